@@ -0,0 +1,439 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	MissingKeyError      = errors.New("the record has no value for the loader's key property")
+	MissingLabelError    = errors.New("edge records need a label")
+	MissingEndpointError = errors.New("edge records need both an out and an in vertex")
+)
+
+// VertexRecord is a vertex to load. Records without a label get the default vertex label.
+type VertexRecord struct {
+	Label      string
+	Properties map[string]interface{}
+}
+
+// EdgeRecord is an edge to load between two existing vertices. OutV and InV are the values of the loader's
+// key property on the vertices, or the vertex ids when the loader has no key.
+type EdgeRecord struct {
+	Label      string
+	OutV, InV  interface{}
+	Properties map[string]interface{}
+}
+
+// RecordError is a record that could not be loaded. Index is the position of the record in its stream.
+type RecordError struct {
+	Index  int64
+	Record interface{}
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %s", e.Index, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// BulkOptions controls how a BulkLoader batches and sends records
+type BulkOptions struct {
+	// BatchSize is the number of records sent in one request, 500 by default
+	BatchSize int
+	// Concurrency is the number of batches sent at the same time, 4 by default. Each batch uses its own pooled connection.
+	Concurrency int
+	// Retries is the number of times a batch is sent again after a transport, server or timeout error.
+	// Batches are only retried as a whole, so set a Key when a batch that timed out may have been committed.
+	Retries int
+	// RetryBackoff is the wait before the first retry of a batch, doubled for each further retry. 100ms by default.
+	RetryBackoff time.Duration
+	// Key makes loading get-or-create: a vertex is only created when no vertex with its label has the same value
+	// for the key property, and an edge is only created when its vertices aren't connected by an edge with its label yet.
+	// Edge records then find their vertices by the key property instead of by id. Give the key an index.
+	// Records with the same key value, or edges with the same label and vertices, are sent by the same worker
+	// one batch after the other, so repeated records in a stream don't create duplicates. Other loaders writing
+	// the same keys at the same time still can, a unique index on the key prevents that.
+	Key string
+	// OnLoaded is called with the id of every vertex or edge loaded or found, for example to map the ids of
+	// imported elements to the ids they got. Calls are serialized.
+	OnLoaded func(index int64, record interface{}, id interface{})
+	// Progress is called with the stats so far after every batch. Calls are serialized.
+	Progress func(stats BulkStats)
+}
+
+// BulkStats describes a load
+type BulkStats struct {
+	// Records counts the records read from the stream, Loaded those that were loaded or found
+	Records int64
+	Loaded  int64
+	Batches int64
+	Retries int64
+	// Failures holds the records that could not be loaded, in the order they failed
+	Failures []RecordError
+	Duration time.Duration
+}
+
+// PerSecond returns the number of records read per second
+func (s BulkStats) PerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Records) / s.Duration.Seconds()
+}
+
+// BulkLoader loads streams of vertices and edges. Records are grouped into batches, each sent as a single
+// parameterized script that is evaluated in one transaction. A record that fails doesn't fail its batch,
+// it is reported in the stats instead.
+type BulkLoader struct {
+	client *Client
+	opts   BulkOptions
+}
+
+// NewBulkLoader returns a loader that sends its batches with the client
+func NewBulkLoader(client *Client, opts BulkOptions) *BulkLoader {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	return &BulkLoader{client: client, opts: opts}
+}
+
+// bulkScript evaluates the per record statements in rows, which must leave the element in e, and returns
+// the ids of the elements and the failed rows. They are returned as a list, which the server sends as two
+// results; a map would be sent as one result per entry.
+const bulkScript = `ids = []
+failed = []
+rows.eachWithIndex { r, i ->
+  try {
+    def e
+%s
+    ids << e.id()
+  } catch (ex) {
+    ids << null
+    failed << [i, ex.toString()]
+  }
+}
+[ids, failed]`
+
+// LoadVertices reads vertices from records until the channel is closed and loads them. It returns the stats of the load,
+// and an error if the context is done or a batch failed, in which case the rest of the stream isn't read.
+func (l *BulkLoader) LoadVertices(ctx context.Context, records <-chan VertexRecord) (*BulkStats, error) {
+	statements := `    def t = g.addV(r.label)
+    r.properties.each { k, v -> t = t.property(k, v) }
+    e = t.next()`
+	if l.opts.Key != "" {
+		statements = `    e = g.V().has(r.label, key, r.properties[key]).tryNext().orElse(null)
+    if (e == null) {
+` + statements + `
+    }`
+	}
+	next := func(ctx context.Context) (interface{}, bool) {
+		select {
+		case r, ok := <-records:
+			return r, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	var partition func(record interface{}) string
+	if l.opts.Key != "" {
+		partition = func(record interface{}) string {
+			return fmt.Sprintf("%#v", record.(VertexRecord).Properties[l.opts.Key])
+		}
+	}
+	return l.load(ctx, fmt.Sprintf(bulkScript, statements), next, partition, func(record interface{}) (map[string]interface{}, error) {
+		r := record.(VertexRecord)
+		label := r.Label
+		if label == "" {
+			label = "vertex"
+		}
+		if _, ok := r.Properties[l.opts.Key]; l.opts.Key != "" && !ok {
+			return nil, MissingKeyError
+		}
+		return map[string]interface{}{"label": label, "properties": bulkProperties(r.Properties)}, nil
+	})
+}
+
+// LoadEdges reads edges from records until the channel is closed and loads them, see LoadVertices.
+// Edges whose vertices can't be found are reported as failed records.
+func (l *BulkLoader) LoadEdges(ctx context.Context, records <-chan EdgeRecord) (*BulkStats, error) {
+	find := `g.V(id)`
+	if l.opts.Key != "" {
+		find = `g.V().has(key, id)`
+	}
+	statements := `    def find = { id -> ` + find + `.tryNext().orElseThrow { new NoSuchElementException("vertex " + id + " not found") } }
+    def outVertex = find(r.outV)
+    def inVertex = find(r.inV)
+`
+	create := `    def t = g.V(outVertex).addE(r.label).to(inVertex)
+    r.properties.each { k, v -> t = t.property(k, v) }
+    e = t.next()`
+	if l.opts.Key != "" {
+		statements += `    e = g.V(outVertex).outE(r.label).where(__.inV().is(inVertex)).tryNext().orElse(null)
+    if (e == null) {
+` + create + `
+    }`
+	} else {
+		statements += create
+	}
+	next := func(ctx context.Context) (interface{}, bool) {
+		select {
+		case r, ok := <-records:
+			return r, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	var partition func(record interface{}) string
+	if l.opts.Key != "" {
+		partition = func(record interface{}) string {
+			r := record.(EdgeRecord)
+			return fmt.Sprintf("%s %#v %#v", r.Label, r.OutV, r.InV)
+		}
+	}
+	return l.load(ctx, fmt.Sprintf(bulkScript, statements), next, partition, func(record interface{}) (map[string]interface{}, error) {
+		r := record.(EdgeRecord)
+		if r.Label == "" {
+			return nil, MissingLabelError
+		}
+		if r.OutV == nil || r.InV == nil {
+			return nil, MissingEndpointError
+		}
+		return map[string]interface{}{"label": r.Label, "outV": r.OutV, "inV": r.InV, "properties": bulkProperties(r.Properties)}, nil
+	})
+}
+
+func bulkProperties(p map[string]interface{}) map[string]interface{} {
+	if p == nil {
+		return map[string]interface{}{}
+	}
+	return p
+}
+
+// bulkBatch is a batch of records with their positions in the stream
+type bulkBatch struct {
+	indexes []int64
+	records []interface{}
+	rows    []interface{}
+}
+
+// bulkLoad is the state of a load shared by its workers
+type bulkLoad struct {
+	mu     sync.Mutex
+	stats  BulkStats
+	start  time.Time
+	err    error
+	cancel context.CancelFunc
+}
+
+func (b *bulkLoad) fail(err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.cancel()
+}
+
+// load sends the records in batches from concurrent workers. Records are spread over the workers as they come,
+// or by the hash of their partition when partition is set, so that records of one partition are loaded in order.
+func (l *BulkLoader) load(parent context.Context, script string, next func(ctx context.Context) (interface{}, bool), partition func(record interface{}) string, row func(record interface{}) (map[string]interface{}, error)) (*BulkStats, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	state := &bulkLoad{start: time.Now(), cancel: cancel}
+
+	queues := make([]chan *bulkBatch, 1)
+	if partition != nil {
+		queues = make([]chan *bulkBatch, l.opts.Concurrency)
+	}
+	for i := range queues {
+		queues[i] = make(chan *bulkBatch)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < l.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(batches <-chan *bulkBatch) {
+			defer wg.Done()
+			for batch := range batches {
+				if err := l.send(ctx, script, batch, state); err != nil {
+					state.fail(err)
+				}
+			}
+		}(queues[i%len(queues)])
+	}
+
+	var index int64
+	pending := make([]*bulkBatch, len(queues))
+	for i := range pending {
+		pending[i] = &bulkBatch{}
+	}
+	flush := func(queue int) bool {
+		if len(pending[queue].rows) == 0 {
+			return true
+		}
+		select {
+		case queues[queue] <- pending[queue]:
+			pending[queue] = &bulkBatch{}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for {
+		record, ok := next(ctx)
+		if !ok {
+			for queue := range queues {
+				if !flush(queue) {
+					break
+				}
+			}
+			break
+		}
+		r, err := row(record)
+		state.mu.Lock()
+		state.stats.Records++
+		if err != nil {
+			state.stats.Failures = append(state.stats.Failures, RecordError{Index: index, Record: record, Err: err})
+		}
+		state.mu.Unlock()
+		queue := 0
+		if partition != nil && err == nil {
+			h := fnv.New32a()
+			h.Write([]byte(partition(record)))
+			queue = int(h.Sum32() % uint32(len(queues)))
+		}
+		batch := pending[queue]
+		if err == nil {
+			batch.indexes = append(batch.indexes, index)
+			batch.records = append(batch.records, record)
+			batch.rows = append(batch.rows, r)
+		}
+		index++
+		if len(batch.rows) == l.opts.BatchSize && !flush(queue) {
+			break
+		}
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	state.stats.Duration = time.Since(state.start)
+	err := state.err
+	if err == nil {
+		err = parent.Err()
+	}
+	return &state.stats, err
+}
+
+// send executes a batch, retrying it after failures that may be temporary, and records its outcome
+func (l *BulkLoader) send(ctx context.Context, script string, batch *bulkBatch, state *bulkLoad) error {
+	bindings := Bind{"rows": batch.rows}
+	if l.opts.Key != "" {
+		bindings["key"] = l.opts.Key
+	}
+	backoff := l.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := l.client.Do(ctx, Query(script).Bindings(bindings))
+		if err == nil {
+			return l.loaded(batch, res, state)
+		}
+		if attempt >= l.opts.Retries || !retryable(err) {
+			return err
+		}
+		state.mu.Lock()
+		state.stats.Retries++
+		state.mu.Unlock()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether sending a batch again may succeed
+func retryable(err error) bool {
+	return errors.Is(err, ErrTransport) || errors.Is(err, ErrServer) || errors.Is(err, ErrTimeout)
+}
+
+// loaded records the ids and failures returned for a batch
+func (l *BulkLoader) loaded(batch *bulkBatch, res *Result, state *bulkLoad) error {
+	values, err := res.Values()
+	if err != nil {
+		return err
+	}
+	var ids, failed []interface{}
+	if len(values) == 2 {
+		ids, _ = values[0].([]interface{})
+		failed, _ = values[1].([]interface{})
+	}
+	if ids == nil || len(ids) != len(batch.rows) {
+		return fmt.Errorf("unexpected bulk load result %v", values)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	isFailed := map[int]bool{}
+	for _, f := range failed {
+		pair, _ := f.([]interface{})
+		if len(pair) != 2 {
+			return fmt.Errorf("unexpected bulk load failure %v", f)
+		}
+		i, ok := bulkIndex(pair[0])
+		if !ok || i < 0 || i >= len(batch.rows) {
+			return fmt.Errorf("unexpected bulk load failure %v", f)
+		}
+		isFailed[i] = true
+		state.stats.Failures = append(state.stats.Failures, RecordError{
+			Index:  batch.indexes[i],
+			Record: batch.records[i],
+			Err:    errors.New(strings.TrimSpace(fmt.Sprint(pair[1]))),
+		})
+	}
+	for i, id := range ids {
+		if isFailed[i] {
+			continue
+		}
+		state.stats.Loaded++
+		if l.opts.OnLoaded != nil {
+			l.opts.OnLoaded(batch.indexes[i], batch.records[i], id)
+		}
+	}
+	state.stats.Batches++
+	if l.opts.Progress != nil {
+		stats := state.stats
+		stats.Duration = time.Since(state.start)
+		l.opts.Progress(stats)
+	}
+	return nil
+}
+
+func bulkIndex(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	}
+	return 0, false
+}
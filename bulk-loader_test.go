@@ -0,0 +1,203 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkResponse loads every row of the batch except those with a name of "bad", giving them their index as id.
+// Like Gremlin Server it sends the list returned by the script as one result per item.
+func bulkResponse(req *testRequest) []*Response {
+	bindings, _ := req.Args["bindings"].(map[string]interface{})
+	b, _ := json.Marshal(bindings["rows"])
+	decoded, _ := DecodeGraphSON(b)
+	rows, _ := decoded.([]interface{})
+	ids, failed := []string{}, []string{}
+	for i, row := range rows {
+		properties, _ := row.(map[string]interface{})["properties"].(map[string]interface{})
+		if properties["name"] == "bad" {
+			ids = append(ids, "null")
+			failed = append(failed, fmt.Sprintf(`[%d, "java.lang.IllegalArgumentException: bad name"]`, i))
+			continue
+		}
+		ids = append(ids, fmt.Sprint(i))
+	}
+	return []*Response{testResponse(req, StatusSuccess, fmt.Sprintf(`[[%s], [%s]]`, strings.Join(ids, ","), strings.Join(failed, ",")))}
+}
+
+func vertexRecords(names ...string) <-chan VertexRecord {
+	records := make(chan VertexRecord, len(names))
+	for _, name := range names {
+		properties := map[string]interface{}{"name": name}
+		if name == "" {
+			properties = nil
+		}
+		records <- VertexRecord{Label: "person", Properties: properties}
+	}
+	close(records)
+	return records
+}
+
+func TestBulkLoaderVertices(t *testing.T) {
+	server := newTestServer(bulkResponse)
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	var mu sync.Mutex
+	loaded := map[int64]interface{}{}
+	progress := 0
+	loader := NewBulkLoader(cl, BulkOptions{BatchSize: 2, Concurrency: 2, Key: "name",
+		OnLoaded: func(index int64, record interface{}, id interface{}) {
+			mu.Lock()
+			loaded[index] = record.(VertexRecord).Properties["name"]
+			mu.Unlock()
+		},
+		Progress: func(stats BulkStats) { progress++ },
+	})
+	stats, err := loader.LoadVertices(context.Background(), vertexRecords("marko", "", "bad", "josh", "vadas"))
+	assert.Empty(t, err)
+	assert.Equal(t, int64(5), stats.Records)
+	assert.Equal(t, int64(3), stats.Loaded)
+	assert.Equal(t, int64(2), stats.Batches)
+	assert.Equal(t, 2, progress)
+	assert.Equal(t, map[int64]interface{}{0: "marko", 3: "josh", 4: "vadas"}, loaded)
+	if assert.Len(t, stats.Failures, 2) {
+		assert.Equal(t, int64(1), stats.Failures[0].Index)
+		assert.Equal(t, MissingKeyError, stats.Failures[0].Err)
+		assert.Equal(t, int64(2), stats.Failures[1].Index)
+		assert.Equal(t, "java.lang.IllegalArgumentException: bad name", stats.Failures[1].Err.Error())
+	}
+	assert.True(t, stats.PerSecond() > 0)
+
+	received := server.received()
+	if assert.Len(t, received, 2) {
+		assert.Contains(t, received[0].Args["gremlin"], "g.V().has(r.label, key, r.properties[key]).tryNext()")
+		assert.Equal(t, "name", received[0].Args["bindings"].(map[string]interface{})["key"])
+	}
+}
+
+func TestBulkLoaderEdges(t *testing.T) {
+	server := newTestServer(bulkResponse)
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	records := make(chan EdgeRecord, 3)
+	records <- EdgeRecord{Label: "knows", OutV: 1, InV: 2, Properties: map[string]interface{}{"weight": 0.5}}
+	records <- EdgeRecord{OutV: 1, InV: 2}
+	records <- EdgeRecord{Label: "knows", OutV: 1}
+	close(records)
+	stats, err := NewBulkLoader(cl, BulkOptions{}).LoadEdges(context.Background(), records)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(1), stats.Loaded)
+	if assert.Len(t, stats.Failures, 2) {
+		assert.Equal(t, MissingLabelError, stats.Failures[0].Err)
+		assert.Equal(t, MissingEndpointError, stats.Failures[1].Err)
+	}
+	received := server.received()
+	if assert.Len(t, received, 1) {
+		script := received[0].Args["gremlin"].(string)
+		assert.Contains(t, script, "g.V(id).tryNext()")
+		assert.Contains(t, script, "g.V(outVertex).addE(r.label).to(inVertex)")
+		assert.NotContains(t, script, "outE(r.label)")
+	}
+}
+
+func TestBulkLoaderRetries(t *testing.T) {
+	var mu sync.Mutex
+	failures := 2
+	server := newTestServer(func(req *testRequest) []*Response {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return []*Response{testResponse(req, StatusServerError, "")}
+		}
+		return bulkResponse(req)
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	stats, err := NewBulkLoader(cl, BulkOptions{Retries: 2, RetryBackoff: time.Millisecond}).LoadVertices(context.Background(), vertexRecords("marko"))
+	assert.Empty(t, err)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, int64(1), stats.Loaded)
+
+	mu.Lock()
+	failures = 1
+	mu.Unlock()
+	stats, err = NewBulkLoader(cl, BulkOptions{BatchSize: 1}).LoadVertices(context.Background(), vertexRecords("marko", "josh"))
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, int64(0), stats.Retries)
+}
+
+func TestBulkLoaderRepeatedKeys(t *testing.T) {
+	// each batch is a transaction that sees the vertices committed before it started and its own
+	var mu sync.Mutex
+	created := map[string]int{}
+	server := newTestServer(func(req *testRequest) []*Response {
+		mu.Lock()
+		committed := map[string]bool{}
+		for name := range created {
+			committed[name] = true
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+
+		bindings, _ := req.Args["bindings"].(map[string]interface{})
+		b, _ := json.Marshal(bindings["rows"])
+		decoded, _ := DecodeGraphSON(b)
+		rows, _ := decoded.([]interface{})
+		ids, added := []string{}, []string{}
+		for i, row := range rows {
+			name, _ := row.(map[string]interface{})["properties"].(map[string]interface{})["name"].(string)
+			if !committed[name] {
+				committed[name] = true
+				added = append(added, name)
+			}
+			ids = append(ids, fmt.Sprint(i))
+		}
+		mu.Lock()
+		for _, name := range added {
+			created[name]++
+		}
+		mu.Unlock()
+		return []*Response{testResponse(req, StatusSuccess, fmt.Sprintf(`[[%s], []]`, strings.Join(ids, ",")))}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	var names []string
+	for i := 0; i < 200; i++ {
+		names = append(names, fmt.Sprintf("person-%d", i%10))
+	}
+	stats, err := NewBulkLoader(cl, BulkOptions{BatchSize: 3, Concurrency: 4, Key: "name"}).LoadVertices(context.Background(), vertexRecords(names...))
+	assert.Empty(t, err)
+	assert.Equal(t, int64(200), stats.Loaded)
+	assert.Len(t, created, 10)
+	for name, n := range created {
+		assert.Equal(t, 1, n, name)
+	}
+}
@@ -0,0 +1,233 @@
+package gremlin
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of an endpoint's circuit breaker
+type CircuitState string
+
+const (
+	// requests flow to the endpoint and failures are counted
+	CircuitClosed CircuitState = "closed"
+	// the endpoint is on ice, no requests are sent to it until the open timeout lapses
+	CircuitOpen CircuitState = "open"
+	// a limited number of trial requests decide whether the endpoint is healthy again
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// number of buckets the sliding window is split into
+const breakerBuckets = 10
+
+// CircuitBreakerOptions configures the circuit breaker kept for every endpoint.
+// Zero values are replaced with the defaults below.
+type CircuitBreakerOptions struct {
+	// Window is the sliding window over which the failure rate is measured. Defaults to one minute.
+	Window time.Duration
+	// MinRequests is the number of requests the window must hold before the breaker can open. Defaults to 10.
+	MinRequests int
+	// FailureRate is the share of failed requests in the window that opens the breaker. Defaults to 0.5.
+	FailureRate float64
+	// OpenTimeout is how long the breaker stays open the first time it opens. It grows with the square of the
+	// number of times the breaker opened in a row, like the on ice time always did. Defaults to 5 seconds.
+	OpenTimeout time.Duration
+	// MaxOpenTimeout caps the open time. Defaults to 5 minutes.
+	MaxOpenTimeout time.Duration
+	// HalfOpenRequests is the number of trial requests let through while half open. The breaker closes once
+	// all of them succeeded and opens again as soon as one fails. Defaults to 1.
+	HalfOpenRequests int
+}
+
+func (o CircuitBreakerOptions) window() time.Duration {
+	if o.Window <= 0 {
+		return time.Minute
+	}
+	return o.Window
+}
+
+func (o CircuitBreakerOptions) minRequests() int {
+	if o.MinRequests <= 0 {
+		return 10
+	}
+	return o.MinRequests
+}
+
+func (o CircuitBreakerOptions) failureRate() float64 {
+	if o.FailureRate <= 0 {
+		return 0.5
+	}
+	return o.FailureRate
+}
+
+func (o CircuitBreakerOptions) openTimeout(trips int) time.Duration {
+	base, max := o.OpenTimeout, o.MaxOpenTimeout
+	if base <= 0 {
+		base = 5 * time.Second
+	}
+	if max <= 0 {
+		max = 5 * time.Minute
+	}
+	d := base * time.Duration(trips*trips)
+	if d > max || d <= 0 {
+		return max
+	}
+	return d
+}
+
+func (o CircuitBreakerOptions) halfOpenRequests() int {
+	if o.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return o.HalfOpenRequests
+}
+
+// Configures the circuit breaker that decides when an endpoint is put on ice
+func OptClientCircuitBreaker(opts CircuitBreakerOptions) OptClient {
+	return func(c *Client) error {
+		c.factory.breakerOpts = opts
+		return nil
+	}
+}
+
+type breakerBucket struct {
+	start     int64 // unix nanoseconds the bucket was started at
+	successes int
+	failures  int
+}
+
+// circuitBreaker tracks the health of a single endpoint
+type circuitBreaker struct {
+	mu          sync.Mutex
+	state       CircuitState
+	buckets     [breakerBuckets]breakerBucket
+	trips       int // times the breaker opened without closing in between
+	openUntil   time.Time
+	trials      int
+	successes   int
+	trialsSince time.Time
+	lastErr     error
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{state: CircuitClosed}
+}
+
+// breakerFor returns the circuit breaker of the endpoint
+func breakerFor(m *sync.Map) *circuitBreaker {
+	val, _ := m.LoadOrStore("Breaker", newCircuitBreaker())
+	return val.(*circuitBreaker)
+}
+
+// allow reports whether a request may be sent to the endpoint. While half open every allowed request is a trial.
+// Trials that never report back are given up on after the open timeout, so the breaker can't get stuck half open.
+func (b *circuitBreaker) allow(now time.Time, opts CircuitBreakerOptions) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateAt(now) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.trials >= opts.halfOpenRequests() && now.Sub(b.trialsSince) > opts.openTimeout(1) {
+			b.trials, b.successes, b.trialsSince = 0, 0, now
+		}
+		if b.trials < opts.halfOpenRequests() {
+			b.trials++
+			return true
+		}
+	}
+	return false
+}
+
+// stateAt moves an open breaker to half open once the open timeout lapsed. b.mu must be held.
+func (b *circuitBreaker) stateAt(now time.Time) CircuitState {
+	if b.state == CircuitOpen && !now.Before(b.openUntil) {
+		b.state = CircuitHalfOpen
+		b.trials, b.successes, b.trialsSince = 0, 0, now
+	}
+	return b.state
+}
+
+func (b *circuitBreaker) success(now time.Time, opts CircuitBreakerOptions) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateAt(now) {
+	case CircuitClosed:
+		b.bucket(now, opts).successes++
+	case CircuitHalfOpen:
+		b.successes++
+		if b.successes >= opts.halfOpenRequests() {
+			b.state = CircuitClosed
+			b.trips = 0
+			b.buckets = [breakerBuckets]breakerBucket{}
+		}
+	}
+}
+
+// failure counts a failed request, opening the breaker once the failure rate over the window is too high
+func (b *circuitBreaker) failure(now time.Time, opts CircuitBreakerOptions, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastErr = err
+	switch b.stateAt(now) {
+	case CircuitClosed:
+		b.bucket(now, opts).failures++
+		successes, failures := b.counts(now, opts)
+		total := successes + failures
+		if total >= opts.minRequests() && float64(failures)/float64(total) >= opts.failureRate() {
+			b.open(now, opts)
+		}
+	case CircuitHalfOpen:
+		b.open(now, opts)
+	}
+}
+
+// trip opens the breaker straight away, for failures that leave no doubt the endpoint is down
+func (b *circuitBreaker) trip(now time.Time, opts CircuitBreakerOptions, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastErr = err
+	if b.stateAt(now) != CircuitOpen {
+		b.open(now, opts)
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time, opts CircuitBreakerOptions) {
+	b.trips++
+	b.state = CircuitOpen
+	b.openUntil = now.Add(opts.openTimeout(b.trips))
+}
+
+// bucket returns the bucket of the window that now falls into, clearing it if it holds older counts
+func (b *circuitBreaker) bucket(now time.Time, opts CircuitBreakerOptions) *breakerBucket {
+	width := int64(opts.window()) / breakerBuckets
+	start := now.UnixNano() - now.UnixNano()%width
+	bucket := &b.buckets[(start/width)%breakerBuckets]
+	if bucket.start != start {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// counts sums the requests within the window
+func (b *circuitBreaker) counts(now time.Time, opts CircuitBreakerOptions) (successes, failures int) {
+	since := now.Add(-opts.window()).UnixNano()
+	for _, bucket := range b.buckets {
+		if bucket.start > since {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return
+}
+
+// status fills in the breaker's side of the endpoint status
+func (b *circuitBreaker) status(now time.Time, status *EndpointStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status.State = b.stateAt(now)
+	status.ErrorScore = b.trips
+	status.OnIce = status.State == CircuitOpen
+	status.OnIceUntil = b.openUntil
+	status.LastError = b.lastErr
+}
@@ -0,0 +1,116 @@
+package gremlin
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	opts := CircuitBreakerOptions{MinRequests: 4, FailureRate: 0.5, OpenTimeout: time.Second}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.success(now, opts)
+	b.success(now, opts)
+	b.failure(now, opts, errors.New("broken pipe"))
+	assert.Equal(t, CircuitClosed, b.state)
+	// 2 out of 4 requests failed
+	b.failure(now, opts, errors.New("broken pipe"))
+	assert.Equal(t, CircuitOpen, b.state)
+	assert.False(t, b.allow(now, opts))
+	assert.Equal(t, now.Add(time.Second), b.openUntil)
+}
+
+func TestCircuitBreakerWindowSlides(t *testing.T) {
+	opts := CircuitBreakerOptions{Window: 10 * time.Second, MinRequests: 2, FailureRate: 0.5}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.failure(now, opts, errors.New("broken pipe"))
+	// the first failure dropped out of the window
+	now = now.Add(11 * time.Second)
+	b.success(now, opts)
+	b.failure(now, opts, errors.New("broken pipe"))
+	assert.Equal(t, CircuitOpen, b.state)
+
+	b = newCircuitBreaker()
+	b.failure(now, opts, errors.New("broken pipe"))
+	now = now.Add(11 * time.Second)
+	b.failure(now, opts, errors.New("broken pipe"))
+	assert.Equal(t, CircuitClosed, b.state)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	opts := CircuitBreakerOptions{OpenTimeout: time.Second, HalfOpenRequests: 2}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.trip(now, opts, errors.New("connection refused"))
+	assert.False(t, b.allow(now.Add(500*time.Millisecond), opts))
+
+	// only two trials are let through once the open timeout lapsed
+	now = now.Add(time.Second)
+	assert.True(t, b.allow(now, opts))
+	assert.Equal(t, CircuitHalfOpen, b.state)
+	assert.True(t, b.allow(now, opts))
+	assert.False(t, b.allow(now, opts))
+
+	b.success(now, opts)
+	assert.Equal(t, CircuitHalfOpen, b.state)
+	b.success(now, opts)
+	assert.Equal(t, CircuitClosed, b.state)
+	assert.Equal(t, 0, b.trips)
+	assert.True(t, b.allow(now, opts))
+}
+
+func TestCircuitBreakerHalfOpenFailure(t *testing.T) {
+	opts := CircuitBreakerOptions{OpenTimeout: time.Second}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.trip(now, opts, errors.New("connection refused"))
+	now = now.Add(time.Second)
+	assert.True(t, b.allow(now, opts))
+	b.failure(now, opts, errors.New("broken pipe"))
+	assert.Equal(t, CircuitOpen, b.state)
+	// the open time grows with the number of trips in a row
+	assert.Equal(t, now.Add(4*time.Second), b.openUntil)
+	assert.Equal(t, "broken pipe", b.lastErr.Error())
+}
+
+func TestCircuitBreakerLostTrials(t *testing.T) {
+	opts := CircuitBreakerOptions{OpenTimeout: time.Second}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.trip(now, opts, errors.New("connection refused"))
+	now = now.Add(time.Second)
+	assert.True(t, b.allow(now, opts))
+	assert.False(t, b.allow(now, opts))
+	// the trial never reported back, another one is allowed after the open timeout
+	assert.True(t, b.allow(now.Add(2*time.Second), opts))
+}
+
+func TestScriptErrorsDontPutEndpointOnIce(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		return []*Response{testResponse(req, StatusScriptEvaluationError, "")}
+	})
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientCircuitBreaker(CircuitBreakerOptions{MinRequests: 1}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err = cl.ExecQuery("g.V(")
+		assert.True(t, errors.Is(err, ConnectionErrors[StatusScriptEvaluationError]))
+	}
+	status := cl.Endpoints()[0]
+	assert.Equal(t, CircuitClosed, status.State)
+	assert.False(t, status.OnIce)
+	// the socket was reused rather than redialed for every request
+	assert.Equal(t, uint64(0), cl.Stats().Reconnects)
+}
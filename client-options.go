@@ -0,0 +1,43 @@
+package gremlin
+
+import (
+	"compress/flate"
+	"errors"
+)
+
+var (
+	InvalidCompressionLevelError = errors.New("compression level must be between -2 (huffman only) and 9 (best compression)")
+)
+
+// OptClient configures a Client when it is created with NewClientWithOptions
+type OptClient func(*Client) error
+
+// Sets the authentication options used to answer the server's SASL challenge
+func OptClientAuth(options ...OptAuth) OptClient {
+	return func(c *Client) error {
+		c.Auth = append(c.Auth, options...)
+		return nil
+	}
+}
+
+// Sets request options that are applied to every request executed by the client.
+// Options set on an individual request take precedence over these defaults.
+func OptClientRequestDefaults(opts RequestOptions) OptClient {
+	return func(c *Client) error {
+		c.defaults = opts
+		return nil
+	}
+}
+
+// Negotiates permessage-deflate compression with the server and compresses the messages written
+// at the given compress/flate level. Servers that don't support compression are used uncompressed.
+func OptClientCompression(level int) OptClient {
+	return func(c *Client) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return InvalidCompressionLevelError
+		}
+		c.factory.dialer.EnableCompression = true
+		c.factory.compressionLevel = level
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+// Command gremlin-migrate lists, applies and rolls back the Gremlin script migrations in a directory.
+// It connects to the servers in GREMLIN_SERVERS, authenticating with GREMLIN_USER and GREMLIN_PASS when set.
+//
+//	gremlin-migrate -dir migrations list
+//	gremlin-migrate -dir migrations up [version]
+//	gremlin-migrate -dir migrations down [steps]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/go-gremlin/gremlin/migrate"
+)
+
+func main() {
+	dir := flag.String("dir", "migrations", "directory holding the <version>_<name>.up.groovy and .down.groovy files")
+	label := flag.String("label", "", "label of the marker vertex (default \"migrations\")")
+	marker := flag.String("marker", "", "name of the set of migrations (default \"default\")")
+	lockTTL := flag.Duration("lock-ttl", 0, "how long the lock is held before another deployer may take it over (default 15m)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] list | up [version] | down [steps]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*dir, migrate.Options{Label: *label, Marker: *marker, LockTTL: *lockTTL}, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if err == migrate.UsageError {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(dir string, opts migrate.Options, args []string) error {
+	migrations, err := migrate.Load(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	client, err := gremlin.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	migrator, err := migrate.New(client, migrations, opts)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return migrate.Run(ctx, migrator, args, os.Stdout)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+const (
+	prompt             = "gremlin> "
+	continuationPrompt = "......> "
+	maxHistory         = 1000
+)
+
+var quitError = errors.New("quit")
+
+// console evaluates scripts and console commands, keeping the bindings, session and output settings between them
+type console struct {
+	client   *gremlin.Client
+	session  *gremlin.Session
+	bindings gremlin.Bind
+	format   string
+	timing   bool
+	out      io.Writer
+
+	history     []string
+	historyFile string
+}
+
+func newConsole(client *gremlin.Client, out io.Writer) *console {
+	return &console{client: client, bindings: gremlin.Bind{}, format: "table", out: out}
+}
+
+// run reads inputs from in until it ends or :quit. Interactive consoles prompt for input and report
+// errors without stopping, the others stop at the first error. interrupt cancels the input being evaluated.
+func (c *console) run(ctx context.Context, in io.Reader, interactive bool, interrupt <-chan os.Signal) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var lines []string
+	for {
+		if interactive {
+			if len(lines) == 0 {
+				fmt.Fprint(c.out, prompt)
+			} else {
+				fmt.Fprint(c.out, continuationPrompt)
+			}
+		}
+		if !scanner.Scan() {
+			if interactive {
+				fmt.Fprintln(c.out)
+			}
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			if len(lines) > 0 {
+				return c.execute(ctx, joinLines(lines), interrupt)
+			}
+			return nil
+		}
+		line := scanner.Text()
+		if len(lines) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if !strings.HasPrefix(strings.TrimSpace(lines[0]), ":") && incomplete(strings.Join(lines, "\n")) {
+			continue
+		}
+		err := c.execute(ctx, joinLines(lines), interrupt)
+		lines = nil
+		if err == quitError {
+			return nil
+		}
+		if err != nil {
+			if !interactive {
+				return err
+			}
+			fmt.Fprintln(c.out, "error:", err)
+		}
+	}
+}
+
+// joinLines joins the lines of a script, dropping the backslashes that continued them
+func joinLines(lines []string) string {
+	joined := make([]string, len(lines))
+	for i, line := range lines {
+		joined[i] = strings.TrimSuffix(strings.TrimRightFunc(line, unicode.IsSpace), "\\")
+	}
+	return strings.Join(joined, "\n")
+}
+
+// incomplete reports whether a script needs more lines: it has unclosed brackets, strings or block comments,
+// or its code ends with a backslash, a dot or a comma. Quotes and brackets in comments don't count.
+func incomplete(script string) bool {
+	depth := 0
+	var quote rune
+	escaped := false
+	var code strings.Builder
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			code.WriteRune(r)
+			continue
+		}
+		if r == '/' && i+1 < len(runes) && runes[i+1] == '/' {
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			code.WriteRune('\n')
+			continue
+		}
+		if r == '/' && i+1 < len(runes) && runes[i+1] == '*' {
+			end := i + 2
+			for end+1 < len(runes) && !(runes[end] == '*' && runes[end+1] == '/') {
+				end++
+			}
+			if end+1 >= len(runes) {
+				return true
+			}
+			i = end + 1
+			code.WriteRune(' ')
+			continue
+		}
+		switch r {
+		case '\'', '"':
+			quote = r
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+		code.WriteRune(r)
+	}
+	if quote != 0 || depth > 0 {
+		return true
+	}
+	trimmed := strings.TrimRightFunc(code.String(), unicode.IsSpace)
+	return strings.HasSuffix(trimmed, "\\") || strings.HasSuffix(trimmed, ".") || strings.HasSuffix(trimmed, ",")
+}
+
+// execute runs a console command or evaluates a script
+func (c *console) execute(ctx context.Context, input string, interrupt <-chan os.Signal) error {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+	c.remember(input)
+	if strings.HasPrefix(input, ":") {
+		return c.command(ctx, input)
+	}
+
+	// an interrupt while no script was running shouldn't cancel this one
+	select {
+	case <-interrupt:
+	default:
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req := gremlin.Query(input)
+	if len(c.bindings) > 0 {
+		bindings := gremlin.Bind{}
+		for k, v := range c.bindings {
+			bindings[k] = v
+		}
+		req.Bindings(bindings)
+	}
+	start := time.Now()
+	var res *gremlin.Result
+	var err error
+	if c.session != nil {
+		res, err = c.session.Do(ctx, req)
+	} else {
+		res, err = c.client.Do(ctx, req)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return err
+	}
+	n, err := c.print(res)
+	if err != nil {
+		return err
+	}
+	if c.timing {
+		fmt.Fprintf(c.out, "%d results in %s\n", n, elapsed.Round(time.Microsecond))
+	}
+	return nil
+}
+
+const help = `:bind name=value   bind a value to a name for the scripts that follow, values are JSON or GraphSON, other text is a string
+:unbind name       remove a binding
+:bindings          list the bindings
+:session [close]   evaluate the scripts that follow in a session, or close it
+:format name       print results as a table, json or graphson
+:timing on|off     print how long scripts take
+:history           list the inputs of this and earlier consoles, run the console under rlwrap to recall and edit them
+:help              show this help
+:quit              leave the console
+Scripts continue on the next line while brackets, strings or block comments are open, or when a line ends with \, . or ,
+`
+
+// command runs a console command
+func (c *console) command(ctx context.Context, input string) error {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":bind", ":b":
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errors.New("usage: :bind name=value")
+		}
+		key = strings.TrimSpace(key)
+		if err := gremlin.ValidateBindingName(key); err != nil {
+			return err
+		}
+		c.bindings[key] = parseValue(value)
+	case ":unbind":
+		delete(c.bindings, arg)
+	case ":bindings":
+		names := make([]string, 0, len(c.bindings))
+		for name := range c.bindings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(c.out, "%s=%s\n", name, cell(plain(c.bindings[name])))
+		}
+	case ":session":
+		switch arg {
+		case "":
+			if c.session == nil {
+				c.session = c.client.NewSession()
+			}
+			fmt.Fprintln(c.out, "session", c.session.Id())
+		case "close":
+			if c.session == nil {
+				return nil
+			}
+			err := c.session.Close(ctx)
+			c.session = nil
+			return err
+		default:
+			return errors.New("usage: :session [close]")
+		}
+	case ":format":
+		if arg != "table" && arg != "json" && arg != "graphson" {
+			return errors.New("usage: :format table|json|graphson")
+		}
+		c.format = arg
+	case ":timing":
+		if arg != "on" && arg != "off" {
+			return errors.New("usage: :timing on|off")
+		}
+		c.timing = arg == "on"
+	case ":history", ":h":
+		for i, input := range c.history {
+			fmt.Fprintf(c.out, "%4d  %s\n", i+1, strings.ReplaceAll(input, "\n", "\n      "))
+		}
+	case ":help", ":?":
+		fmt.Fprint(c.out, help)
+	case ":quit", ":exit", ":q", ":x":
+		return quitError
+	default:
+		return fmt.Errorf("unknown command %s, see :help", name)
+	}
+	return nil
+}
+
+// parseValue reads a binding value. JSON and GraphSON are decoded, with integers sized like
+// literals in a script, quoted strings are unquoted, and anything else is taken as a string.
+func parseValue(s string) interface{} {
+	s = strings.TrimSpace(s)
+	if json.Valid([]byte(s)) {
+		if v, err := gremlin.DecodeGraphSON([]byte(s)); err == nil {
+			if n, ok := v.(int64); ok {
+				return int(n)
+			}
+			return v
+		}
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// loadHistory reads the history kept by earlier consoles, the file is created by the first input
+func (c *console) loadHistory(path string) {
+	c.historyFile = path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, entry := range strings.Split(string(b), "\x00\n") {
+		if entry != "" {
+			c.history = append(c.history, entry)
+		}
+	}
+	if len(c.history) > maxHistory {
+		c.history = c.history[len(c.history)-maxHistory:]
+	}
+}
+
+// remember adds an input to the history, entries are separated by a NUL and a newline as they may span lines
+func (c *console) remember(input string) {
+	if len(c.history) > 0 && c.history[len(c.history)-1] == input {
+		return
+	}
+	c.history = append(c.history, input)
+	if c.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(c.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(input + "\x00\n")
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	Processor string
+	Script    string
+	Bindings  map[string]interface{}
+}
+
+// newServer answers scripts by what they contain and records the requests it receives
+func newServer(requests *[]request) *httptest.Server {
+	var mu sync.Mutex
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// skip the mime type header
+			var req struct {
+				RequestId struct {
+					Value string `json:"@value"`
+				} `json:"requestId"`
+				Processor string `json:"processor"`
+				Args      struct {
+					Gremlin  string          `json:"gremlin"`
+					Bindings json.RawMessage `json:"bindings"`
+				} `json:"args"`
+			}
+			json.Unmarshal(msg[int(msg[0])+1:], &req)
+			var bindings map[string]interface{}
+			if len(req.Args.Bindings) > 0 {
+				decoded, _ := gremlin.DecodeGraphSON(req.Args.Bindings)
+				bindings, _ = decoded.(map[string]interface{})
+			}
+			mu.Lock()
+			*requests = append(*requests, request{Processor: req.Processor, Script: req.Args.Gremlin, Bindings: bindings})
+			mu.Unlock()
+
+			code, data := gremlin.StatusSuccess, `[]`
+			switch {
+			case strings.Contains(req.Args.Gremlin, "fail"):
+				code = gremlin.StatusScriptEvaluationError
+			case strings.Contains(req.Args.Gremlin, "values('age')"):
+				data = `[{"@type": "g:Int32", "@value": 29}]`
+			case strings.Contains(req.Args.Gremlin, "valueMap"):
+				data = `[{"name": ["marko"], "age": [{"@type": "g:Int32", "@value": 29}]}, {"name": ["vadas"]}]`
+			case req.Args.Gremlin == "g.V(1)":
+				data = `[{"@type": "g:Vertex", "@value": {"id": {"@type": "g:Int64", "@value": 1}, "label": "person", "properties": {
+					"name": [{"@type": "g:VertexProperty", "@value": {"id": {"@type": "g:Int64", "@value": 10}, "value": "marko", "label": "name"}}]}}}]`
+			}
+			ws.WriteJSON(gremlin.Response{
+				RequestId: req.RequestId.Value,
+				Status:    &gremlin.ResponseStatus{Code: code},
+				Result:    &gremlin.ResponseResult{Data: json.RawMessage(data)},
+			})
+		}
+	}))
+}
+
+func newTestConsole(t *testing.T, requests *[]request) (*console, *bytes.Buffer) {
+	server := newServer(requests)
+	t.Cleanup(server.Close)
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(client.Close)
+	var out bytes.Buffer
+	return newConsole(client, &out), &out
+}
+
+func TestIncomplete(t *testing.T) {
+	for script, want := range map[string]bool{
+		"g.V().count()":                  false,
+		"g.V().":                         true,
+		"g.V().has('name',":              true,
+		"g.V().has('name', 'a(')":        false,
+		"g.V().has('name', 'it\\'s')":    false,
+		"[1, 2":                          true,
+		"x = 1 \\":                       true,
+		"m = [a: 1,\n  b: 2]":            false,
+		"'unclosed":                      true,
+		"g.V() // don't":                 false,
+		"g.V() /* it's (open */":         false,
+		"g.V().\n  // count(\n  count()": false,
+		"g.V(). // next":                 true,
+		"g.V() /* unclosed":              true,
+		"g.V().has('url', 'http://x')":   false,
+	} {
+		assert.Equal(t, want, incomplete(script), script)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	assert.Equal(t, 7, parseValue("7"))
+	assert.Equal(t, 1.5, parseValue(" 1.5 "))
+	assert.Equal(t, true, parseValue("true"))
+	assert.Equal(t, "marko", parseValue(`"marko"`))
+	assert.Equal(t, "marko", parseValue(`'marko'`))
+	assert.Equal(t, "marko rodriguez", parseValue(`marko rodriguez`))
+	assert.Equal(t, "1 2", parseValue(`1 2`))
+	assert.Equal(t, []interface{}{int64(1), "a"}, parseValue(`[1, "a"]`))
+	assert.Equal(t, int32(1), parseValue(`{"@type": "g:Int32", "@value": 1}`))
+}
+
+func TestConsole(t *testing.T) {
+	var requests []request
+	c, out := newTestConsole(t, &requests)
+	ctx := context.Background()
+
+	input := `:bind name="marko"
+g.V().has('name', name).
+  values('age')
+g.V().valueMap()
+:format json
+g.V(1)
+:format graphson
+g.V().values('age')
+:bindings
+`
+	assert.Empty(t, c.run(ctx, strings.NewReader(input), false, nil))
+	assert.Equal(t, `==>29
+age   name
+---   ----
+[29]  ["marko"]
+      ["vadas"]
+[
+  {
+    "id": 1,
+    "label": "person",
+    "properties": {
+      "name": [
+        "marko"
+      ]
+    }
+  }
+]
+[
+  {
+    "@type": "g:Int32",
+    "@value": 29
+  }
+]
+name=marko
+`, out.String())
+	if assert.Len(t, requests, 4) {
+		assert.Equal(t, "g.V().has('name', name).\n  values('age')", requests[0].Script)
+		assert.Equal(t, map[string]interface{}{"name": "marko"}, requests[0].Bindings)
+	}
+
+	// scripts stop at the first error, interactive consoles carry on
+	requests = nil
+	err := c.run(ctx, strings.NewReader("fail()\ng.V()\n"), false, nil)
+	assert.True(t, errors.Is(err, gremlin.ErrQuery), err)
+	assert.Len(t, requests, 1)
+
+	out.Reset()
+	assert.Empty(t, c.run(ctx, strings.NewReader(":unknown\n:timing on\ng.V()\n:quit\ng.V()\n"), true, nil))
+	assert.Contains(t, out.String(), "error: unknown command :unknown, see :help\n")
+	assert.Contains(t, out.String(), "gremlin> []\n0 results in ")
+	assert.Len(t, requests, 2)
+}
+
+func TestConsoleSession(t *testing.T) {
+	var requests []request
+	c, out := newTestConsole(t, &requests)
+	ctx := context.Background()
+
+	assert.Empty(t, c.run(ctx, strings.NewReader(":session\nx = 1\n:session close\ng.V()\n"), false, nil))
+	assert.True(t, strings.HasPrefix(out.String(), "session "))
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, "session", requests[0].Processor)
+		assert.Equal(t, "session", requests[1].Processor) // close
+		assert.Equal(t, "", requests[2].Processor)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var requests []request
+	c, out := newTestConsole(t, &requests)
+	path := filepath.Join(t.TempDir(), "history")
+	c.loadHistory(path)
+	assert.Empty(t, c.run(context.Background(), strings.NewReader("g.V().\n  count()\n:timing off\n"), false, nil))
+
+	b, err := os.ReadFile(path)
+	assert.Empty(t, err)
+	assert.Equal(t, "g.V().\n  count()\x00\n:timing off\x00\n", string(b))
+
+	c, out = newTestConsole(t, &requests)
+	c.loadHistory(path)
+	assert.Empty(t, c.command(context.Background(), ":history"))
+	assert.Equal(t, "   1  g.V().\n        count()\n   2  :timing off\n", out.String())
+}
+
+func TestConsoleComments(t *testing.T) {
+	var requests []request
+	c, _ := newTestConsole(t, &requests)
+	input := "g.V() // don't\ng.V(). /* next */\n  count() /* it's\n done */\n"
+	assert.Empty(t, c.run(context.Background(), strings.NewReader(input), false, nil))
+	if assert.Len(t, requests, 2) {
+		assert.Equal(t, "g.V() // don't", requests[0].Script)
+		assert.Equal(t, "g.V(). /* next */\n  count() /* it's\n done */", requests[1].Script)
+	}
+}
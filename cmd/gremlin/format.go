@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+// print writes the results of a script in the console's format and returns the number of results.
+// Tables print rows of maps as columns and other results one per line, json prints the results as
+// plain JSON and graphson prints the data as the server sent it.
+func (c *console) print(res *gremlin.Result) (int, error) {
+	values, err := res.Values()
+	if err != nil {
+		return 0, err
+	}
+	switch c.format {
+	case "graphson":
+		data := res.Data
+		if len(data) == 0 {
+			data = []byte("[]")
+		}
+		var b bytes.Buffer
+		if err := json.Indent(&b, data, "", "  "); err != nil {
+			return 0, err
+		}
+		b.WriteByte('\n')
+		_, err = c.out.Write(b.Bytes())
+	case "json":
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = plain(v)
+		}
+		var b []byte
+		if b, err = json.MarshalIndent(items, "", "  "); err == nil {
+			_, err = fmt.Fprintf(c.out, "%s\n", b)
+		}
+	default:
+		err = c.table(values)
+	}
+	return len(values), err
+}
+
+// table prints results that are all maps as a table with a column per key, and other results one per line
+func (c *console) table(values []interface{}) error {
+	rows := make([]map[string]interface{}, 0, len(values))
+	columns := map[string]bool{}
+	for _, v := range values {
+		row, ok := plain(v).(map[string]interface{})
+		if !ok {
+			rows = nil
+			break
+		}
+		rows = append(rows, row)
+		for key := range row {
+			columns[key] = true
+		}
+	}
+	if rows == nil {
+		for _, v := range values {
+			fmt.Fprintf(c.out, "==>%s\n", cell(plain(v)))
+		}
+		return nil
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// id and label first, as for elements
+	names := make([]string, 0, len(columns))
+	for _, first := range []string{"id", "label"} {
+		if columns[first] {
+			names = append(names, first)
+			delete(columns, first)
+		}
+	}
+	rest := make([]string, 0, len(columns))
+	for name := range columns {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(names, "\t"))
+	dashes := make([]string, len(names))
+	for i, name := range names {
+		dashes[i] = strings.Repeat("-", len(name))
+	}
+	fmt.Fprintln(w, strings.Join(dashes, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(names))
+		for i, name := range names {
+			if v, ok := row[name]; ok {
+				cells[i] = cell(v)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// plain turns decoded GraphSON into values that print as plain JSON: elements become maps
+// and typed values of unknown types their value
+func plain(v interface{}) interface{} {
+	switch val := v.(type) {
+	case gremlin.Vertex:
+		properties := map[string]interface{}{}
+		for key, list := range val.Properties {
+			values := make([]interface{}, len(list))
+			for i, p := range list {
+				values[i] = plain(p.Value)
+			}
+			properties[key] = values
+		}
+		return map[string]interface{}{"id": plain(val.Id), "label": val.Label, "properties": properties}
+	case gremlin.Edge:
+		properties := map[string]interface{}{}
+		for key, p := range val.Properties {
+			properties[key] = plain(p.Value)
+		}
+		return map[string]interface{}{"id": plain(val.Id), "label": val.Label, "outV": plain(val.OutV), "inV": plain(val.InV), "properties": properties}
+	case gremlin.VertexProperty:
+		return map[string]interface{}{"id": plain(val.Id), "key": val.Label, "value": plain(val.Value)}
+	case gremlin.Property:
+		return map[string]interface{}{"key": val.Key, "value": plain(val.Value)}
+	case gremlin.Path:
+		return map[string]interface{}{"labels": val.Labels, "objects": plain(val.Objects)}
+	case gremlin.Traverser:
+		return plain(val.Value)
+	case gremlin.TypedValue:
+		return plain(val.Value)
+	case gremlin.Set:
+		return plain([]interface{}(val))
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = plain(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = plain(item)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[cell(plain(k))] = plain(item)
+		}
+		return m
+	}
+	return v
+}
+
+// cell formats a plain value for a table, strings as they are and other values as compact JSON
+func cell(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,90 @@
+// Command gremlin is a console for Gremlin Server. It connects to the servers in GREMLIN_SERVERS,
+// authenticating with GREMLIN_USER and GREMLIN_PASS when set, and evaluates scripts interactively
+// or from the command line:
+//
+//	gremlin
+//	gremlin -e "g.V().count()"
+//	gremlin -f seed.groovy -format json
+//
+// Inputs may also be console commands such as :bind and :session, see :help. The console reads lines
+// without editing them and :history only lists earlier inputs, run it under rlwrap for line editing and
+// recall. The history of earlier consoles is kept in ~/.gremlin_history, or the file named by GREMLIN_HISTORY.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+func main() {
+	script := flag.String("e", "", "evaluate the script and exit")
+	file := flag.String("f", "", "evaluate the scripts and commands in the file and exit, stopping at the first error")
+	format := flag.String("format", "table", "print results as a table, json or graphson")
+	timing := flag.Bool("timing", false, "print how long scripts take, always on in interactive consoles")
+	session := flag.Bool("session", false, "evaluate the scripts in a session")
+	flag.Parse()
+
+	if err := run(*script, *file, *format, *timing, *session); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(script, file, format string, timing, session bool) error {
+	client, err := gremlin.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	c := newConsole(client, os.Stdout)
+	c.timing = timing
+	ctx := context.Background()
+	if err := c.command(ctx, ":format "+format); err != nil {
+		return err
+	}
+	if session {
+		c.session = client.NewSession()
+	}
+	defer func() {
+		if c.session != nil {
+			c.session.Close(ctx)
+		}
+	}()
+
+	switch {
+	case script != "":
+		return c.execute(ctx, script, nil)
+	case file != "":
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.run(ctx, f, false, nil)
+	}
+
+	// scripts piped in are evaluated like a file
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return c.run(ctx, os.Stdin, false, nil)
+	}
+	c.timing = true
+	history := os.Getenv("GREMLIN_HISTORY")
+	if home, err := os.UserHomeDir(); history == "" && err == nil {
+		history = filepath.Join(home, ".gremlin_history")
+	}
+	if history != "" {
+		c.loadHistory(history)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	fmt.Printf("connected to %s, :help for help\n", strings.TrimSpace(os.Getenv("GREMLIN_SERVERS")))
+	return c.run(ctx, os.Stdin, true, interrupt)
+}
@@ -0,0 +1,53 @@
+package gremlin
+
+import (
+	"compress/flate"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	// a large and very compressible result
+	data := "[" + strings.Repeat(`"vertex",`, 10000) + `"vertex"]`
+	server := newTestServer(successResponse(data))
+	server.Upgrader.EnableCompression = true
+	defer server.Close()
+
+	cl, err := NewClientWithOptions(server.Url, OptClientCompression(flate.BestSpeed))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	res, err := cl.ExecQuery("g.V().label()")
+	assert.Empty(t, err)
+	assert.Equal(t, data, string(res))
+
+	st := cl.Stats()
+	assert.True(t, st.BytesIn > uint64(len(data)))
+	assert.True(t, st.WireBytesIn < st.BytesIn/10, "expected %d wire bytes to be compressed from %d", st.WireBytesIn, st.BytesIn)
+}
+
+func TestNoCompression(t *testing.T) {
+	data := "[" + strings.Repeat(`"vertex",`, 10000) + `"vertex"]`
+	server := newTestServer(successResponse(data))
+	server.Upgrader.EnableCompression = true
+	defer server.Close()
+
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, err = cl.ExecQuery("g.V().label()")
+	assert.Empty(t, err)
+
+	st := cl.Stats()
+	assert.True(t, st.WireBytesIn > st.BytesIn)
+	assert.True(t, st.WireBytesOut > st.BytesOut)
+}
+
+func TestInvalidCompressionLevel(t *testing.T) {
+	_, err := NewClientWithOptions("ws://localhost:8182/gremlin", OptClientCompression(12))
+	assert.Equal(t, InvalidCompressionLevelError, err)
+}
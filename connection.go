@@ -1,23 +1,36 @@
 package gremlin
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
 	"net/url"
 	"os"
 	"strings"
-	"github.com/gorilla/websocket"
-	"github.com/jessicacglenn/pool"
-	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Clients include the necessary info to connect to the server and the underlying socket
 type Client struct {
+	inFlight	int64 // accessed atomically, keep first for alignment
 	Remote 		*url.URL
-	pool		pool.Pool
+	transport	Transport
 	Auth   		[]OptAuth
 	factory		*EndpointFactory
+	defaults	RequestOptions
+	observer	Observer
+	stats		*clientStats
+	interceptors	[]Interceptor
+	poolOpts	PoolOptions
+	limiter		limiter
+
+	mu		sync.Mutex
+	closed		bool
+	active		sync.WaitGroup	// requests admitted before the client was shut down
+	shutdown	chan struct{}	// closed to cancel the requests still running at the shutdown deadline
+	closeOnce	sync.Once
+	sessions	sync.Map	// session ids used by requests, closed on shutdown
 }
 
 
@@ -34,19 +47,32 @@ var (
 // perhaps if we change the urlStr to interface{} we can have either a slice or a string passed through and
 // we will be able to use this for both the
 func NewClient(urlStr string, options ...OptAuth) (*Client, error) {
+	return NewClientWithOptions(urlStr, OptClientAuth(options...))
+}
+
+// NewClientWithOptions creates a client for the given servers, configured by the provided client options
+func NewClientWithOptions(urlStr string, options ...OptClient) (*Client, error) {
 
 	fact, err := NewEndpointFactory(urlStr)
 	if err != nil {
 		return nil, err
 	}
 
-	c := &Client{Auth: options, factory: fact}
+	c := &Client{
+		factory: fact,
+		stats: newClientStats(),
+		shutdown: make(chan struct{}),
+	}
+	for _, op := range options {
+		if err := op(c); err != nil {
+			return nil, err
+		}
+	}
+	fact.stats = c.stats
 
-	p, err := pool.NewChannelPool(1, 30, fact.connectSocket)
-	if err != nil {
+	if c.transport, err = newTransport(c); err != nil {
 		return nil, err
 	}
-	c.pool = p
 	return c, nil
 }
 
@@ -56,8 +82,11 @@ func NewClient(urlStr string, options ...OptAuth) (*Client, error) {
 // if the server is not reachable then we should mark it as unavailable
 // and then try again a little later.
 
+// Close cancels any requests still in flight and closes the client. Use Shutdown to let them finish first.
 func (c *Client) Close() {
-	c.pool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Shutdown(ctx)
 }
 
 // Client executes the provided request
@@ -67,106 +96,100 @@ func (c *Client) ExecQuery(query string) ([]byte, error) {
 }
 
 func (c *Client) Exec(req *Request) ([]byte, error) {
-	con, err := c.pool.Get()
+	return c.ExecContext(context.Background(), req)
+}
+
+// ExecContext executes the provided request. The context is handed to the client's interceptors and observer,
+// so spans created for the request become children of the span in ctx.
+func (c *Client) ExecContext(ctx context.Context, req *Request) ([]byte, error) {
+	res, err := c.Do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	return c.executeForConn(req, con)
+	return res.Data, nil
 }
 
-func (c *Client) executeForConn(req *Request, con *pool.PoolConn) ([]byte, error) {
-	requestMessage, err := GraphSONSerializer(req)
-	if err != nil {
+// Do executes the provided request through the client's interceptor chain
+func (c *Client) Do(ctx context.Context, req *Request) (*Result, error) {
+	if err := c.begin(); err != nil {
 		return nil, err
 	}
+	defer c.active.Done()
+	req = req.withDefaults(c.defaults)
+	if len(req.Args.Bindings) > 0 {
+		if err := c.factory.validateBindings(req.Args.Bindings); err != nil {
+			return nil, err
+		}
+	}
+	return chainInterceptors(c.interceptors, c.send)(ctx, req)
+}
 
-	// todo : remove me
-	fmt.Printf("Sending Message:\n%s\n", requestMessage)
-
-	if err := con.WriteMessage(websocket.BinaryMessage, requestMessage); err != nil {
-		// todo : remove me
-		print("error", err)
-		return nil, err
+// send is the final handler of the interceptor chain, it executes the request against the server
+func (c *Client) send(ctx context.Context, req *Request) (*Result, error) {
+	info := &RequestInfo{RequestId: req.RequestId, Op: req.Op, Processor: req.Processor, onBatch: batchHandler(ctx)}
+	if req.Args != nil && req.Args.Session != "" {
+		c.sessions.Store(req.Args.Session, true)
+	}
+	if c.observer != nil {
+		ctx = c.observer.RequestStarted(ctx, req)
 	}
-	b, err := c.readResponse(con)
+	atomic.AddInt64(&c.inFlight, 1)
+	start := time.Now()
 
-	// todo : remove me
-	fmt.Printf("Receiving Response:\n%s\n", b)
+	data, err := c.roundTrip(ctx, req, info)
 
-	// update the endpoint to mark success/error, this allows us to back off endpoints that are continuing to fail
+	info.Duration = time.Since(start)
+	atomic.AddInt64(&c.inFlight, -1)
+	c.stats.request(info.StatusCode, info.Duration)
+	if c.observer != nil {
+		c.observer.RequestFinished(ctx, info, err)
+	}
 	if err != nil {
-
-		// todo : remove me
-		fmt.Printf("ERROR in Response:\n%s\n", err.Error())
-
-		c.factory.failedEndpoint(con)
-	} else {
-		c.factory.successfulEndpoint(con)
+		return nil, err
 	}
-
-	// if the request was successful, return to the pool, otherwise close and remove from the pool.
-	err = con.Close()
-	return b, err
+	return &Result{
+		RequestId: req.RequestId,
+		StatusCode: info.StatusCode,
+		Data: data,
+		Warnings: info.Warnings,
+		Batches: info.Batches,
+		Endpoint: info.Endpoint,
+		client: c,
+		aliases: req.Args.Aliases,
+	}, nil
 }
 
-
-// this doesn't seem to be useful outside of the Exec function (in this context)
-func (c *Client) readResponse(con *pool.PoolConn) (data []byte, err error) {
-	// Data buffer
-	var message []byte
-	var dataItems []json.RawMessage
-	inBatchMode := false
-	// Receive data
-	for {
-		if _, message, err = con.ReadMessage(); err != nil {
-			return
-		}
-		var res *Response
-		if err = json.Unmarshal(message, &res); err != nil {
-			return
-		}
-		var items []json.RawMessage
-		switch res.Status.Code {
-		case StatusNoContent:
-
-			return
-
-		case StatusAuthenticate:
-
-			return c.authenticate(con, res.RequestId)
-		case StatusPartialContent:
-			inBatchMode = true
-			if err = json.Unmarshal(res.Result.Data, &items); err != nil {
-				return
-			}
-			dataItems = append(dataItems, items...)
-
-
-		case StatusSuccess:
-			if inBatchMode {
-				if err = json.Unmarshal(res.Result.Data, &items); err != nil {
-					return
-				}
-				dataItems = append(dataItems, items...)
-				data, err = json.Marshal(dataItems)
-			} else {
-				data = res.Result.Data
-			}
-
-			return
-
-		default:
-			if errmsg, exists := ConnectionErrors[res.Status.Code]; exists {
-				err = errmsg
-			} else {
-				err = UnknownErr
-			}
-			return
+// roundTrip sends the request with the transport once a connection is available
+func (c *Client) roundTrip(ctx context.Context, req *Request, info *RequestInfo) ([]byte, error) {
+	start := time.Now()
+	waited, err := c.limiter.acquire(ctx, c.poolOpts, c.shutdown)
+	if waited {
+		c.stats.poolWait(time.Since(start))
+	}
+	if err != nil {
+		if err != PoolExhaustedError && err != ErrClientClosed {
+			c.stats.add(&c.stats.poolTimeouts, 1)
 		}
+		return nil, err
 	}
-	return
+	defer c.limiter.release()
+	return c.transport.RoundTrip(ctx, req, info)
 }
 
+// InFlight returns the number of requests currently being executed by the client
+func (c *Client) InFlight() int {
+	return int(atomic.LoadInt64(&c.inFlight))
+}
+
+// PoolSize returns the number of idle connections held in the client's pool
+func (c *Client) PoolSize() int {
+	return c.transport.Idle()
+}
+
+// Endpoints returns the current health of each server the client connects to
+func (c *Client) Endpoints() []EndpointStatus {
+	return c.factory.statuses()
+}
 
 // AuthInfo includes all info related with SASL authentication with the Gremlin server
 // ChallengeId is the  requestID in the 407 status (AUTHENTICATE) response given by the server.
@@ -217,29 +240,19 @@ func OptAuthUserPass(user, pass string) OptAuth {
 	}
 }
 
-
-func (c *Client) authenticate(con *pool.PoolConn, requestId string) ([]byte, error) {
-	auth, err := NewAuthInfo(c.Auth...)
-	if err != nil {
-		return nil, err
+// NewClientFromEnv creates a client for the servers listed in GREMLIN_SERVERS. When GREMLIN_USER is set
+// the client authenticates with GREMLIN_USER and GREMLIN_PASS, see OptAuthEnv.
+func NewClientFromEnv(options ...OptClient) (*Client, error) {
+	connString := strings.TrimSpace(os.Getenv("GREMLIN_SERVERS"))
+	if connString == "" {
+		return nil, NoServersSetError
 	}
-	var sasl []byte
-	sasl = append(sasl, 0)
-	sasl = append(sasl, []byte(auth.User)...)
-	sasl = append(sasl, 0)
-	sasl = append(sasl, []byte(auth.Pass)...)
-	saslEnc := base64.StdEncoding.EncodeToString(sasl)
-	args := &RequestArgs{Sasl: saslEnc}
-	authReq := &Request{
-		RequestId: requestId,
-		Processor: "trasversal",
-		Op:        "authentication",
-		Args:      args,
+	if _, ok := os.LookupEnv("GREMLIN_USER"); ok {
+		options = append([]OptClient{OptClientAuth(OptAuthEnv())}, options...)
 	}
-	return c.executeForConn(authReq, con)
+	return NewClientWithOptions(connString, options...)
 }
 
-
 // LEGACY
 
 var defaultClient *Client
@@ -52,7 +52,9 @@ func TestThreadsafeClient(t *testing.T) {
 	concurrency := 100
 	for i := 0; i < concurrency; i++ {
 		go func(c *Client, d chan struct{}, n int) {
-			res, err := c.ExecQuery(fmt.Sprintf("%v * %v", n, n))
+			req, err := Queryf("%v * %v", n, n)
+			assert.Empty(t, err)
+			res, err := c.Exec(req)
 			assert.Empty(t, err)
 			var m []map[string]interface{}
 			err = json.Unmarshal(res, &m)
@@ -74,8 +76,31 @@ func BenchmarkSimpleRequest(b *testing.B) {
 	}
 	for i := 0; i < b.N; i++ {
 		n := i
-		res, _ := c.ExecQuery(fmt.Sprintf("%v * %v", n, n))
+		req, _ := Queryf("%v * %v", n, n)
+		res, _ := c.Exec(req)
 		var m []map[string]interface{}
 		json.Unmarshal(res, &m)
 	}
 }
+
+func TestNewClientFromEnv(t *testing.T) {
+	server := newTestServer(successResponse("[]"))
+	defer server.Close()
+
+	t.Setenv("GREMLIN_SERVERS", "")
+	_, err := NewClientFromEnv()
+	assert.Equal(t, NoServersSetError, err)
+
+	t.Setenv("GREMLIN_SERVERS", server.Url)
+	t.Setenv("GREMLIN_USER", "user")
+	t.Setenv("GREMLIN_PASS", "pass")
+	cl, err := NewClientFromEnv()
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	auth, err := NewAuthInfo(cl.Auth...)
+	assert.Empty(t, err)
+	assert.Equal(t, "user", auth.User)
+	assert.Equal(t, "pass", auth.Pass)
+}
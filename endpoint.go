@@ -1,6 +1,7 @@
 package gremlin
 
 import (
+	"fmt"
 	"sync"
 	"time"
 	"github.com/satori/go.uuid"
@@ -12,7 +13,10 @@ import (
 
 var (
 	EndpointOnIceError = errors.New("endpoint on ice, try again later")
+	ErrAllEndpointsUnavailable = errors.New("all endpoints are unavailable")
 	EmptyUrlError = errors.New("missing url for this endpoint")
+	UnsupportedSchemeError = errors.New("server urls must use one of the ws, wss, http or https schemes")
+	MixedSchemesError = errors.New("all servers must use the same transport, either websockets (ws, wss) or http (http, https)")
 )
 
 
@@ -24,19 +28,14 @@ func NewEndpoint(urlStr string) (*sync.Map, error) {
 	m.Store("Id", id)
 	m.Store("Url", urlStr)
 	m.Store("LastResponse", 0)
-	m.Store("ErrorScore", 0)
-	m.Store("OnIceUntil", time.Now())
+	m.Store("Breaker", newCircuitBreaker())
 	return &m, err
 }
 
 
-func newEndpointsChannel(urlStr string) (*sync.Map, chan *sync.Map, error) {
-	endpoints, err := SplitServers(urlStr)
-	if err != nil {
-		return nil, nil, err
-	}
+func newEndpointList(endpoints []*url.URL) (*sync.Map, []*sync.Map, error) {
 	endpointmap := sync.Map{}
-	endpointchannel := make(chan *sync.Map, len(endpoints))
+	var endpointlist []*sync.Map
 
 
 	for _, endpoint := range endpoints {
@@ -47,17 +46,51 @@ func newEndpointsChannel(urlStr string) (*sync.Map, chan *sync.Map, error) {
 
 		if val, err := NewEndpoint(endpoint.String()); err == nil {
 			endpointmap.Store(endpoint.String(), val)
-			endpointchannel <- val
+			endpointlist = append(endpointlist, val)
 		}
 	}
-	if len(endpointchannel) == 0 {
+	if len(endpointlist) == 0 {
 		return nil, nil, NoEndpointsError
 	}
-	return &endpointmap, endpointchannel, nil
+	return &endpointmap, endpointlist, nil
+}
+
+
+// EndpointFailure describes why an endpoint could not be used
+type EndpointFailure struct {
+	Url        string
+	Err        error
+	OnIceUntil time.Time
+}
+
+// EndpointsUnavailableError is returned when no endpoint could be connected to.
+// It matches ErrAllEndpointsUnavailable with errors.Is.
+type EndpointsUnavailableError struct {
+	Failures []EndpointFailure
+}
+
+func (e *EndpointsUnavailableError) Error() string {
+	var failures []string
+	for _, f := range e.Failures {
+		switch {
+		case f.Err != nil && f.OnIceUntil.After(time.Now()):
+			failures = append(failures, fmt.Sprintf("%s: %v (on ice until %s)", f.Url, f.Err, f.OnIceUntil.Format(time.RFC3339)))
+		case f.Err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", f.Url, f.Err))
+		default:
+			failures = append(failures, fmt.Sprintf("%s: on ice until %s", f.Url, f.OnIceUntil.Format(time.RFC3339)))
+		}
+	}
+	return ErrAllEndpointsUnavailable.Error() + ": " + strings.Join(failures, "; ")
 }
 
+func (e *EndpointsUnavailableError) Is(target error) bool {
+	return target == ErrAllEndpointsUnavailable
+}
 
-// if a string is provided as a comma seperated list then we should be able to create a cluster from that
+// if a string is provided as a comma seperated list then we should be able to create a cluster from that.
+// the scheme of the urls selects the transport: ws and wss connect over websockets, http and https
+// send requests to the http endpoint. all servers in a cluster have to use the same transport.
 func SplitServers(connString string) (servers []*url.URL, err error) {
 	serverStrings := strings.Split(connString, ",")
 	if len(serverStrings) < 1 {
@@ -69,59 +102,35 @@ func SplitServers(connString string) (servers []*url.URL, err error) {
 		if u, err = url.Parse(strings.TrimSpace(serverString)); err != nil {
 			return
 		}
+		if !isWebSocketScheme(u.Scheme) && !isHTTPScheme(u.Scheme) {
+			return nil, UnsupportedSchemeError
+		}
+		if len(servers) > 0 && isHTTPScheme(u.Scheme) != isHTTPScheme(servers[0].Scheme) {
+			return nil, MixedSchemesError
+		}
 		servers = append(servers, u)
 	}
 	return
 }
 
+func isWebSocketScheme(scheme string) bool {
+	return scheme == "ws" || scheme == "wss"
+}
 
-
-
-
-// if the endpoint keeps erroring out it will be put 'on ice' and will not be available
-// for a length of time. The amount of time it is out for will be determined by 'putEndpointOnIce'
-func endpointOnIce(m *sync.Map) bool {
-	val, exists := m.Load("OnIceUntil")
-	if exists && (val.(time.Time)).After(time.Now()) {
-		return true
-	}
-	return false
+func isHTTPScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
 }
 
-func putEndpointOnIce(m *sync.Map) {
-	// todo : does this require a sync mutex?
 
-	// error score
-	var errorScore int
-	val, ex := m.Load("ErrorScore")
-	if ex {
-		errorScore = val.(int)
-	} else {
-		errorScore = 0
-	}
 
-	// new error score
-	errorScore = errorScore + 1
-	iceInt := (errorScore * errorScore) * 5
-	onIceUntil := time.Now().Add(time.Duration(iceInt) * time.Second)
-	m.Store("OnIceUntil", onIceUntil)
-	m.Store("ErrorScore", errorScore)
-}
 
-func reduceErrorScore(m *sync.Map) {
-	// error score
-	var errorScore int
-	val, ex := m.Load("ErrorScore")
-	if ex {
-		errorScore = val.(int)
-	} else {
-		errorScore = 0
-	}
-	// new error score
-	if errorScore > 0 {
-		errorScore = errorScore - 1
-	}
-	m.Store("ErrorScore", errorScore)
+
+// if the endpoint keeps erroring out its circuit breaker opens and it is put 'on ice', it will not be available
+// for a length of time. The amount of time it is out for is determined by the CircuitBreakerOptions
+func endpointOnIce(m *sync.Map) bool {
+	var status EndpointStatus
+	breakerFor(m).status(time.Now(), &status)
+	return status.OnIce
 }
 
 func urlStrForEndpoint(m *sync.Map) (string, error) {
@@ -1,121 +1,291 @@
 package gremlin
 
 import (
+	"context"
+	"net"
 	"sync"
 	"github.com/gorilla/websocket"
 	"net/http"
-	"github.com/satori/go.uuid"
-	"github.com/jessicacglenn/pool"
+	"time"
 )
 
 type EndpointFactory struct {
-	endpoints			chan *sync.Map
+	endpoints			[]*sync.Map
+	next				int // index of the endpoint to try first, guarded by mu
 	mu 					*sync.Mutex
 	endpointmap			*sync.Map
+	stats				*clientStats
+	conns				sync.Map // *websocket.Conn -> *connInfo
+	keepAlive			time.Duration
+	validateIdle		time.Duration
+	maxIdle				time.Duration
+	maxLifetime			time.Duration
+	dialer				*websocket.Dialer
+	compressionLevel	int
+	http				bool // the endpoints are http rather than websocket urls
+	maxDialAttempts		int
+	breakerOpts			CircuitBreakerOptions
+	serializer			GraphSONVersion
+	graphBinary			bool // requests and responses are GraphBinary, typed as in serializer
+	dialWebSocket		func(urlStr string) (*websocket.Conn, error)
 }
 
 func NewEndpointFactory(urlStr string) (ef *EndpointFactory, err error) {
-	em, ec, err := newEndpointsChannel(urlStr)
+	servers, err := SplitServers(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	em, el, err := newEndpointList(servers)
 	if err != nil {
 		return nil, err
 	}
 
 	ef = &EndpointFactory{
-		endpoints: ec,
+		http: isHTTPScheme(servers[0].Scheme),
+		endpoints: el,
 		endpointmap: em,
 		mu: &sync.Mutex{},
+		keepAlive: DefaultKeepAliveInterval,
+		validateIdle: DefaultValidateIdle,
+		serializer: GraphSONv2,
+		dialer: &websocket.Dialer{
+			ReadBufferSize: 8192,
+			WriteBufferSize: 8192,
+		},
 	}
+	ef.dialWebSocket = ef.dial
 
 	return
 }
 
+// connectSocket dials the next available endpoint. Endpoints that can't be reached are put on ice
+// and the next one is tried, up to maxDialAttempts dials or one per endpoint by default.
 func (f *EndpointFactory) connectSocket() (*websocket.Conn, error) {
-	endpoint, err := f.findValidEndpoint()
+	attempts := f.maxDialAttempts
+	if attempts <= 0 {
+		attempts = len(f.endpoints)
+	}
+	for i := 0; i < attempts; i++ {
+		endpoint, err := f.findValidEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		urlStr, err := urlStrForEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		ws, err := f.dialWebSocket(urlStr)
+		if err != nil {
+			f.endpointUnreachable(endpoint, err)
+			continue
+		}
+		if f.stats != nil {
+			f.stats.add(&f.stats.reconnects, 1)
+		}
+		if f.dialer.EnableCompression {
+			ws.SetCompressionLevel(f.compressionLevel)
+		}
+		f.track(ws, endpoint, urlStr)
+		// a socket dialed while the breaker is half open carries the trial it was allowed for
+		if endpointState(endpoint) == CircuitHalfOpen {
+			f.connInfo(ws).trial = true
+		}
+		return ws, nil
+	}
+	return nil, f.unavailableError()
+}
+
+// dial opens a websocket using the configured dialer
+func (f *EndpointFactory) dial(urlStr string) (*websocket.Conn, error) {
+	return f.dialContext(context.Background(), urlStr)
+}
 
+// dialEndpoint opens a websocket to one endpoint outside the pool, giving up when ctx is done.
+// The socket is tracked as in use, so requests over it count towards the endpoint's breaker.
+func (f *EndpointFactory) dialEndpoint(ctx context.Context, urlStr string) (*websocket.Conn, error) {
+	ws, err := f.dialContext(ctx, urlStr)
 	if err != nil {
 		return nil, err
 	}
-	urlStr, err := urlStrForEndpoint(endpoint)
-	if err != nil {
-		return nil, err
+	if f.dialer.EnableCompression {
+		ws.SetCompressionLevel(f.compressionLevel)
+	}
+	if endpoint, ok := f.endpointmap.Load(urlStr); ok {
+		now := time.Now()
+		f.conns.Store(ws, &connInfo{endpoint: endpoint.(*sync.Map), url: urlStr, created: now, lastUsed: now.UnixNano(), state: connInUse})
 	}
-	dialer := websocket.Dialer{}
-	dialer.WriteBufferSize = 8192
-	dialer.ReadBufferSize = 8192
-	ws, _, err := dialer.Dial(urlStr, http.Header{})
+	return ws, nil
+}
 
+// dialContext opens a websocket using the configured dialer, giving up when ctx is done
+func (f *EndpointFactory) dialContext(ctx context.Context, urlStr string) (*websocket.Conn, error) {
+	dialer := *f.dialer
+	dialer.NetDial = nil
+	dialer.NetDialContext = f.netDialContext()
+	ws, _, err := dialer.DialContext(ctx, urlStr, http.Header{})
+	return ws, err
+}
 
-	if err != nil {
-		putEndpointOnIce(endpoint)
-		return f.connectSocket()
+// netDialContext returns the function used to open network connections, counting the bytes that go over the wire
+func (f *EndpointFactory) netDialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	netDial := f.dialer.NetDialContext
+	if netDial == nil {
+		if f.dialer.NetDial != nil {
+			netDial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return f.dialer.NetDial(network, addr)
+			}
+		} else {
+			netDial = (&net.Dialer{}).DialContext
+		}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := netDial(ctx, network, addr)
+		if err != nil {
+			return conn, err
+		}
+		return &countingConn{Conn: conn, stats: f.stats}, nil
 	}
-	return ws, err
 }
 
-func (f *EndpointFactory) findValidEndpoint() (*sync.Map, error) {
-	return f.selectEndpoint( nil)
+// countingConn counts the bytes read and written on a network connection. It can also wait for the
+// server to send something, keeping what it read for the websocket reading the connection.
+type countingConn struct {
+	net.Conn
+	stats   *clientStats
+	pending []byte // read while waiting, not yet handed to the websocket
 }
 
-func (f *EndpointFactory) selectEndpoint(firstid *uuid.UUID) (*sync.Map, error) {
-	// todo : this might require the sync mutex when retrieving from the channel
-	f.mu.Lock()
-	m := <- f.endpoints
-	f.endpoints <- m
-	f.mu.Unlock()
-	thisid, err := idForEndpoint(m)
-	if err != nil {
-		return nil, err
+func (c *countingConn) Read(b []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
 	}
-	if firstid != nil && firstid.String() == thisid.String() {
-		return nil, EndpointOnIceError
+	n, err := c.Conn.Read(b)
+	if c.stats != nil {
+		c.stats.add(&c.stats.wireBytesIn, n)
 	}
-	if endpointOnIce(m) {
-		// find the next endpoint
-		eid, err := idForEndpoint(m)
-		if err != nil {
-			return nil, err
-		}
-		// put the endpoint back in the queue
-		if len(f.endpoints) == 1 {
-			// if there is only one endpoint in the channel then make sure to return instead of
-			// trying recursion
-			return nil, EndpointOnIceError
-		}
+	return n, err
+}
 
-		// if there is only one in the queue then this won't work, and if none are available
-		// then this will freeze
-		if firstid == nil {
-			firstid = eid
-		}
-		sm, err := f.selectEndpoint(firstid)
-		if err != nil {
-			return nil, err
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if c.stats != nil {
+		c.stats.add(&c.stats.wireBytesOut, n)
+	}
+	return n, err
+}
+
+// await waits until the deadline for the server to send something
+func (c *countingConn) await(deadline time.Time) error {
+	c.Conn.SetReadDeadline(deadline)
+	defer c.Conn.SetReadDeadline(time.Time{})
+	b := make([]byte, 4096)
+	n, err := c.Conn.Read(b)
+	if c.stats != nil {
+		c.stats.add(&c.stats.wireBytesIn, n)
+	}
+	c.pending = append(c.pending, b[:n]...)
+	if n > 0 {
+		return nil
+	}
+	return err
+}
+
+// findValidEndpoint returns the next endpoint whose circuit breaker lets a request through, going round robin
+// through the endpoints
+func (f *EndpointFactory) findValidEndpoint() (*sync.Map, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for i := 0; i < len(f.endpoints); i++ {
+		idx := (f.next + i) % len(f.endpoints)
+		if breakerFor(f.endpoints[idx]).allow(now, f.breakerOpts) {
+			f.next = (idx + 1) % len(f.endpoints)
+			return f.endpoints[idx], nil
 		}
-		if neid, err := idForEndpoint(sm); err != nil || neid == eid {
-			if err != nil {
-				return nil, err
-			} else {
-				return nil, EndpointOnIceError
-			}
+	}
+	return nil, f.unavailableError()
+}
+
+// unavailableError lists the last failure of every endpoint
+func (f *EndpointFactory) unavailableError() error {
+	err := &EndpointsUnavailableError{}
+	for _, status := range f.statuses() {
+		err.Failures = append(err.Failures, EndpointFailure{
+			Url: status.Url,
+			Err: status.LastError,
+			OnIceUntil: status.OnIceUntil,
+		})
+	}
+	return err
+}
+
+// failedEndpoint counts a failed request against the endpoint the connection was dialed to
+func (f *EndpointFactory) failedEndpoint(ws *websocket.Conn, err error) {
+	if info := f.connInfo(ws); info != nil {
+		f.endpointFailed(info.endpoint, err)
+	}
+}
+
+func (f *EndpointFactory) successfulEndpoint(ws *websocket.Conn) {
+	if info := f.connInfo(ws); info != nil {
+		f.endpointSucceeded(info.endpoint)
+	}
+}
+
+func (f *EndpointFactory) endpointSucceeded(m *sync.Map) {
+	breakerFor(m).success(time.Now(), f.breakerOpts)
+}
+
+// endpointFailed counts a failed request, the endpoint goes on ice once too many requests in the window failed
+func (f *EndpointFactory) endpointFailed(m *sync.Map, err error) {
+	breakerFor(m).failure(time.Now(), f.breakerOpts, err)
+}
+
+// endpointUnreachable puts the endpoint on ice straight away, it could not even be connected to
+func (f *EndpointFactory) endpointUnreachable(m *sync.Map, err error) {
+	breakerFor(m).trip(time.Now(), f.breakerOpts, err)
+}
+
+// endpointUsable reports whether a request may be sent over an idle socket to the endpoint.
+// While the breaker is half open a socket is only used for a trial request.
+func (f *EndpointFactory) endpointUsable(info *connInfo) bool {
+	breaker := breakerFor(info.endpoint)
+	switch endpointState(info.endpoint) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if info.trial {
+			info.trial = false
+			return true
 		}
-		return sm, nil
+		return breaker.allow(time.Now(), f.breakerOpts)
 	}
-	return m, nil
+	return false
+}
+
+func endpointState(m *sync.Map) CircuitState {
+	var status EndpointStatus
+	breakerFor(m).status(time.Now(), &status)
+	return status.State
 }
 
-func (f *EndpointFactory) failedEndpoint(con *pool.PoolConn) {
-	con.MarkUnusable()
-	val, ex := f.endpointmap.Load(con.RemoteAddr().String())
-	if ex {
-		sm := val.(*sync.Map)
-		putEndpointOnIce(sm)
+// endpointUrl returns the url of the endpoint the connection was dialed to
+func (f *EndpointFactory) endpointUrl(ws *websocket.Conn) string {
+	if info := f.connInfo(ws); info != nil {
+		return info.url
 	}
+	return ws.RemoteAddr().String()
 }
 
-func (f *EndpointFactory) successfulEndpoint(con *pool.PoolConn) {
-	val, ex := f.endpointmap.Load(con.RemoteAddr().String())
-	if ex {
-		sm := val.(*sync.Map)
-		reduceErrorScore(sm)
+func (f *EndpointFactory) statuses() []EndpointStatus {
+	var statuses []EndpointStatus
+	for _, m := range f.endpoints {
+		urlStr, _ := urlStrForEndpoint(m)
+		status := EndpointStatus{Url: urlStr}
+		breakerFor(m).status(time.Now(), &status)
+		statuses = append(statuses, status)
 	}
-}
\ No newline at end of file
+	return statuses
+}
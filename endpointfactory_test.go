@@ -0,0 +1,137 @@
+package gremlin
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDialer records the urls dialed and fails for the urls in down
+type fakeDialer struct {
+	mu     sync.Mutex
+	down   map[string]error
+	dialed []string
+	dial   func(urlStr string) (*websocket.Conn, error)
+}
+
+func (d *fakeDialer) Dial(urlStr string) (*websocket.Conn, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, urlStr)
+	err := d.down[urlStr]
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if d.dial != nil {
+		return d.dial(urlStr)
+	}
+	return nil, errors.New("no server for " + urlStr)
+}
+
+func newFakeFactory(t *testing.T, urlStr string, d *fakeDialer) *EndpointFactory {
+	f, err := NewEndpointFactory(urlStr)
+	assert.Empty(t, err)
+	f.dialWebSocket = d.Dial
+	return f
+}
+
+func TestFindValidEndpointRoundRobin(t *testing.T) {
+	f := newFakeFactory(t, "ws://a:8182,ws://b:8182,ws://c:8182", &fakeDialer{})
+	f.endpointUnreachable(f.endpoints[1], errors.New("down"))
+
+	var urls []string
+	for i := 0; i < 4; i++ {
+		m, err := f.findValidEndpoint()
+		assert.Empty(t, err)
+		urlStr, _ := urlStrForEndpoint(m)
+		urls = append(urls, urlStr)
+	}
+	assert.Equal(t, []string{"ws://a:8182", "ws://c:8182", "ws://a:8182", "ws://c:8182"}, urls)
+}
+
+func TestConnectSocketAllEndpointsDown(t *testing.T) {
+	d := &fakeDialer{down: map[string]error{
+		"ws://a:8182": errors.New("connection refused"),
+		"ws://b:8182": errors.New("no such host"),
+	}}
+	f := newFakeFactory(t, "ws://a:8182,ws://b:8182", d)
+
+	ws, err := f.connectSocket()
+	assert.Nil(t, ws)
+	assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+	assert.Equal(t, []string{"ws://a:8182", "ws://b:8182"}, d.dialed)
+
+	var unavailable *EndpointsUnavailableError
+	assert.True(t, errors.As(err, &unavailable))
+	assert.Len(t, unavailable.Failures, 2)
+	assert.Equal(t, "connection refused", unavailable.Failures[0].Err.Error())
+	assert.Equal(t, "no such host", unavailable.Failures[1].Err.Error())
+	assert.True(t, strings.Contains(err.Error(), "ws://a:8182: connection refused"))
+	assert.True(t, strings.Contains(err.Error(), "ws://b:8182: no such host"))
+
+	// everything is on ice now, so nothing is dialed at all
+	_, err = f.connectSocket()
+	assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+	assert.Len(t, d.dialed, 2)
+
+	statuses := f.statuses()
+	assert.Equal(t, "connection refused", statuses[0].LastError.Error())
+	assert.True(t, statuses[0].OnIce)
+}
+
+func TestConnectSocketMaxDialAttempts(t *testing.T) {
+	d := &fakeDialer{down: map[string]error{
+		"ws://a:8182": errors.New("down"),
+		"ws://b:8182": errors.New("down"),
+		"ws://c:8182": errors.New("down"),
+	}}
+	f := newFakeFactory(t, "ws://a:8182,ws://b:8182,ws://c:8182", d)
+	f.maxDialAttempts = 2
+
+	_, err := f.connectSocket()
+	assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+	assert.Equal(t, []string{"ws://a:8182", "ws://b:8182"}, d.dialed)
+
+	// the next call picks up where the last one stopped
+	_, err = f.connectSocket()
+	assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+	assert.Equal(t, []string{"ws://a:8182", "ws://b:8182", "ws://c:8182"}, d.dialed)
+}
+
+func TestConnectSocketSkipsFailingEndpoint(t *testing.T) {
+	s := newTestServer(successResponse(`[1]`))
+	d := &fakeDialer{
+		down: map[string]error{"ws://down:8182": errors.New("connection refused")},
+		dial: func(urlStr string) (*websocket.Conn, error) {
+			ws, _, err := websocket.DefaultDialer.Dial(urlStr, nil)
+			return ws, err
+		},
+	}
+	f := newFakeFactory(t, "ws://down:8182,"+s.Url, d)
+
+	for i := 0; i < 3; i++ {
+		ws, err := f.connectSocket()
+		assert.Empty(t, err)
+		ws.Close()
+	}
+	// the failing endpoint was only tried once, it is on ice afterwards
+	assert.Equal(t, []string{"ws://down:8182", s.Url, s.Url, s.Url}, d.dialed)
+}
+
+func TestNewClientAllEndpointsUnreachable(t *testing.T) {
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewClient("ws://127.0.0.1:1,ws://127.0.0.1:2")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+	case <-time.After(5 * time.Second):
+		t.Fatal("NewClient kept retrying unreachable endpoints")
+	}
+}
@@ -0,0 +1,105 @@
+package gremlin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Every error returned for a failed request matches one of these with errors.Is, so callers can tell
+// a broken connection from a bad script. Errors of the context passed to a request are returned as is.
+var (
+	// the request could not be sent or the response could not be read, the endpoint may be down
+	ErrTransport = errors.New("gremlin: transport error")
+	// the server's response could not be understood, or the server could not understand the request
+	ErrProtocol = errors.New("gremlin: protocol error")
+	// the server failed to process a valid request
+	ErrServer = errors.New("gremlin: server error")
+	// the script or its bindings are invalid, retrying the same request won't help
+	ErrQuery = errors.New("gremlin: query error")
+	// the credentials were rejected or missing
+	ErrAuth = errors.New("gremlin: authentication error")
+	// the server gave up evaluating the request
+	ErrTimeout = errors.New("gremlin: timeout")
+)
+
+var MissingStatusError = errors.New("response has no status")
+
+// ResponseError is returned when the server answers a request with an error status code.
+// It unwraps to the matching ConnectionErrors entry and matches the kind of the status code.
+type ResponseError struct {
+	Code       int
+	Message    string
+	Attributes StatusAttributes
+}
+
+// Temporary reports whether the request may succeed if it is sent again later,
+// for the too many requests (429) and temporary server error (596) status codes
+func (e *ResponseError) Temporary() bool {
+	return temporaryStatus(e.Code)
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%v (%d)", e.Unwrap(), e.Code)
+	}
+	return fmt.Sprintf("%v (%d): %s", e.Unwrap(), e.Code, e.Message)
+}
+
+func (e *ResponseError) Unwrap() error {
+	if err, ok := ConnectionErrors[e.Code]; ok {
+		return err
+	}
+	return UnknownErr
+}
+
+func (e *ResponseError) Is(target error) bool {
+	return target == errorKind(e.Code)
+}
+
+// TransportError is returned when a request failed on the way to or from the endpoint
+type TransportError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	if e.Endpoint == "" {
+		return e.Err.Error()
+	}
+	return e.Endpoint + ": " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TransportError) Is(target error) bool {
+	return target == ErrTransport
+}
+
+// ProtocolError is returned when a response from the endpoint could not be decoded
+type ProtocolError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Endpoint == "" {
+		return e.Err.Error()
+	}
+	return e.Endpoint + ": " + e.Err.Error()
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ProtocolError) Is(target error) bool {
+	return target == ErrProtocol
+}
+
+// penalizesEndpoint reports whether the error says something about the health of the endpoint.
+// Only transport and server faults count against an endpoint, a bad script doesn't.
+func penalizesEndpoint(err error) bool {
+	return errors.Is(err, ErrTransport) || errors.Is(err, ErrServer)
+}
@@ -0,0 +1,1187 @@
+package gremlin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/satori/go.uuid"
+)
+
+const graphBinaryMimeType = "application/vnd.graphbinary-v1.0"
+
+// graphBinaryVersion starts every GraphBinary 1.0 request and response message
+const graphBinaryVersion byte = 0x81
+
+// GraphBinary type codes
+const (
+	gbCustom           byte = 0x00
+	gbInt              byte = 0x01
+	gbLong             byte = 0x02
+	gbString           byte = 0x03
+	gbDate             byte = 0x04
+	gbTimestamp        byte = 0x05
+	gbClass            byte = 0x06
+	gbDouble           byte = 0x07
+	gbFloat            byte = 0x08
+	gbList             byte = 0x09
+	gbMap              byte = 0x0a
+	gbSet              byte = 0x0b
+	gbUUID             byte = 0x0c
+	gbEdge             byte = 0x0d
+	gbPath             byte = 0x0e
+	gbProperty         byte = 0x0f
+	gbVertex           byte = 0x11
+	gbVertexProperty   byte = 0x12
+	gbBarrier          byte = 0x13
+	gbBinding          byte = 0x14
+	gbBytecode         byte = 0x15
+	gbCardinality      byte = 0x16
+	gbColumn           byte = 0x17
+	gbDirection        byte = 0x18
+	gbOperator         byte = 0x19
+	gbOrder            byte = 0x1a
+	gbPick             byte = 0x1b
+	gbPop              byte = 0x1c
+	gbLambda           byte = 0x1d
+	gbP                byte = 0x1e
+	gbScope            byte = 0x1f
+	gbT                byte = 0x20
+	gbTraverser        byte = 0x21
+	gbBigDecimal       byte = 0x22
+	gbBigInteger       byte = 0x23
+	gbByte             byte = 0x24
+	gbByteBuffer       byte = 0x25
+	gbShort            byte = 0x26
+	gbBoolean          byte = 0x27
+	gbTextP            byte = 0x28
+	gbStrategy         byte = 0x29
+	gbBulkSet          byte = 0x2a
+	gbTree             byte = 0x2b
+	gbMetrics          byte = 0x2c
+	gbTraversalMetrics byte = 0x2d
+	gbChar             byte = 0x80
+	gbDuration         byte = 0x81
+	gbInetAddress      byte = 0x82
+	gbInstant          byte = 0x83
+	gbLocalDate        byte = 0x84
+	gbLocalDateTime    byte = 0x85
+	gbLocalTime        byte = 0x86
+	gbMonthDay         byte = 0x87
+	gbOffsetDateTime   byte = 0x88
+	gbOffsetTime       byte = 0x89
+	gbPeriod           byte = 0x8a
+	gbYear             byte = 0x8b
+	gbYearMonth        byte = 0x8c
+	gbZonedDateTime    byte = 0x8d
+	gbZoneOffset       byte = 0x8e
+	gbUnspecifiedNull  byte = 0xfe
+)
+
+// the value flag following the type code of a fully qualified value
+const (
+	gbValue byte = 0x00
+	gbNull  byte = 0x01
+)
+
+// graphBinaryInts are the GraphBinary types of the GraphSON types holding an integer
+var graphBinaryInts = map[string]byte{
+	"g:Int32": gbInt, "g:Int64": gbLong, "gx:Int16": gbShort, "gx:Byte": gbByte, "g:Date": gbDate, "g:Timestamp": gbTimestamp,
+}
+
+// graphBinaryEnums are the GraphBinary types of the tokens that can be sent, by their GraphSON type
+var graphBinaryEnums = map[string]byte{
+	"g:Barrier": gbBarrier, "g:Cardinality": gbCardinality, "g:Column": gbColumn, "g:Direction": gbDirection,
+	"g:Operator": gbOperator, "g:Order": gbOrder, "g:Pick": gbPick, "g:Pop": gbPop, "g:Scope": gbScope, "g:T": gbT,
+}
+
+// graphSONEnums are the GraphSON types of the enums a response may hold
+var graphSONEnums = map[byte]string{
+	gbBarrier: "g:Barrier", gbCardinality: "g:Cardinality", gbColumn: "g:Column", gbDirection: "g:Direction",
+	gbOperator: "g:Operator", gbOrder: "g:Order", gbPick: "g:Pick", gbPop: "g:Pop", gbScope: "g:Scope", gbT: "g:T",
+}
+
+// listPredicates are the predicates whose value is the list of their arguments, rather than a single argument
+var listPredicates = map[string]bool{
+	"between": true, "inside": true, "outside": true, "within": true, "without": true, "and": true, "or": true,
+}
+
+// JanusGraph's types are GraphBinary custom types, sent with their name and id
+const (
+	janusGraphGeoshapeType   = "janusgraph.Geoshape"
+	janusGraphRelationIdType = "janusgraph.RelationIdentifier"
+	janusGraphPType          = "janusgraph.P"
+)
+
+var janusGraphTypeIds = map[string]int32{janusGraphGeoshapeType: 0x1000, janusGraphRelationIdType: 0x1001, janusGraphPType: 0x1002}
+
+// the shapes of JanusGraph's GraphBinary geoshapes, which follow the version of their format
+const (
+	geoshapeFormat byte = 0
+	geoshapePoint  byte = 0
+	geoshapeCircle byte = 1
+	geoshapeBox    byte = 2
+)
+
+// strategyClasses are the java classes of the strategies of this package, GraphBinary sends strategies by class
+var strategyClasses = map[string]string{
+	"PartitionStrategy": "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.PartitionStrategy",
+	"SubgraphStrategy":  "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.SubgraphStrategy",
+	"OptionsStrategy":   "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.OptionsStrategy",
+	"ElementIdStrategy": "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.ElementIdStrategy",
+	"ReadOnlyStrategy":  "org.apache.tinkerpop.gremlin.process.traversal.strategy.verification.ReadOnlyStrategy",
+}
+
+// Sends requests and reads responses as GraphBinary 1.0 rather than GraphSON. Values are typed as they are in
+// GraphSON 3: GraphSONMarshaler implementations are called with GraphSONv3 and results are handed out as GraphSON 3,
+// so Result.Data and DecodeGraphSON work as they do with OptClientSerializer(GraphSONv3). Strategies of your own
+// must return their fully qualified class name from StrategyName to be sent as GraphBinary.
+func OptClientGraphBinary() OptClient {
+	return func(c *Client) error {
+		c.factory.serializer = GraphSONv3
+		c.factory.graphBinary = true
+		return nil
+	}
+}
+
+// serialize serializes a request in the format the client talks to the server
+func (f *EndpointFactory) serialize(req *Request) ([]byte, error) {
+	if f.graphBinary {
+		return graphBinarySerializer(req)
+	}
+	return graphSONSerializer(req, f.serializer)
+}
+
+// mimeType is the mime type responses are requested in
+func (f *EndpointFactory) mimeType() string {
+	if f.graphBinary {
+		return graphBinaryMimeType
+	}
+	return f.serializer.mimeType()
+}
+
+// validateBindings checks that the binding values can be sent in the format the client talks to the server
+func (f *EndpointFactory) validateBindings(bindings Bind) error {
+	if err := ValidateBindings(bindings, f.serializer); err != nil || !f.graphBinary {
+		return err
+	}
+	for name, value := range bindings {
+		if err := new(graphBinaryWriter).write(value); err != nil {
+			return &InvalidBindingError{Name: name, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// graphBinarySerializer serializes the request as a GraphBinary request message, preceded by its mime type
+func graphBinarySerializer(req *Request) ([]byte, error) {
+	id, err := uuid.FromString(req.RequestId)
+	if err != nil {
+		return nil, err
+	}
+	args, err := graphBinaryArgs(req.Args)
+	if err != nil {
+		return nil, err
+	}
+	w := &graphBinaryWriter{}
+	w.WriteByte(byte(len(graphBinaryMimeType)))
+	w.WriteString(graphBinaryMimeType)
+	w.WriteByte(graphBinaryVersion)
+	w.Write(id.Bytes())
+	w.writeString(req.Op)
+	w.writeString(req.Processor)
+	w.writeInt(int32(len(args) / 2))
+	for _, arg := range args {
+		if err := w.writeGraphSON(arg); err != nil {
+			return nil, err
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// graphBinaryArgs returns the set request arguments in their GraphSON 3 form, as a flat list of names and values
+func graphBinaryArgs(args *RequestArgs) ([]interface{}, error) {
+	if args == nil {
+		return nil, nil
+	}
+	bindings, err := graphSONBindings(args.Bindings, GraphSONv3)
+	if err != nil {
+		return nil, err
+	}
+	sideEffect := args.SideEffect
+	if id, ok := sideEffect.(map[string]string); ok {
+		sideEffect = TypedValue{id["@type"], id["@value"]}
+	}
+
+	var kv []interface{}
+	set := func(name string, value interface{}, ok bool) {
+		if ok {
+			kv = append(kv, name, value)
+		}
+	}
+	set("gremlin", args.Bytecode, args.Bytecode != nil)
+	set("gremlin", args.Gremlin, args.Bytecode == nil && args.Gremlin != "")
+	set("session", args.Session, args.Session != "")
+	set("bindings", bindings, bindings != nil)
+	set("language", args.Language, args.Language != "")
+	set("rebindings", args.Rebindings, args.Rebindings != nil)
+	set("sasl", args.Sasl, args.Sasl != "")
+	set("batchSize", int32(args.BatchSize), args.BatchSize != 0)
+	set("manageTransaction", args.ManageTransaction, args.ManageTransaction != nil)
+	set("aliases", args.Aliases, len(args.Aliases) > 0)
+	set("evaluationTimeout", args.EvaluationTimeout, args.EvaluationTimeout != 0)
+	set("materializeProperties", args.MaterializeProperties, args.MaterializeProperties != "")
+	set("userAgent", args.UserAgent, args.UserAgent != "")
+	set("sideEffect", sideEffect, sideEffect != nil)
+	set("sideEffectKey", args.SideEffectKey, args.SideEffectKey != "")
+	set("traceContext", args.TraceContext, len(args.TraceContext) > 0)
+	for i := 1; i < len(kv); i += 2 {
+		if kv[i], err = toGraphSON(kv[i], GraphSONv3); err != nil {
+			return nil, err
+		}
+	}
+	return kv, nil
+}
+
+// graphBinaryResponse reads a GraphBinary response message and returns it as a GraphSON 3 response,
+// the form the transports decode responses from
+func graphBinaryResponse(message []byte) ([]byte, error) {
+	r := &graphBinaryReader{b: message}
+	if version := r.byte(); r.err == nil && version != graphBinaryVersion {
+		return nil, fmt.Errorf("unexpected GraphBinary version 0x%02x", version)
+	}
+	var requestId, statusMessage string
+	if r.byte()&gbNull == 0 {
+		requestId = r.uuid()
+	}
+	code := r.int()
+	if r.byte()&gbNull == 0 {
+		statusMessage = r.string()
+	}
+	attributes := r.readValue(gbMap)
+	meta := r.readValue(gbMap)
+	data := r.read()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return json.Marshal(map[string]interface{}{
+		"requestId": requestId,
+		"status":    map[string]interface{}{"code": code, "message": statusMessage, "attributes": attributes},
+		"result":    map[string]interface{}{"data": data, "meta": meta},
+	})
+}
+
+// graphBinaryWriter writes GraphBinary values. Go values are converted into their GraphSON 3 form first,
+// which is then written as the matching GraphBinary types.
+type graphBinaryWriter struct {
+	bytes.Buffer
+}
+
+func (w *graphBinaryWriter) writeInt(n int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n))
+	w.Write(b[:])
+}
+
+func (w *graphBinaryWriter) writeLong(n int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	w.Write(b[:])
+}
+
+func (w *graphBinaryWriter) writeString(s string) {
+	w.writeInt(int32(len(s)))
+	w.WriteString(s)
+}
+
+// header writes the type code and value flag of a fully qualified value that isn't null
+func (w *graphBinaryWriter) header(typ byte) {
+	w.WriteByte(typ)
+	w.WriteByte(gbValue)
+}
+
+// write writes a Go value fully qualified, with its type code and value flag
+func (w *graphBinaryWriter) write(v interface{}) error {
+	g, err := toGraphSON(v, GraphSONv3)
+	if err != nil {
+		return err
+	}
+	return w.writeGraphSON(g)
+}
+
+// writeGraphSON writes a value in its GraphSON 3 form, which may also be decoded GraphSON
+func (w *graphBinaryWriter) writeGraphSON(g interface{}) error {
+	switch val := g.(type) {
+	case nil:
+		w.WriteByte(gbUnspecifiedNull)
+		w.WriteByte(gbNull)
+	case bool:
+		w.header(gbBoolean)
+		if val {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	case string:
+		w.header(gbString)
+		w.writeString(val)
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			w.header(gbLong)
+			w.writeLong(n)
+			return nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return err
+		}
+		w.header(gbDouble)
+		w.writeLong(int64(math.Float64bits(f)))
+	case TypedValue:
+		return w.writeTyped(val)
+	case []interface{}:
+		w.header(gbList)
+		return w.writeItems(val)
+	case map[string]interface{}:
+		if typ, ok := val["@type"].(string); ok && len(val) == 2 {
+			if value, ok := val["@value"]; ok {
+				return w.writeTyped(TypedValue{typ, value})
+			}
+		}
+		w.header(gbMap)
+		return w.writeStringMap(val)
+	case map[string]string:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[k] = v
+		}
+		return w.writeGraphSON(m)
+	default:
+		// structs and json.Marshalers are written the way they encode as JSON
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var decoded interface{}
+		if err := dec.Decode(&decoded); err != nil {
+			return err
+		}
+		return w.writeGraphSON(decoded)
+	}
+	return nil
+}
+
+func (w *graphBinaryWriter) writeItems(items []interface{}) error {
+	w.writeInt(int32(len(items)))
+	for _, item := range items {
+		if err := w.writeGraphSON(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStringMap writes the length and entries of a map with string keys, sorted by key
+func (w *graphBinaryWriter) writeStringMap(m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w.writeInt(int32(len(keys)))
+	for _, key := range keys {
+		w.writeGraphSON(key)
+		if err := w.writeGraphSON(m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTyped writes a typed GraphSON value as the GraphBinary type that matches its GraphSON type
+func (w *graphBinaryWriter) writeTyped(t TypedValue) error {
+	if code, ok := graphBinaryInts[t.Type]; ok {
+		n, err := graphBinaryInt(t.Value)
+		if err != nil {
+			return err
+		}
+		w.header(code)
+		switch code {
+		case gbInt:
+			w.writeInt(int32(n))
+		case gbShort:
+			w.Write([]byte{byte(n >> 8), byte(n)})
+		case gbByte:
+			w.WriteByte(byte(n))
+		default:
+			w.writeLong(n)
+		}
+		return nil
+	}
+	if code, ok := graphBinaryEnums[t.Type]; ok {
+		name, ok := t.Value.(string)
+		if !ok {
+			return fmt.Errorf("%s expects a string, got %v", t.Type, t.Value)
+		}
+		w.header(code)
+		return w.writeGraphSON(name)
+	}
+
+	switch t.Type {
+	case "g:Double":
+		f, err := graphBinaryFloat(t.Value)
+		if err != nil {
+			return err
+		}
+		w.header(gbDouble)
+		w.writeLong(int64(math.Float64bits(f)))
+	case "g:Float":
+		f, err := graphBinaryFloat(t.Value)
+		if err != nil {
+			return err
+		}
+		w.header(gbFloat)
+		w.writeInt(int32(math.Float32bits(float32(f))))
+	case "g:UUID":
+		s, _ := t.Value.(string)
+		id, err := uuid.FromString(s)
+		if err != nil {
+			return err
+		}
+		w.header(gbUUID)
+		w.Write(id.Bytes())
+	case "gx:ByteBuffer":
+		s, _ := t.Value.(string)
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		w.header(gbByteBuffer)
+		w.writeInt(int32(len(b)))
+		w.Write(b)
+	case "gx:Duration":
+		s, _ := t.Value.(string)
+		seconds, nanos, err := parseISODuration(s)
+		if err != nil {
+			return err
+		}
+		w.header(gbDuration)
+		w.writeLong(seconds)
+		w.writeInt(nanos)
+	case "g:Class":
+		name, _ := t.Value.(string)
+		w.header(gbClass)
+		w.writeString(name)
+	case "g:List", "g:Set":
+		items, _ := t.Value.([]interface{})
+		if t.Type == "g:Set" {
+			w.header(gbSet)
+		} else {
+			w.header(gbList)
+		}
+		return w.writeItems(items)
+	case "g:Map":
+		items, _ := t.Value.([]interface{})
+		if len(items)%2 != 0 {
+			return fmt.Errorf("g:Map holds a key without a value")
+		}
+		w.header(gbMap)
+		w.writeInt(int32(len(items) / 2))
+		for _, item := range items {
+			if err := w.writeGraphSON(item); err != nil {
+				return err
+			}
+		}
+	case "g:Bytecode":
+		return w.writeBytecode(t.Value)
+	case "g:P":
+		return w.writePredicate(gbP, t.Value)
+	case "g:TextP":
+		return w.writePredicate(gbTextP, t.Value)
+	case "janusgraph:JanusGraphP":
+		fields, _ := t.Value.(map[string]interface{})
+		name, _ := fields["predicate"].(string)
+		w.customHeader(janusGraphPType)
+		w.writeString(name)
+		return w.writeGraphSON(fields["value"])
+	case "janusgraph:Geoshape":
+		return w.writeGeoshape(t.Value)
+	default:
+		if name := strings.TrimPrefix(t.Type, "g:"); name != t.Type && strings.HasSuffix(name, "Strategy") {
+			return w.writeStrategy(name, t.Value)
+		}
+		return fmt.Errorf("%s values can't be serialized as GraphBinary", t.Type)
+	}
+	return nil
+}
+
+// writeBytecode writes the steps and then the sources of a g:Bytecode, each instruction as its name and arguments
+func (w *graphBinaryWriter) writeBytecode(value interface{}) error {
+	fields, _ := value.(map[string]interface{})
+	w.header(gbBytecode)
+	for _, key := range []string{"step", "source"} {
+		instructions, err := bytecodeInstructions(fields[key])
+		if err != nil {
+			return err
+		}
+		w.writeInt(int32(len(instructions)))
+		for _, instruction := range instructions {
+			name, _ := instruction[0].(string)
+			w.writeString(name)
+			if err := w.writeItems(instruction[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bytecodeInstructions returns the instructions of a g:Bytecode, as built by Bytecode or decoded from JSON
+func bytecodeInstructions(v interface{}) ([][]interface{}, error) {
+	var instructions [][]interface{}
+	switch list := v.(type) {
+	case nil:
+		return nil, nil
+	case [][]interface{}:
+		instructions = list
+	case []interface{}:
+		for _, item := range list {
+			instruction, _ := item.([]interface{})
+			instructions = append(instructions, instruction)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected g:Bytecode instructions %v", v)
+	}
+	for _, instruction := range instructions {
+		if len(instruction) == 0 {
+			return nil, fmt.Errorf("g:Bytecode holds an instruction without a name")
+		}
+	}
+	return instructions, nil
+}
+
+// writePredicate writes a g:P or g:TextP as its name and arguments, the predicates of and and or are the arguments of those
+func (w *graphBinaryWriter) writePredicate(typ byte, value interface{}) error {
+	fields, _ := value.(map[string]interface{})
+	name, _ := fields["predicate"].(string)
+	args := []interface{}{fields["value"]}
+	if listPredicates[name] {
+		switch list := fields["value"].(type) {
+		case TypedValue:
+			args, _ = list.Value.([]interface{})
+		case map[string]interface{}:
+			args, _ = list["@value"].([]interface{})
+		case []interface{}:
+			args = list
+		}
+	}
+	w.header(typ)
+	w.writeString(name)
+	return w.writeItems(args)
+}
+
+// customHeader writes the type code, name, type id and value flag of a JanusGraph type
+func (w *graphBinaryWriter) customHeader(name string) {
+	w.WriteByte(gbCustom)
+	w.writeString(name)
+	w.writeInt(janusGraphTypeIds[name])
+	w.WriteByte(gbValue)
+}
+
+// writeGeoshape writes the GeoJSON of a janusgraph:Geoshape as JanusGraph's GraphBinary geoshape, latitudes first.
+// Points, circles and the polygons built by Geoshape.Box are supported.
+func (w *graphBinaryWriter) writeGeoshape(geometry interface{}) error {
+	b, err := json.Marshal(geometry)
+	if err != nil {
+		return err
+	}
+	var g struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Radius      float64         `json:"radius"`
+	}
+	if err := json.Unmarshal(b, &g); err != nil {
+		return err
+	}
+	var shape byte
+	var values []float64
+	switch g.Type {
+	case "Point", "Circle":
+		var point []float64
+		if json.Unmarshal(g.Coordinates, &point) != nil || len(point) != 2 {
+			return fmt.Errorf("unexpected coordinates %s", g.Coordinates)
+		}
+		shape, values = geoshapePoint, []float64{point[1], point[0]}
+		if g.Type == "Circle" {
+			shape, values = geoshapeCircle, append(values, g.Radius)
+		}
+	case "Polygon":
+		var rings [][][]float64
+		json.Unmarshal(g.Coordinates, &rings)
+		if !isBox(rings) {
+			return fmt.Errorf("only boxes are supported as GraphBinary polygons")
+		}
+		southWest, northEast := rings[0][0], rings[0][2]
+		shape, values = geoshapeBox, []float64{southWest[1], southWest[0], northEast[1], northEast[0]}
+	default:
+		return fmt.Errorf("%s geoshapes can't be serialized as GraphBinary", g.Type)
+	}
+	w.customHeader(janusGraphGeoshapeType)
+	w.WriteByte(geoshapeFormat)
+	w.WriteByte(shape)
+	for _, v := range values {
+		w.writeLong(int64(math.Float64bits(v)))
+	}
+	return nil
+}
+
+// isBox reports whether the GeoJSON polygon is a box as built by Geoshape.Box, from its south west corner anticlockwise
+func isBox(rings [][][]float64) bool {
+	if len(rings) != 1 || len(rings[0]) != 5 {
+		return false
+	}
+	ring := rings[0]
+	for _, point := range ring {
+		if len(point) != 2 {
+			return false
+		}
+	}
+	sw, ne := ring[0], ring[2]
+	return ring[1][0] == ne[0] && ring[1][1] == sw[1] && ring[3][0] == sw[0] && ring[3][1] == ne[1] && ring[4][0] == sw[0] && ring[4][1] == sw[1]
+}
+
+// writeStrategy writes a strategy as its java class and its configuration
+func (w *graphBinaryWriter) writeStrategy(name string, config interface{}) error {
+	class, ok := strategyClasses[name]
+	if !ok {
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("the class of %s is unknown, its name must be fully qualified to send it as GraphBinary", name)
+		}
+		class = name
+	}
+	m, _ := config.(map[string]interface{})
+	w.header(gbStrategy)
+	w.writeString(class)
+	return w.writeStringMap(m)
+}
+
+// graphBinaryInt reads an integer out of a GraphSON value, as built by toGraphSON or decoded from JSON
+func graphBinaryInt(v interface{}) (int64, error) {
+	if n, ok := v.(int64); ok {
+		return n, nil
+	}
+	return graphSONInt(v)
+}
+
+func graphBinaryFloat(v interface{}) (float64, error) {
+	if f, ok := v.(float64); ok {
+		return f, nil
+	}
+	return graphSONFloat(v)
+}
+
+// graphSONFloatValue returns a float as GraphSON has it, NaN and the infinities as strings
+func graphSONFloatValue(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+var isoDurationPattern = regexp.MustCompile(`^([-+]?)P(?:([-+]?\d+)D)?(?:T(?:([-+]?\d+)H)?(?:([-+]?\d+)M)?(?:([-+]?)(\d+)(?:[.,](\d{0,9}))?S)?)?$`)
+
+// parseISODuration parses a java.time.Duration into its seconds and nanoseconds, the nanoseconds are never negative
+func parseISODuration(s string) (int64, int32, error) {
+	m := isoDurationPattern.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil {
+		return 0, 0, fmt.Errorf("%q is not an ISO-8601 duration", s)
+	}
+	field := func(s string) int64 {
+		n, _ := strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, 64)
+		return n
+	}
+	seconds := field(m[2])*86400 + field(m[3])*3600 + field(m[4])*60
+	nanos := field((m[7] + "000000000")[:9])
+	if m[5] == "-" {
+		seconds, nanos = seconds-field(m[6]), -nanos
+	} else {
+		seconds += field(m[6])
+	}
+	if m[1] == "-" {
+		seconds, nanos = -seconds, -nanos
+	}
+	if nanos < 0 {
+		seconds, nanos = seconds-1, nanos+int64(time.Second)
+	}
+	return seconds, int32(nanos), nil
+}
+
+// formatISODuration formats the seconds and nanoseconds of a java.time.Duration, such as PT-1.5S
+func formatISODuration(seconds int64, nanos int32) string {
+	sign := ""
+	if seconds < 0 {
+		sign = "-"
+		if seconds, nanos = -seconds, -nanos; nanos < 0 {
+			seconds, nanos = seconds-1, nanos+int32(time.Second)
+		}
+	}
+	s := "PT" + sign + strconv.FormatInt(seconds, 10)
+	if fraction := strings.TrimRight(fmt.Sprintf("%09d", nanos), "0"); fraction != "" {
+		s += "." + fraction
+	}
+	return s + "S"
+}
+
+// graphBinaryReader reads GraphBinary values into their GraphSON 3 form, so that results decode with DecodeGraphSON.
+// The first error is kept and stops the reading, values read after it are zero.
+type graphBinaryReader struct {
+	b   []byte
+	err error
+}
+
+func (r *graphBinaryReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *graphBinaryReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *graphBinaryReader) int() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *graphBinaryReader) long() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *graphBinaryReader) double() float64 {
+	return math.Float64frombits(uint64(r.long()))
+}
+
+func (r *graphBinaryReader) string() string {
+	return string(r.next(int(r.int())))
+}
+
+func (r *graphBinaryReader) uuid() string {
+	b := r.next(16)
+	if b == nil {
+		return ""
+	}
+	id, _ := uuid.FromBytes(b)
+	return id.String()
+}
+
+// length reads the length of a collection, which can't be longer than the bytes left
+func (r *graphBinaryReader) length() int {
+	n := int(r.int())
+	if r.err == nil && (n < 0 || n > len(r.b)) {
+		r.err = io.ErrUnexpectedEOF
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
+
+// read reads a fully qualified value
+func (r *graphBinaryReader) read() interface{} {
+	typ := r.byte()
+	if typ == gbCustom && r.err == nil {
+		return r.readCustom()
+	}
+	if r.byte()&gbNull != 0 {
+		return nil
+	}
+	return r.readValue(typ)
+}
+
+// readItems reads the length and items of a list
+func (r *graphBinaryReader) readItems() []interface{} {
+	n := r.length()
+	items := make([]interface{}, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		items = append(items, r.read())
+	}
+	return items
+}
+
+// readValue reads the value of the given type, without its type code and value flag
+func (r *graphBinaryReader) readValue(typ byte) interface{} {
+	if r.err != nil {
+		return nil
+	}
+	if name, ok := graphSONEnums[typ]; ok {
+		return TypedValue{name, r.read()}
+	}
+	switch typ {
+	case gbInt:
+		return TypedValue{"g:Int32", r.int()}
+	case gbLong:
+		return TypedValue{"g:Int64", r.long()}
+	case gbString:
+		return r.string()
+	case gbDate:
+		return TypedValue{"g:Date", r.long()}
+	case gbTimestamp:
+		return TypedValue{"g:Timestamp", r.long()}
+	case gbClass:
+		return TypedValue{"g:Class", r.string()}
+	case gbDouble:
+		return TypedValue{"g:Double", graphSONFloatValue(r.double())}
+	case gbFloat:
+		return TypedValue{"g:Float", graphSONFloatValue(float64(math.Float32frombits(uint32(r.int()))))}
+	case gbList:
+		return TypedValue{"g:List", r.readItems()}
+	case gbSet:
+		return TypedValue{"g:Set", r.readItems()}
+	case gbMap:
+		n := r.length()
+		items := make([]interface{}, 0, 2*n)
+		for i := 0; i < n && r.err == nil; i++ {
+			items = append(items, r.read(), r.read())
+		}
+		return TypedValue{"g:Map", items}
+	case gbUUID:
+		return TypedValue{"g:UUID", r.uuid()}
+	case gbEdge:
+		fields := map[string]interface{}{"id": r.read(), "label": r.string()}
+		fields["inV"], fields["inVLabel"] = r.read(), r.string()
+		fields["outV"], fields["outVLabel"] = r.read(), r.string()
+		r.read() // the parent, always null
+		if properties := elementProperties(r.read(), "key"); properties != nil {
+			fields["properties"] = properties
+		}
+		return TypedValue{"g:Edge", fields}
+	case gbPath:
+		return TypedValue{"g:Path", map[string]interface{}{"labels": r.read(), "objects": r.read()}}
+	case gbProperty:
+		fields := map[string]interface{}{"key": r.string(), "value": r.read()}
+		r.read() // the parent
+		return TypedValue{"g:Property", fields}
+	case gbVertex:
+		fields := map[string]interface{}{"id": r.read(), "label": r.string()}
+		if properties := elementProperties(r.read(), "label"); properties != nil {
+			fields["properties"] = properties
+		}
+		return TypedValue{"g:Vertex", fields}
+	case gbVertexProperty:
+		fields := map[string]interface{}{"id": r.read(), "label": r.string(), "value": r.read()}
+		r.read() // the parent
+		if properties := elementProperties(r.read(), "key"); properties != nil {
+			fields["properties"] = properties
+		}
+		return TypedValue{"g:VertexProperty", fields}
+	case gbBinding:
+		return TypedValue{"g:Binding", map[string]interface{}{"key": r.string(), "value": r.read()}}
+	case gbBytecode:
+		fields := map[string]interface{}{}
+		for _, key := range []string{"step", "source"} {
+			n := r.length()
+			instructions := make([]interface{}, 0, n)
+			for i := 0; i < n && r.err == nil; i++ {
+				instruction := append([]interface{}{r.string()}, r.readItems()...)
+				instructions = append(instructions, instruction)
+			}
+			if n > 0 {
+				fields[key] = instructions
+			}
+		}
+		return TypedValue{"g:Bytecode", fields}
+	case gbP, gbTextP:
+		name, graphSONType := r.string(), "g:P"
+		if typ == gbTextP {
+			graphSONType = "g:TextP"
+		}
+		var value interface{} = TypedValue{"g:List", r.readItems()}
+		if args := value.(TypedValue).Value.([]interface{}); len(args) == 1 && !listPredicates[name] {
+			value = args[0]
+		}
+		return TypedValue{graphSONType, map[string]interface{}{"predicate": name, "value": value}}
+	case gbLambda:
+		fields := map[string]interface{}{"language": r.string(), "script": r.string()}
+		fields["arguments"] = r.int()
+		return TypedValue{"g:Lambda", fields}
+	case gbTraverser:
+		bulk := r.long()
+		return TypedValue{"g:Traverser", map[string]interface{}{"bulk": TypedValue{"g:Int64", bulk}, "value": r.read()}}
+	case gbBigInteger:
+		return TypedValue{"gx:BigInteger", json.Number(r.bigInteger().String())}
+	case gbBigDecimal:
+		scale := int(r.int())
+		return TypedValue{"gx:BigDecimal", json.Number(decimalString(r.bigInteger(), scale))}
+	case gbByte:
+		return TypedValue{"gx:Byte", int8(r.byte())}
+	case gbShort:
+		b := r.next(2)
+		if b == nil {
+			return nil
+		}
+		return TypedValue{"gx:Int16", int16(binary.BigEndian.Uint16(b))}
+	case gbByteBuffer:
+		return TypedValue{"gx:ByteBuffer", base64.StdEncoding.EncodeToString(r.next(r.length()))}
+	case gbBoolean:
+		return r.byte() != 0
+	case gbBulkSet:
+		n := r.length()
+		items := make([]interface{}, 0, 2*n)
+		for i := 0; i < n && r.err == nil; i++ {
+			items = append(items, r.read(), TypedValue{"g:Int64", r.long()})
+		}
+		return TypedValue{"g:BulkSet", items}
+	case gbTree:
+		return r.tree()
+	case gbMetrics:
+		return r.metrics()
+	case gbTraversalMetrics:
+		duration := r.long()
+		metrics := TypedValue{"g:List", r.readItems()}
+		return TypedValue{"g:TraversalMetrics", TypedValue{"g:Map", []interface{}{
+			"dur", TypedValue{"g:Double", float64(duration) / float64(time.Millisecond)}, "metrics", metrics,
+		}}}
+	case gbChar:
+		first := r.byte()
+		n := 1
+		switch {
+		case first&0xf8 == 0xf0:
+			n = 4
+		case first&0xf0 == 0xe0:
+			n = 3
+		case first&0xe0 == 0xc0:
+			n = 2
+		}
+		return TypedValue{"gx:Char", string(append([]byte{first}, r.next(n-1)...))}
+	case gbDuration:
+		seconds := r.long()
+		return TypedValue{"gx:Duration", formatISODuration(seconds, r.int())}
+	case gbInetAddress:
+		return TypedValue{"gx:InetAddress", net.IP(r.next(r.length())).String()}
+	case gbInstant:
+		seconds := r.long()
+		return TypedValue{"gx:Instant", time.Unix(seconds, int64(r.int())).UTC().Format(time.RFC3339Nano)}
+	case gbLocalDate:
+		return TypedValue{"gx:LocalDate", r.localDate()}
+	case gbLocalDateTime:
+		return TypedValue{"gx:LocalDateTime", r.localDate() + "T" + r.localTime()}
+	case gbLocalTime:
+		return TypedValue{"gx:LocalTime", r.localTime()}
+	case gbMonthDay:
+		month := r.byte()
+		return TypedValue{"gx:MonthDay", fmt.Sprintf("--%02d-%02d", month, r.byte())}
+	case gbOffsetDateTime:
+		return TypedValue{"gx:OffsetDateTime", r.localDate() + "T" + r.localTime() + r.zoneOffset()}
+	case gbZonedDateTime:
+		return TypedValue{"gx:ZonedDateTime", r.localDate() + "T" + r.localTime() + r.zoneOffset()}
+	case gbOffsetTime:
+		return TypedValue{"gx:OffsetTime", r.localTime() + r.zoneOffset()}
+	case gbPeriod:
+		years, months := r.int(), r.int()
+		return TypedValue{"gx:Period", fmt.Sprintf("P%dY%dM%dD", years, months, r.int())}
+	case gbYear:
+		return TypedValue{"gx:Year", strconv.Itoa(int(r.int()))}
+	case gbYearMonth:
+		year := r.int()
+		return TypedValue{"gx:YearMonth", fmt.Sprintf("%04d-%02d", year, r.byte())}
+	case gbZoneOffset:
+		return TypedValue{"gx:ZoneOffset", r.zoneOffset()}
+	case gbUnspecifiedNull:
+		return nil
+	}
+	r.err = fmt.Errorf("GraphBinary type 0x%02x is not supported", typ)
+	return nil
+}
+
+// readCustom reads a value of a custom type, after its type code. JanusGraph's types are supported.
+func (r *graphBinaryReader) readCustom() interface{} {
+	name := r.string()
+	r.int() // the type id
+	if r.byte()&gbNull != 0 {
+		return nil
+	}
+	switch name {
+	case janusGraphPType:
+		return TypedValue{"janusgraph:JanusGraphP", map[string]interface{}{"predicate": r.string(), "value": r.read()}}
+	case janusGraphGeoshapeType:
+		return r.geoshape()
+	case janusGraphRelationIdType:
+		// formatted like JanusGraph's RelationIdentifier.toString, as its GraphSON has it
+		outV, typeId, relationId, inV := r.long(), r.long(), r.long(), r.long()
+		id := strconv.FormatInt(relationId, 36) + "-" + strconv.FormatInt(outV, 36) + "-" + strconv.FormatInt(typeId, 36)
+		if inV != 0 {
+			id += "-" + strconv.FormatInt(inV, 36)
+		}
+		return TypedValue{"janusgraph:RelationIdentifier", map[string]interface{}{"relationId": id}}
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf("GraphBinary custom type %s is not supported", name)
+	}
+	return nil
+}
+
+// geoshape reads a JanusGraph geoshape into the GeoJSON of its janusgraph:Geoshape
+func (r *graphBinaryReader) geoshape() interface{} {
+	if format := r.byte(); r.err == nil && format != geoshapeFormat {
+		r.err = fmt.Errorf("unexpected geoshape format %d", format)
+	}
+	var shape Shape
+	switch typ := r.byte(); typ {
+	case geoshapePoint:
+		lat, lon := r.double(), r.double()
+		shape = Geoshape.Point(lat, lon)
+	case geoshapeCircle:
+		lat, lon, radius := r.double(), r.double(), r.double()
+		shape = Geoshape.Circle(lat, lon, radius)
+	case geoshapeBox:
+		swLat, swLon, neLat, neLon := r.double(), r.double(), r.double(), r.double()
+		shape = Geoshape.Box(swLat, swLon, neLat, neLon)
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("geoshapes of type %d are not supported", typ)
+		}
+	}
+	if r.err != nil {
+		return nil
+	}
+	return TypedValue{"janusgraph:Geoshape", shape.geometry}
+}
+
+// elementProperties keys the list of properties GraphBinary sends with an element the way GraphSON 3 does:
+// vertex properties are grouped in lists by their label, other properties are keyed by their key
+func elementProperties(properties interface{}, key string) map[string]interface{} {
+	list, _ := properties.(TypedValue)
+	items, _ := list.Value.([]interface{})
+	if len(items) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		p, _ := item.(TypedValue)
+		fields, _ := p.Value.(map[string]interface{})
+		name, _ := fields[key].(string)
+		if key == "label" {
+			grouped, _ := m[name].([]interface{})
+			m[name] = append(grouped, item)
+		} else {
+			m[name] = item
+		}
+	}
+	return m
+}
+
+// bigInteger reads the length and two's complement bytes of a java.math.BigInteger
+func (r *graphBinaryReader) bigInteger() *big.Int {
+	b := r.next(r.length())
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
+
+// decimalString formats the unscaled value and scale of a java.math.BigDecimal as a JSON number
+func decimalString(unscaled *big.Int, scale int) string {
+	s := new(big.Int).Abs(unscaled).String()
+	switch {
+	case scale < 0:
+		s += "E" + strconv.Itoa(-scale)
+	case scale > 0:
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (r *graphBinaryReader) tree() interface{} {
+	n := r.length()
+	items := make([]interface{}, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		key := r.read()
+		items = append(items, map[string]interface{}{"key": key, "value": r.tree()})
+	}
+	return TypedValue{"g:Tree", items}
+}
+
+// metrics reads the metrics of a step into the g:Metrics of GraphSON 3, with the duration in milliseconds
+func (r *graphBinaryReader) metrics() interface{} {
+	id, name := r.string(), r.string()
+	duration := r.long()
+	counts, annotations := r.readValue(gbMap), r.readValue(gbMap)
+	nested := TypedValue{"g:List", r.readItems()}
+	return TypedValue{"g:Metrics", TypedValue{"g:Map", []interface{}{
+		"dur", TypedValue{"g:Double", float64(duration) / float64(time.Millisecond)},
+		"counts", counts, "name", name, "annotations", annotations, "id", id, "metrics", nested,
+	}}}
+}
+
+func (r *graphBinaryReader) localDate() string {
+	year := r.int()
+	month := r.byte()
+	return fmt.Sprintf("%04d-%02d-%02d", year, month, r.byte())
+}
+
+// localTime reads the nanoseconds of the day of a java.time.LocalTime
+func (r *graphBinaryReader) localTime() string {
+	d := time.Duration(r.long())
+	s := fmt.Sprintf("%02d:%02d:%02d", int(d/time.Hour), int(d/time.Minute%60), int(d/time.Second%60))
+	if fraction := strings.TrimRight(fmt.Sprintf("%09d", d%time.Second), "0"); fraction != "" {
+		s += "." + fraction
+	}
+	return s
+}
+
+// zoneOffset reads the seconds of a java.time.ZoneOffset, formatted like +01:00 or Z
+func (r *graphBinaryReader) zoneOffset() string {
+	seconds := int(r.int())
+	if seconds == 0 {
+		return "Z"
+	}
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	s := fmt.Sprintf("%s%02d:%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf(":%02d", seconds%60)
+	}
+	return s
+}
@@ -0,0 +1,314 @@
+package gremlin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// gb concatenates the parts of a GraphBinary message: bytes as they are, strings with their length,
+// and ints and longs big endian
+func gb(parts ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, part := range parts {
+		switch p := part.(type) {
+		case string:
+			binary.Write(&b, binary.BigEndian, int32(len(p)))
+			b.WriteString(p)
+		case []byte:
+			b.Write(p)
+		default:
+			binary.Write(&b, binary.BigEndian, p)
+		}
+	}
+	return b.Bytes()
+}
+
+// readTestGraphBinaryRequest reads a GraphBinary request message, the arguments are kept in their GraphSON 3 form
+func readTestGraphBinaryRequest(b []byte) (*testRequest, error) {
+	r := &graphBinaryReader{b: b}
+	if r.byte() != graphBinaryVersion {
+		return nil, errors.New("not a GraphBinary request")
+	}
+	req := &testRequest{Args: map[string]interface{}{}}
+	req.RequestId.Value = r.uuid()
+	req.Op, req.Processor = r.string(), r.string()
+	args, _ := r.readValue(gbMap).(TypedValue)
+	if r.err != nil {
+		return nil, r.err
+	}
+	items, _ := args.Value.([]interface{})
+	for i := 0; i+1 < len(items); i += 2 {
+		b, err := json.Marshal(items[i+1])
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		json.Unmarshal(b, &v)
+		req.Args[items[i].(string)] = v
+	}
+	return req, nil
+}
+
+// testGraphBinaryResponse writes a response as a GraphBinary response message
+func testGraphBinaryResponse(res *Response) []byte {
+	w := &graphBinaryWriter{}
+	w.WriteByte(graphBinaryVersion)
+	w.WriteByte(gbValue)
+	w.Write(uuid.FromStringOrNil(res.RequestId).Bytes())
+	w.writeInt(int32(res.Status.Code))
+	w.WriteByte(gbValue)
+	w.writeString(res.Status.Message)
+	w.writeStringMap(res.Status.Attributes)
+	var data interface{}
+	if res.Result != nil {
+		w.writeStringMap(res.Result.Meta)
+		if len(res.Result.Data) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(res.Result.Data))
+			dec.UseNumber()
+			dec.Decode(&data)
+		}
+	} else {
+		w.writeInt(0)
+	}
+	w.writeGraphSON(data)
+	return w.Bytes()
+}
+
+func TestGraphBinaryRequestMessage(t *testing.T) {
+	id := "41d2e28a-20a4-4ab0-b379-d810dede3786"
+	req := &Request{RequestId: id, Op: "eval", Args: &RequestArgs{
+		Gremlin:           "g.V(x)",
+		Bindings:          Bind{"x": 1},
+		ManageTransaction: new(bool),
+	}}
+	b, err := graphBinarySerializer(req)
+	assert.Empty(t, err)
+	assert.Equal(t, gb(
+		byte(32), []byte("application/vnd.graphbinary-v1.0"), graphBinaryVersion,
+		uuid.Must(uuid.FromString(id)).Bytes(), "eval", "", int32(3),
+		gbString, gbValue, "gremlin", gbString, gbValue, "g.V(x)",
+		gbString, gbValue, "bindings", gbMap, gbValue, int32(1), gbString, gbValue, "x", gbInt, gbValue, int32(1),
+		gbString, gbValue, "manageTransaction", gbBoolean, gbValue, byte(0),
+	), b)
+
+	// values without a GraphBinary type are rejected, by the client before anything is sent
+	_, err = graphBinarySerializer(&Request{RequestId: id, Op: "eval", Args: &RequestArgs{Bindings: Bind{"x": point{1, 2}}}})
+	assert.Error(t, err)
+	factory := &EndpointFactory{serializer: GraphSONv3, graphBinary: true}
+	assert.Equal(t, &InvalidBindingError{Name: "x", Reason: "example:Point values can't be serialized as GraphBinary"},
+		factory.validateBindings(Bind{"x": point{1, 2}}))
+}
+
+func TestGraphBinaryBytecode(t *testing.T) {
+	g := NewGraphTraversalSource(nil, "")
+	traversal := g.WithStrategies(ReadOnlyStrategy{}).V().HasLabel("person").Limit(int64(2)).Order().By("age", Scope.Local)
+	w := &graphBinaryWriter{}
+	assert.Empty(t, w.write(traversal))
+	assert.Equal(t, gb(
+		gbBytecode, gbValue, int32(5),
+		"V", int32(0),
+		"hasLabel", int32(1), gbString, gbValue, "person",
+		"limit", int32(1), gbLong, gbValue, int64(2),
+		"order", int32(0),
+		"by", int32(2), gbString, gbValue, "age", gbScope, gbValue, gbString, gbValue, "local",
+		int32(1),
+		"withStrategies", int32(1), gbStrategy, gbValue,
+		"org.apache.tinkerpop.gremlin.process.traversal.strategy.verification.ReadOnlyStrategy", int32(0),
+	), w.Bytes())
+
+	for _, c := range []struct {
+		value interface{}
+		want  []byte
+	}{
+		{1500 * time.Millisecond, gb(gbDuration, gbValue, int64(1), int32(500000000))},
+		{[]byte{1, 2}, gb(gbByteBuffer, gbValue, int32(2), []byte{1, 2})},
+		{float32(0.5), gb(gbFloat, gbValue, float32(0.5))},
+		{Set{"a"}, gb(gbSet, gbValue, int32(1), gbString, gbValue, "a")},
+		{map[int64]string{1: "a"}, gb(gbMap, gbValue, int32(1), gbLong, gbValue, int64(1), gbString, gbValue, "a")},
+		{struct{ A int }{1}, gb(gbMap, gbValue, int32(1), gbString, gbValue, "A", gbLong, gbValue, int64(1))},
+		{(*int)(nil), gb(gbUnspecifiedNull, gbNull)},
+	} {
+		w.Reset()
+		assert.Empty(t, w.write(c.value), c.value)
+		assert.Equal(t, c.want, w.Bytes(), c.value)
+	}
+
+	w.Reset()
+	assert.Error(t, w.write(g.WithStrategies(customStrategy{}).V()))
+}
+
+// customStrategy is a strategy of the server that isn't known by this package
+type customStrategy struct{}
+
+func (customStrategy) StrategyName() string { return "CustomStrategy" }
+
+func (customStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return strategyGraphSON("CustomStrategy", nil, version)
+}
+
+func TestGraphBinaryResponse(t *testing.T) {
+	id := uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786"))
+	long := func(n int64) []byte { return gb(gbLong, gbValue, n) }
+	null := gb(gbUnspecifiedNull, gbNull)
+	message := gb(graphBinaryVersion, gbValue, id.Bytes(), int32(200), gbValue, "",
+		int32(1), gbString, gbValue, "host", gbString, gbValue, "/127.0.0.1",
+		int32(0),
+		gbList, gbValue, int32(16),
+		gbInt, gbValue, int32(1),
+		long(2),
+		gbDouble, gbValue, 0.5,
+		gbString, gbValue, "marko",
+		gbUUID, gbValue, id.Bytes(),
+		gbBoolean, gbValue, byte(1),
+		null,
+		gbVertex, gbValue, long(1), "person",
+		gbList, gbValue, int32(1), gbVertexProperty, gbValue, long(10), "name", gbString, gbValue, "marko", null, null,
+		gbEdge, gbValue, long(7), "knows", long(2), "person", long(1), "person", null,
+		gbList, gbValue, int32(1), gbProperty, gbValue, "weight", gbDouble, gbValue, 0.5, null,
+		gbTraverser, gbValue, int64(3), gbString, gbValue, "x",
+		gbMap, gbValue, int32(1), gbString, gbValue, "a", gbInt, gbValue, int32(1),
+		gbSet, gbValue, int32(1), gbString, gbValue, "s",
+		gbT, gbValue, gbString, gbValue, "id",
+		gbPath, gbValue, gbList, gbValue, int32(1), gbSet, gbValue, int32(1), gbString, gbValue, "a",
+		gbList, gbValue, int32(1), long(1),
+		gbDuration, gbValue, int64(-2), int32(500000000),
+		gbBulkSet, gbValue, int32(1), gbString, gbValue, "b", int64(2),
+	)
+	b, err := graphBinaryResponse(message)
+	if !assert.Empty(t, err) {
+		return
+	}
+	var res Response
+	assert.Empty(t, json.Unmarshal(b, &res))
+	assert.Equal(t, id.String(), res.RequestId)
+	assert.Equal(t, StatusSuccess, res.Status.Code)
+	assert.Equal(t, "/127.0.0.1", res.Status.Attributes["host"])
+
+	values, err := (&Result{Data: res.Result.Data}).Values()
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{
+		int32(1), int64(2), 0.5, "marko", id, true, nil,
+		Vertex{Id: int64(1), Label: "person", Properties: map[string][]VertexProperty{
+			"name": {{Id: int64(10), Label: "name", Value: "marko"}},
+		}},
+		Edge{Id: int64(7), Label: "knows", InV: int64(2), InVLabel: "person", OutV: int64(1), OutVLabel: "person",
+			Properties: map[string]Property{"weight": {Key: "weight", Value: 0.5}}},
+		"x", "x", "x",
+		map[string]interface{}{"a": int32(1)},
+		Set{"s"},
+		"id",
+		Path{Labels: [][]string{{"a"}}, Objects: []interface{}{int64(1)}},
+		TypedValue{"gx:Duration", "PT-1.5S"},
+		[]interface{}{"b", "b"},
+	}, values)
+
+	_, err = graphBinaryResponse(message[:len(message)-3])
+	assert.Error(t, err)
+	_, err = graphBinaryResponse(gb(graphBinaryVersion, gbNull, int32(200), gbNull, int32(0), int32(0), byte(0x10), gbValue))
+	assert.Error(t, err)
+}
+
+func TestISODuration(t *testing.T) {
+	for s, want := range map[string][2]int64{
+		"PT1.5S":         {1, 500000000},
+		"PT-1.5S":        {-2, 500000000},
+		"-PT1M":          {-60, 0},
+		"P1DT2H3M4S":     {93784, 0},
+		"PT0.000000001S": {0, 1},
+	} {
+		seconds, nanos, err := parseISODuration(s)
+		assert.Empty(t, err, s)
+		assert.Equal(t, want, [2]int64{seconds, int64(nanos)}, s)
+	}
+	_, _, err := parseISODuration("1.5s")
+	assert.Error(t, err)
+	assert.Equal(t, "PT-1.5S", formatISODuration(-2, 500000000))
+	assert.Equal(t, "PT90S", formatISODuration(90, 0))
+}
+
+func TestGraphBinaryClient(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		if req.Args["gremlin"] == "fail" {
+			res := testResponse(req, StatusServerError, "")
+			res.Status.Message = "boom"
+			return []*Response{res}
+		}
+		return []*Response{
+			testResponse(req, StatusPartialContent, `[{"@type":"g:Int32","@value":1}]`),
+			testResponse(req, StatusSuccess, `[{"@type":"g:Int64","@value":2}, "three"]`),
+		}
+	})
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientGraphBinary())
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	res, err := cl.Do(context.Background(), Query("g.V(x)").Bindings(Bind{"x": int64(1)}).BatchSize(1))
+	if !assert.Empty(t, err) {
+		return
+	}
+	values, err := res.Values()
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{int32(1), int64(2), "three"}, values)
+	assert.Len(t, res.Batches, 2)
+
+	received := server.received()
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, "eval", received[0].Op)
+		assert.Equal(t, "g.V(x)", received[0].Args["gremlin"])
+		assert.Equal(t, map[string]interface{}{"@type": "g:Int32", "@value": 1.0}, received[0].Args["batchSize"])
+		assert.Equal(t, map[string]interface{}{"@type": "g:Map", "@value": []interface{}{"x", map[string]interface{}{"@type": "g:Int64", "@value": 1.0}}},
+			received[0].Args["bindings"])
+	}
+
+	_, err = cl.Do(context.Background(), Query("fail"))
+	assert.Equal(t, &ResponseError{Code: StatusServerError, Message: "boom", Attributes: StatusAttributes{}}, err)
+
+	// bindings are checked before anything is sent
+	_, err = cl.Do(context.Background(), Query("g.V(x)").Bindings(Bind{"x": point{1, 2}}))
+	assert.IsType(t, &InvalidBindingError{}, err)
+	assert.Len(t, server.received(), 2)
+
+	// the last serializer option wins
+	cl2, err := NewClientWithOptions(server.Url, OptClientGraphBinary(), OptClientSerializer(GraphSONv3))
+	if assert.Empty(t, err) {
+		assert.Equal(t, "application/vnd.gremlin-v3.0+json", cl2.factory.mimeType())
+		cl2.Close()
+	}
+}
+
+func TestGraphBinaryHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != graphBinaryMimeType {
+			http.Error(w, "unexpected Accept header", http.StatusBadRequest)
+			return
+		}
+		req := &testRequest{}
+		w.Write(testGraphBinaryResponse(testResponse(req, StatusSuccess, `[{"@type":"g:Int64","@value":9}]`)))
+	}))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.URL, OptClientGraphBinary())
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	res, err := cl.Do(context.Background(), Query("n * n").Bindings(Bind{"n": 3}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	values, err := res.Values()
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{int64(9)}, values)
+}
@@ -0,0 +1,181 @@
+package graphio
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+type ExportOptions struct {
+	Filter
+	// PageSize is the number of vertices read per request, 1000 by default
+	PageSize int
+}
+
+// ExportStats counts the elements written
+type ExportStats struct {
+	Vertices int64
+	Edges    int64
+}
+
+// pageScript reads a page of vertices with their properties and out edges. Pages are in id order and
+// start after the last vertex of the previous page, so vertices added or removed during the export
+// don't shift the pages.
+const pageScript = `t = g.V()
+edges = __.outE(*edgeLabels)
+if (vertexLabels) {
+  t = t.hasLabel(*vertexLabels)
+  edges = edges.where(__.inV().hasLabel(*vertexLabels))
+}
+if (paged) {
+  t = t.has(T.id, gt(last))
+}
+t.order().by(T.id).limit(pageSize).project('id', 'label', 'properties', 'outE').
+  by(T.id).by(T.label).
+  by(__.properties(*keys).group().by(__.key()).by(__.project('id', 'value').by(T.id).by(__.value()).fold())).
+  by(edges.project('id', 'label', 'inV', 'properties').by(T.id).by(T.label).by(__.inV().id()).by(__.valueMap(*keys)).fold())`
+
+// keysScript reads the property keys of the vertices or edges with a value of each, to declare them in GraphML
+const keysScript = `t = edges ? g.E() : g.V()
+labels = edges ? edgeLabels : vertexLabels
+if (labels) {
+  t = t.hasLabel(*labels)
+}
+t.properties(*keys).group().by(__.key()).by(__.value().limit(1).fold())`
+
+// writer writes the vertices of an export in a file format
+type writer interface {
+	vertex(v *vertex) error
+	close() error
+}
+
+// Export writes the vertices and edges selected by the filter to w. Vertices are read a page at a time in
+// id order, which the server sorts for every page, so the ids must be comparable. Changes made during the
+// export are only seen if they come after the current page. GraphML exports read the graph's property keys
+// first, as GraphML declares them before the elements.
+func Export(ctx context.Context, client *gremlin.Client, w io.Writer, format Format, opts ExportOptions) (*ExportStats, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 1000
+	}
+	bindings := gremlin.Bind{
+		"vertexLabels": bindingList(opts.VertexLabels),
+		"edgeLabels":   bindingList(opts.EdgeLabels),
+		"keys":         bindingList(opts.Properties),
+	}
+
+	var out writer
+	switch format {
+	case GraphML:
+		vertexKeys, err := propertyKeys(ctx, client, bindings, false)
+		if err != nil {
+			return nil, err
+		}
+		edgeKeys, err := propertyKeys(ctx, client, bindings, true)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = newGraphMLWriter(w, vertexKeys, edgeKeys); err != nil {
+			return nil, err
+		}
+	case GraphSON:
+		out = newGraphSONWriter(w)
+	default:
+		return nil, UnknownFormatError
+	}
+
+	stats := &ExportStats{}
+	var last interface{} = ""
+	for paged := false; ; paged = true {
+		page := gremlin.Bind{"pageSize": opts.PageSize, "paged": paged, "last": last}
+		for k, v := range bindings {
+			page[k] = v
+		}
+		res, err := client.Do(ctx, gremlin.Query(pageScript).Bindings(page))
+		if err != nil {
+			return stats, err
+		}
+		values, err := res.Values()
+		if err != nil {
+			return stats, err
+		}
+		for _, value := range values {
+			v, err := pagedVertex(value)
+			if err != nil {
+				return stats, err
+			}
+			if err := out.vertex(v); err != nil {
+				return stats, err
+			}
+			stats.Vertices++
+			stats.Edges += int64(len(v.outE))
+			last = v.id
+		}
+		if len(values) < opts.PageSize {
+			break
+		}
+	}
+	return stats, out.close()
+}
+
+// propertyKeys returns the property keys of the vertices or edges with a value of each
+func propertyKeys(ctx context.Context, client *gremlin.Client, bindings gremlin.Bind, edges bool) (map[string]interface{}, error) {
+	b := gremlin.Bind{"edges": edges}
+	for k, v := range bindings {
+		b[k] = v
+	}
+	res, err := client.Do(ctx, gremlin.Query(keysScript).Bindings(b))
+	if err != nil {
+		return nil, err
+	}
+	values, err := res.Values()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, value := range values {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected property keys %v", value)
+		}
+		for key, first := range m {
+			if list, ok := first.([]interface{}); ok && len(list) > 0 {
+				keys[key] = list[0]
+			}
+		}
+	}
+	return keys, nil
+}
+
+// pagedVertex reads a vertex from the result of pageScript
+func pagedVertex(value interface{}) (*vertex, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected vertex %v", value)
+	}
+	label, _ := m["label"].(string)
+	v := &vertex{id: m["id"], label: label, properties: map[string][]vertexProperty{}}
+	properties, _ := m["properties"].(map[string]interface{})
+	for key, list := range properties {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			p, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unexpected property %v", item)
+			}
+			v.properties[key] = append(v.properties[key], vertexProperty{id: p["id"], value: p["value"]})
+		}
+	}
+	edges, _ := m["outE"].([]interface{})
+	for _, item := range edges {
+		e, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected edge %v", item)
+		}
+		label, _ := e["label"].(string)
+		properties, _ := e["properties"].(map[string]interface{})
+		v.outE = append(v.outE, edge{id: e["id"], label: label, outV: v.id, inV: e["inV"], properties: properties})
+	}
+	return v, nil
+}
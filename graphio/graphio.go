@@ -0,0 +1,102 @@
+// Package graphio exports graphs to GraphML and GraphSON files and imports them again, for example to
+// snapshot a subgraph for a bug report or to seed a test database. Both directions stream the elements:
+// exports read the vertices a page at a time and imports load them with a gremlin.BulkLoader.
+//
+//	stats, err := graphio.Export(ctx, client, f, graphio.GraphSON, graphio.ExportOptions{
+//		Filter: graphio.Filter{VertexLabels: []string{"person"}, EdgeLabels: []string{"knows"}},
+//	})
+//	stats, err := graphio.Import(ctx, client, f, graphio.GraphSON, graphio.ImportOptions{IdProperty: "importId"})
+//
+// GraphSON files are in the adjacency list format written by TinkerPop's GraphSONWriter, one vertex with
+// its out edges per line. GraphML has no multi-properties or meta-properties, and imports keep only the
+// first value of a multi-property from either format.
+package graphio
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+// Format is the file format of an export or import
+type Format string
+
+const (
+	GraphML  Format = "graphml"
+	GraphSON Format = "graphson"
+)
+
+var UnknownFormatError = errors.New("unknown format, use GraphML or GraphSON")
+
+// Filter selects the elements and properties that are exported or imported. Empty lists select everything.
+// Edges are only kept when both their vertices are.
+type Filter struct {
+	VertexLabels []string
+	EdgeLabels   []string
+	// Properties are the keys of the vertex and edge properties that are kept
+	Properties []string
+}
+
+func (f Filter) vertex(label string) bool {
+	return selected(f.VertexLabels, label)
+}
+
+func (f Filter) edge(label string) bool {
+	return selected(f.EdgeLabels, label)
+}
+
+func (f Filter) property(key string) bool {
+	return selected(f.Properties, key)
+}
+
+func selected(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingList is sent instead of a nil slice, so scripts can spread it
+func bindingList(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
+
+// vertex is a vertex as read from the graph or a file
+type vertex struct {
+	id         interface{}
+	label      string
+	properties map[string][]vertexProperty
+	outE       []edge
+}
+
+type vertexProperty struct {
+	id    interface{}
+	value interface{}
+}
+
+type edge struct {
+	id         interface{}
+	label      string
+	outV, inV  interface{}
+	properties map[string]interface{}
+}
+
+// idKey identifies an id in the import's id map, ids of different types don't collide
+func idKey(id interface{}) (string, error) {
+	b, err := gremlin.EncodeGraphSON(id, gremlin.GraphSONv3)
+	return string(b), err
+}
+
+// graphSON encodes a value as GraphSON 3, the version of the files written
+func graphSON(v interface{}) (json.RawMessage, error) {
+	return gremlin.EncodeGraphSON(v, gremlin.GraphSONv3)
+}
@@ -0,0 +1,243 @@
+package graphio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// vertices are the vertices of the graph the fake server exports in id order, marko knows vadas
+var vertices = []struct {
+	id   int64
+	json string
+}{
+	{1, `{"id": {"@type": "g:Int64", "@value": 1}, "label": "person",
+	"properties": {
+		"name": [{"id": {"@type": "g:Int64", "@value": 10}, "value": "marko"}],
+		"age": [{"id": {"@type": "g:Int64", "@value": 11}, "value": {"@type": "g:Int32", "@value": 29}}]},
+	"outE": [{"id": {"@type": "g:Int64", "@value": 7}, "label": "knows", "inV": {"@type": "g:Int64", "@value": 2},
+		"properties": {"weight": {"@type": "g:Double", "@value": 0.5}}}]}`},
+	{2, `{"id": {"@type": "g:Int64", "@value": 2}, "label": "person",
+	"properties": {"name": [{"id": {"@type": "g:Int64", "@value": 12}, "value": "vadas"}]},
+	"outE": []}`},
+}
+
+// fakeGraph answers the export scripts with pages of vertices, and bulk load scripts by giving the rows new ids
+type fakeGraph struct {
+	mu       sync.Mutex
+	bindings []map[string]interface{}
+	rows     []map[string]interface{} // rows of the bulk load scripts
+	nextId   int64
+}
+
+func (g *fakeGraph) respond(script string, bindings map[string]interface{}) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.bindings = append(g.bindings, bindings)
+	switch {
+	case script == pageScript:
+		// the vertices after the last one of the previous page
+		var page []string
+		for _, v := range vertices {
+			if bindings["paged"] == true && v.id <= bindings["last"].(int64) {
+				continue
+			}
+			if len(page) < int(bindings["pageSize"].(int32)) {
+				page = append(page, v.json)
+			}
+		}
+		return `[` + strings.Join(page, ",") + `]`
+	case script == keysScript:
+		if bindings["edges"] == true {
+			return `[{"weight": [{"@type": "g:Double", "@value": 0.5}]}]`
+		}
+		return `[{"name": ["marko"], "age": [{"@type": "g:Int32", "@value": 29}]}]`
+	case strings.Contains(script, "rows.eachWithIndex"):
+		rows, _ := bindings["rows"].([]interface{})
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			g.rows = append(g.rows, row.(map[string]interface{}))
+			g.nextId++
+			ids[i] = fmt.Sprintf(`{"@type": "g:Int64", "@value": %d}`, 100+g.nextId)
+		}
+		// the server sends the list returned by the script as one result per item
+		return `[[` + strings.Join(ids, ",") + `], []]`
+	}
+	return `[]`
+}
+
+func newServer(graph *fakeGraph) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// skip the mime type header
+			var req struct {
+				RequestId struct {
+					Value string `json:"@value"`
+				} `json:"requestId"`
+				Args struct {
+					Gremlin  string          `json:"gremlin"`
+					Bindings json.RawMessage `json:"bindings"`
+				} `json:"args"`
+			}
+			json.Unmarshal(msg[int(msg[0])+1:], &req)
+			bindings, _ := gremlin.DecodeGraphSON(req.Args.Bindings)
+			b, _ := bindings.(map[string]interface{})
+			ws.WriteJSON(gremlin.Response{
+				RequestId: req.RequestId.Value,
+				Status:    &gremlin.ResponseStatus{Code: gremlin.StatusSuccess},
+				Result:    &gremlin.ResponseResult{Data: json.RawMessage(graph.respond(req.Args.Gremlin, b))},
+			})
+		}
+	}))
+}
+
+func newClient(t *testing.T, server *httptest.Server) *gremlin.Client {
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+const exportedGraphML = `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="labelV" for="node" attr.name="labelV" attr.type="string"/>
+  <key id="age" for="node" attr.name="age" attr.type="int"/>
+  <key id="name" for="node" attr.name="name" attr.type="string"/>
+  <key id="labelE" for="edge" attr.name="labelE" attr.type="string"/>
+  <key id="weight" for="edge" attr.name="weight" attr.type="double"/>
+  <graph id="G" edgedefault="directed">
+    <node id="1"><data key="labelV">person</data><data key="age">29</data><data key="name">marko</data></node>
+    <edge id="7" source="1" target="2"><data key="labelE">knows</data><data key="weight">0.5</data></edge>
+    <node id="2"><data key="labelV">person</data><data key="name">vadas</data></node>
+  </graph>
+</graphml>
+`
+
+const exportedGraphSON = `{"id":{"@type":"g:Int64","@value":1},"label":"person","outE":{"knows":[{"id":{"@type":"g:Int64","@value":7},"inV":{"@type":"g:Int64","@value":2},"properties":{"weight":{"@type":"g:Double","@value":0.5}}}]},"properties":{"age":[{"id":{"@type":"g:Int64","@value":11},"value":{"@type":"g:Int32","@value":29}}],"name":[{"id":{"@type":"g:Int64","@value":10},"value":"marko"}]}}
+{"id":{"@type":"g:Int64","@value":2},"label":"person","properties":{"name":[{"id":{"@type":"g:Int64","@value":12},"value":"vadas"}]}}
+`
+
+func TestExport(t *testing.T) {
+	graph := &fakeGraph{}
+	server := newServer(graph)
+	defer server.Close()
+	client := newClient(t, server)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	stats, err := Export(ctx, client, &out, GraphML, ExportOptions{PageSize: 2, Filter: Filter{VertexLabels: []string{"person"}}})
+	assert.Empty(t, err)
+	assert.Equal(t, &ExportStats{Vertices: 2, Edges: 1}, stats)
+	assert.Equal(t, exportedGraphML, out.String())
+	// the keys of vertices and edges, then pages until one isn't full
+	if assert.Len(t, graph.bindings, 4) {
+		assert.Equal(t, false, graph.bindings[0]["edges"])
+		assert.Equal(t, true, graph.bindings[1]["edges"])
+		assert.Equal(t, []interface{}{"person"}, graph.bindings[2]["vertexLabels"])
+		assert.Equal(t, []interface{}{}, graph.bindings[2]["edgeLabels"])
+		assert.Equal(t, false, graph.bindings[2]["paged"])
+		assert.Equal(t, true, graph.bindings[3]["paged"])
+		assert.Equal(t, int64(2), graph.bindings[3]["last"])
+	}
+
+	// pages of one vertex, each starting after the last one
+	graph.bindings = nil
+	out.Reset()
+	stats, err = Export(ctx, client, &out, GraphSON, ExportOptions{PageSize: 1})
+	assert.Empty(t, err)
+	assert.Equal(t, &ExportStats{Vertices: 2, Edges: 1}, stats)
+	assert.Equal(t, exportedGraphSON, out.String())
+	if assert.Len(t, graph.bindings, 3) {
+		assert.Equal(t, int64(1), graph.bindings[1]["last"])
+		assert.Equal(t, int64(2), graph.bindings[2]["last"])
+	}
+
+	_, err = Export(ctx, client, &out, Format("csv"), ExportOptions{})
+	assert.Equal(t, UnknownFormatError, err)
+}
+
+func TestExportScripts(t *testing.T) {
+	// edge properties aren't elements, their keys are read with key() rather than T.key
+	assert.Contains(t, keysScript, "group().by(__.key())")
+	assert.Contains(t, pageScript, "group().by(__.key())")
+	assert.NotContains(t, keysScript+pageScript, "T.key")
+	// pages are read in id order after the last vertex of the previous one
+	assert.Contains(t, pageScript, "t.has(T.id, gt(last))")
+	assert.Contains(t, pageScript, "t.order().by(T.id).limit(pageSize)")
+	assert.NotContains(t, pageScript, "range(")
+}
+
+func TestImport(t *testing.T) {
+	for _, c := range []struct {
+		format Format
+		file   string
+	}{
+		{GraphML, exportedGraphML},
+		{GraphSON, exportedGraphSON},
+	} {
+		graph := &fakeGraph{}
+		server := newServer(graph)
+		client := newClient(t, server)
+
+		stats, err := Import(context.Background(), client, strings.NewReader(c.file), c.format, ImportOptions{})
+		assert.Empty(t, err, c.format)
+		assert.Equal(t, int64(2), stats.Vertices.Loaded, c.format)
+		assert.Equal(t, int64(1), stats.Edges.Loaded, c.format)
+		if assert.Len(t, graph.rows, 3, c.format) {
+			assert.Equal(t, map[string]interface{}{"label": "person", "properties": map[string]interface{}{"name": "marko", "age": int32(29)}}, graph.rows[0])
+			assert.Equal(t, map[string]interface{}{"label": "knows", "outV": int64(101), "inV": int64(102), "properties": map[string]interface{}{"weight": 0.5}}, graph.rows[2])
+		}
+		server.Close()
+	}
+}
+
+func TestImportFilterAndIdProperty(t *testing.T) {
+	graph := &fakeGraph{}
+	server := newServer(graph)
+	defer server.Close()
+	client := newClient(t, server)
+
+	stats, err := Import(context.Background(), client, strings.NewReader(exportedGraphSON), GraphSON, ImportOptions{
+		Filter:     Filter{Properties: []string{"name"}},
+		IdProperty: "importId",
+	})
+	assert.Empty(t, err)
+	assert.Equal(t, int64(0), stats.Skipped)
+	if assert.Len(t, graph.rows, 3) {
+		// vertices with a key are loaded in batches by key, in any order
+		assert.Contains(t, graph.rows[:2], map[string]interface{}{"label": "person", "properties": map[string]interface{}{"name": "marko", "importId": int64(1)}})
+		// edges find their vertices by the ids in the file
+		assert.Equal(t, map[string]interface{}{"label": "knows", "outV": int64(1), "inV": int64(2), "properties": map[string]interface{}{}}, graph.rows[2])
+	}
+	assert.Equal(t, "importId", graph.bindings[0]["key"])
+
+	graph.rows = nil
+	stats, err = Import(context.Background(), client, strings.NewReader(exportedGraphML), GraphML, ImportOptions{
+		Filter: Filter{EdgeLabels: []string{"created"}},
+	})
+	assert.Empty(t, err)
+	assert.Equal(t, int64(1), stats.Skipped)
+	assert.Len(t, graph.rows, 2)
+}
@@ -0,0 +1,252 @@
+package graphio
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphMLWriter writes a GraphML document in the layout of TinkerPop's GraphMLWriter, with the labels
+// stored in the labelV and labelE keys
+type graphMLWriter struct {
+	w          *bufio.Writer
+	vertexKeys map[string]string // property key to GraphML key id
+	edgeKeys   map[string]string
+}
+
+func newGraphMLWriter(w io.Writer, vertexKeys, edgeKeys map[string]interface{}) (*graphMLWriter, error) {
+	g := &graphMLWriter{w: bufio.NewWriter(w), vertexKeys: map[string]string{}, edgeKeys: map[string]string{}}
+	g.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	g.w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	g.key("labelV", "node", "labelV", "string")
+	for _, name := range sortedKeys(vertexKeys) {
+		g.vertexKeys[name] = name
+		g.key(name, "node", name, graphMLType(vertexKeys[name]))
+	}
+	g.key("labelE", "edge", "labelE", "string")
+	for _, name := range sortedKeys(edgeKeys) {
+		id := name
+		if _, clash := g.vertexKeys[name]; clash || name == "labelV" || name == "labelE" {
+			id = "edge_" + name
+		}
+		g.edgeKeys[name] = id
+		g.key(id, "edge", name, graphMLType(edgeKeys[name]))
+	}
+	_, err := g.w.WriteString(`  <graph id="G" edgedefault="directed">` + "\n")
+	return g, err
+}
+
+func (g *graphMLWriter) key(id, element, name, typ string) {
+	fmt.Fprintf(g.w, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", escape(id), element, escape(name), typ)
+}
+
+func (g *graphMLWriter) data(key string, value interface{}) {
+	fmt.Fprintf(g.w, `<data key="%s">%s</data>`, escape(key), escape(graphMLValue(value)))
+}
+
+func (g *graphMLWriter) vertex(v *vertex) error {
+	fmt.Fprintf(g.w, `    <node id="%s">`, escape(graphMLValue(v.id)))
+	g.data("labelV", v.label)
+	names := make([]string, 0, len(v.properties))
+	for name := range v.properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, ok := g.vertexKeys[name]
+		if !ok {
+			return fmt.Errorf("vertex property %s was not declared, the graph changed during the export", name)
+		}
+		g.data(key, v.properties[name][0].value)
+	}
+	g.w.WriteString("</node>\n")
+	for _, e := range v.outE {
+		fmt.Fprintf(g.w, `    <edge id="%s" source="%s" target="%s">`, escape(graphMLValue(e.id)), escape(graphMLValue(e.outV)), escape(graphMLValue(e.inV)))
+		g.data("labelE", e.label)
+		for _, name := range sortedKeys(e.properties) {
+			key, ok := g.edgeKeys[name]
+			if !ok {
+				return fmt.Errorf("edge property %s was not declared, the graph changed during the export", name)
+			}
+			g.data(key, e.properties[name])
+		}
+		g.w.WriteString("</edge>\n")
+	}
+	// bufio keeps the first write error
+	_, err := g.w.WriteString("")
+	return err
+}
+
+func (g *graphMLWriter) close() error {
+	g.w.WriteString("  </graph>\n</graphml>\n")
+	return g.w.Flush()
+}
+
+// graphMLType returns the GraphML type of a value, values of other types are written as strings
+func graphMLType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float32:
+		return "float"
+	case float64:
+		return "double"
+	}
+	return "string"
+}
+
+func graphMLValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return val.String()
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// parseGraphMLValue reads a value of a GraphML type
+func parseGraphMLValue(typ, s string) (interface{}, error) {
+	switch typ {
+	case "boolean":
+		return strconv.ParseBool(s)
+	case "int":
+		n, err := strconv.ParseInt(s, 10, 32)
+		return int32(n), err
+	case "long":
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	case "double":
+		return strconv.ParseFloat(s, 64)
+	}
+	return s, nil
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLElement struct {
+	Id     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Data   []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"data"`
+}
+
+// readGraphML reads the nodes and edges of a GraphML document one at a time. Elements without a
+// labelV or labelE get the default vertex and edge labels.
+func readGraphML(r io.Reader, onVertex func(v *vertex) error, onEdge func(e *edge) error) error {
+	dec := xml.NewDecoder(r)
+	keys := map[string]graphMLKey{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "key":
+			var k graphMLKey
+			if err := dec.DecodeElement(&k, &start); err != nil {
+				return err
+			}
+			if k.Name == "" {
+				k.Name = k.Id
+			}
+			keys[k.Id] = k
+		case "node", "edge":
+			var el graphMLElement
+			if err := dec.DecodeElement(&el, &start); err != nil {
+				return err
+			}
+			label, properties := "", map[string]interface{}{}
+			for _, d := range el.Data {
+				key, ok := keys[d.Key]
+				if !ok {
+					return fmt.Errorf("%s %s: undeclared key %s", start.Name.Local, el.Id, d.Key)
+				}
+				if key.Name == "labelV" || key.Name == "labelE" {
+					label = d.Value
+					continue
+				}
+				value, err := parseGraphMLValue(key.Type, d.Value)
+				if err != nil {
+					return fmt.Errorf("%s %s: %s: %s", start.Name.Local, el.Id, key.Name, err)
+				}
+				properties[key.Name] = value
+			}
+			if start.Name.Local == "edge" {
+				if label == "" {
+					label = "edge"
+				}
+				err = onEdge(&edge{id: el.Id, label: label, outV: el.Source, inV: el.Target, properties: properties})
+			} else {
+				if label == "" {
+					label = "vertex"
+				}
+				v := &vertex{id: el.Id, label: label, properties: map[string][]vertexProperty{}}
+				for key, value := range properties {
+					v.properties[key] = []vertexProperty{{value: value}}
+				}
+				err = onVertex(v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+package graphio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+// adjacencyVertex is a line of a GraphSON adjacency list
+type adjacencyVertex struct {
+	Id         json.RawMessage                      `json:"id"`
+	Label      string                               `json:"label"`
+	OutE       map[string][]adjacencyEdge           `json:"outE,omitempty"`
+	Properties map[string][]adjacencyVertexProperty `json:"properties,omitempty"`
+}
+
+type adjacencyEdge struct {
+	Id         json.RawMessage            `json:"id"`
+	InV        json.RawMessage            `json:"inV"`
+	Properties map[string]json.RawMessage `json:"properties,omitempty"`
+}
+
+type adjacencyVertexProperty struct {
+	Id    json.RawMessage `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+// graphSONWriter writes a GraphSON 3 adjacency list, a vertex with its out edges per line
+type graphSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newGraphSONWriter(w io.Writer) *graphSONWriter {
+	b := bufio.NewWriter(w)
+	return &graphSONWriter{w: b, enc: json.NewEncoder(b)}
+}
+
+func (g *graphSONWriter) vertex(v *vertex) error {
+	id, err := graphSON(v.id)
+	if err != nil {
+		return err
+	}
+	line := adjacencyVertex{Id: id, Label: v.label, OutE: map[string][]adjacencyEdge{}, Properties: map[string][]adjacencyVertexProperty{}}
+	for key, properties := range v.properties {
+		for _, p := range properties {
+			id, err := graphSON(p.id)
+			if err != nil {
+				return err
+			}
+			value, err := graphSON(p.value)
+			if err != nil {
+				return err
+			}
+			line.Properties[key] = append(line.Properties[key], adjacencyVertexProperty{Id: id, Value: value})
+		}
+	}
+	for _, e := range v.outE {
+		id, err := graphSON(e.id)
+		if err != nil {
+			return err
+		}
+		inV, err := graphSON(e.inV)
+		if err != nil {
+			return err
+		}
+		edge := adjacencyEdge{Id: id, InV: inV, Properties: map[string]json.RawMessage{}}
+		for key, value := range e.properties {
+			if edge.Properties[key], err = graphSON(value); err != nil {
+				return err
+			}
+		}
+		line.OutE[e.label] = append(line.OutE[e.label], edge)
+	}
+	return g.enc.Encode(line)
+}
+
+func (g *graphSONWriter) close() error {
+	return g.w.Flush()
+}
+
+// readGraphSON reads the vertices of a GraphSON adjacency list one line at a time, with the edges in their outE.
+// The inE of the vertices are ignored, as every edge is also the out edge of another vertex.
+func readGraphSON(r io.Reader, onVertex func(v *vertex) error, onEdge func(e *edge) error) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		decoded, err := gremlin.DecodeGraphSON(raw)
+		if err != nil {
+			return fmt.Errorf("vertex %d: %s", line, err)
+		}
+		m, ok := decoded.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("vertex %d is not an object", line)
+		}
+		label, _ := m["label"].(string)
+		v := &vertex{id: m["id"], label: label, properties: map[string][]vertexProperty{}}
+		properties, _ := m["properties"].(map[string]interface{})
+		for key, list := range properties {
+			items, _ := list.([]interface{})
+			for _, item := range items {
+				p, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("vertex %d: unexpected property %v", line, item)
+				}
+				v.properties[key] = append(v.properties[key], vertexProperty{id: p["id"], value: p["value"]})
+			}
+		}
+		if err := onVertex(v); err != nil {
+			return err
+		}
+
+		outE, _ := m["outE"].(map[string]interface{})
+		for label, list := range outE {
+			items, _ := list.([]interface{})
+			for _, item := range items {
+				e, ok := item.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("vertex %d: unexpected edge %v", line, item)
+				}
+				properties, _ := e["properties"].(map[string]interface{})
+				if err := onEdge(&edge{id: e["id"], label: label, outV: v.id, inV: e["inV"], properties: properties}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
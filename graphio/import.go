@@ -0,0 +1,223 @@
+package graphio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+type ImportOptions struct {
+	Filter
+	// IdProperty stores the id each vertex has in the file in a property of that name. The import is then
+	// get-or-create, so an import that was interrupted can be run again. Give the property an index.
+	IdProperty string
+	// Bulk controls the batches the elements are loaded in. Key and OnLoaded are set by the import.
+	Bulk gremlin.BulkOptions
+}
+
+// ImportStats describes an import
+type ImportStats struct {
+	Vertices gremlin.BulkStats
+	Edges    gremlin.BulkStats
+	// Skipped counts the elements left out by the filter, and the edges with a vertex that was left out or failed to load
+	Skipped int64
+}
+
+// spooledEdge is an edge waiting in the spool file for the vertices to be loaded
+type spooledEdge struct {
+	Label      string          `json:"label"`
+	OutV       string          `json:"outV"`
+	InV        string          `json:"inV"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+// Import loads the vertices and edges selected by the filter from r. The vertices get new ids, and the
+// edges are connected to them through a map from the ids in the file to the new ones, which is the only
+// part of the graph held in memory. Edges are written to a temporary file while the vertices load, and
+// loaded once all vertices are.
+func Import(ctx context.Context, client *gremlin.Client, r io.Reader, format Format, opts ImportOptions) (*ImportStats, error) {
+	var read func(r io.Reader, onVertex func(v *vertex) error, onEdge func(e *edge) error) error
+	switch format {
+	case GraphML:
+		read = readGraphML
+	case GraphSON:
+		read = readGraphSON
+	default:
+		return nil, UnknownFormatError
+	}
+	spool, err := os.CreateTemp("", "graphio-edges-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	stats := &ImportStats{}
+	ids := map[string]interface{}{} // ids in the file to loaded vertices
+	if err := importVertices(ctx, client, r, read, spool, opts, ids, stats); err != nil {
+		return stats, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return stats, err
+	}
+	return stats, importEdges(ctx, client, spool, opts, ids, stats)
+}
+
+func importVertices(ctx context.Context, client *gremlin.Client, r io.Reader, read func(io.Reader, func(*vertex) error, func(*edge) error) error,
+	spool io.Writer, opts ImportOptions, ids map[string]interface{}, stats *ImportStats) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	pending := map[int64]string{} // stream positions of the vertices being loaded to their ids in the file
+	bulk := opts.Bulk
+	bulk.Key = opts.IdProperty
+	bulk.OnLoaded = func(index int64, record interface{}, id interface{}) {
+		mu.Lock()
+		ids[pending[index]] = id
+		delete(pending, index)
+		mu.Unlock()
+	}
+	records := make(chan gremlin.VertexRecord)
+	loaded := make(chan error, 1)
+	go func() {
+		s, err := gremlin.NewBulkLoader(client, bulk).LoadVertices(ctx, records)
+		stats.Vertices = *s
+		cancel()
+		loaded <- err
+	}()
+
+	var index int64
+	out := bufio.NewWriter(spool)
+	enc := json.NewEncoder(out)
+	err := read(r, func(v *vertex) error {
+		if !opts.vertex(v.label) {
+			stats.Skipped++
+			return nil
+		}
+		key, err := idKey(v.id)
+		if err != nil {
+			return err
+		}
+		record := gremlin.VertexRecord{Label: v.label, Properties: map[string]interface{}{}}
+		for name, values := range v.properties {
+			if opts.property(name) && len(values) > 0 {
+				record.Properties[name] = values[0].value
+			}
+		}
+		if opts.IdProperty != "" {
+			record.Properties[opts.IdProperty] = v.id
+		}
+		mu.Lock()
+		pending[index] = key
+		mu.Unlock()
+		index++
+		select {
+		case records <- record:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, func(e *edge) error {
+		if !opts.edge(e.label) {
+			stats.Skipped++
+			return nil
+		}
+		outV, err := idKey(e.outV)
+		if err != nil {
+			return err
+		}
+		inV, err := idKey(e.inV)
+		if err != nil {
+			return err
+		}
+		properties := map[string]interface{}{}
+		for name, value := range e.properties {
+			if opts.property(name) {
+				properties[name] = value
+			}
+		}
+		p, err := graphSON(properties)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(spooledEdge{Label: e.label, OutV: outV, InV: inV, Properties: p})
+	})
+	close(records)
+	if loadErr := <-loaded; loadErr != nil {
+		return loadErr
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func importEdges(ctx context.Context, client *gremlin.Client, spool io.Reader, opts ImportOptions, ids map[string]interface{}, stats *ImportStats) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bulk := opts.Bulk
+	bulk.Key = opts.IdProperty
+	bulk.OnLoaded = nil
+	records := make(chan gremlin.EdgeRecord)
+	loaded := make(chan error, 1)
+	go func() {
+		s, err := gremlin.NewBulkLoader(client, bulk).LoadEdges(ctx, records)
+		stats.Edges = *s
+		cancel()
+		loaded <- err
+	}()
+
+	err := func() error {
+		dec := json.NewDecoder(spool)
+		for {
+			var e spooledEdge
+			if err := dec.Decode(&e); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			outV, outOk := ids[e.OutV]
+			inV, inOk := ids[e.InV]
+			if !outOk || !inOk {
+				stats.Skipped++
+				continue
+			}
+			if opts.IdProperty != "" {
+				// the loader finds the vertices by the ids they had in the file
+				var err error
+				if outV, err = gremlin.DecodeGraphSON([]byte(e.OutV)); err != nil {
+					return err
+				}
+				if inV, err = gremlin.DecodeGraphSON([]byte(e.InV)); err != nil {
+					return err
+				}
+			}
+			properties, err := gremlin.DecodeGraphSON(e.Properties)
+			if err != nil {
+				return err
+			}
+			p, ok := properties.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unexpected edge properties %v", properties)
+			}
+			select {
+			case records <- gremlin.EdgeRecord{Label: e.Label, OutV: outV, InV: inV, Properties: p}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}()
+	close(records)
+	if loadErr := <-loaded; loadErr != nil {
+		return loadErr
+	}
+	return err
+}
@@ -0,0 +1,403 @@
+package gremlin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"github.com/satori/go.uuid"
+)
+
+// Traverser is a value returned by a traversal together with its bulk, the number of times it was returned.
+// Traversals merge equal results into a single traverser rather than repeating them.
+type Traverser struct {
+	Value interface{}
+	Bulk  int64
+}
+
+// Vertex is a g:Vertex. Properties are only returned when the server materializes them.
+type Vertex struct {
+	Id         interface{}
+	Label      string
+	Properties map[string][]VertexProperty
+}
+
+// Edge is a g:Edge
+type Edge struct {
+	Id         interface{}
+	Label      string
+	OutV       interface{}
+	OutVLabel  string
+	InV        interface{}
+	InVLabel   string
+	Properties map[string]Property
+}
+
+// VertexProperty is a g:VertexProperty, which may have properties of its own
+type VertexProperty struct {
+	Id         interface{}
+	Label      string
+	Value      interface{}
+	Properties map[string]interface{}
+}
+
+// Property is a g:Property, the property of an edge or of a vertex property
+type Property struct {
+	Key   string
+	Value interface{}
+}
+
+// Path is a g:Path, the objects a traverser went through with the step labels of each
+type Path struct {
+	Labels  [][]string
+	Objects []interface{}
+}
+
+// DecodeGraphSON decodes GraphSON 2 or 3 into Go values. Numbers keep the type they are sent with
+// (g:Int32 becomes an int32, g:Int64 an int64), lists become []interface{}, sets a Set and maps a
+// map[string]interface{}, or a map[interface{}]interface{} when not all keys are strings. Elements
+// become a Vertex, Edge, VertexProperty, Property or Path. Values of unknown types are returned as
+// a TypedValue holding the decoded value.
+func DecodeGraphSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return fromGraphSON(v)
+}
+
+func fromGraphSON(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n, nil
+		}
+		return val.Float64()
+	case []interface{}:
+		return graphSONItems(val)
+	case map[string]interface{}:
+		typ, typed := val["@type"].(string)
+		if value, ok := val["@value"]; typed && ok && len(val) == 2 {
+			return fromTypedGraphSON(typ, value)
+		}
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			decoded, err := fromGraphSON(item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = decoded
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+func graphSONItems(items []interface{}) ([]interface{}, error) {
+	decoded := make([]interface{}, len(items))
+	for i, item := range items {
+		d, err := fromGraphSON(item)
+		if err != nil {
+			return nil, err
+		}
+		decoded[i] = d
+	}
+	return decoded, nil
+}
+
+func fromTypedGraphSON(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "g:Int32":
+		n, err := graphSONInt(value)
+		return int32(n), err
+	case "g:Int64", "gx:Int16", "gx:Byte":
+		return graphSONInt(value)
+	case "g:Float":
+		f, err := graphSONFloat(value)
+		return float32(f), err
+	case "g:Double":
+		return graphSONFloat(value)
+	case "g:UUID":
+		s, _ := value.(string)
+		return uuid.FromString(s)
+	case "g:Date", "g:Timestamp":
+		ms, err := graphSONInt(value)
+		return time.Unix(0, ms*int64(time.Millisecond)), err
+	case "gx:ByteBuffer":
+		s, _ := value.(string)
+		return base64.StdEncoding.DecodeString(s)
+	case "g:List":
+		items, _ := value.([]interface{})
+		return graphSONItems(items)
+	case "g:Set":
+		items, _ := value.([]interface{})
+		decoded, err := graphSONItems(items)
+		return Set(decoded), err
+	case "g:BulkSet":
+		return decodeBulkSet(value)
+	case "g:Map":
+		items, _ := value.([]interface{})
+		return decodeGraphSONMap(items)
+	case "g:T", "g:Direction", "g:Class", "g:Cardinality", "g:Column", "g:Order", "g:Scope":
+		return value, nil
+	case "g:Traverser":
+		return decodeTraverser(value)
+	case "g:Vertex":
+		return decodeVertex(value)
+	case "g:Edge":
+		return decodeEdge(value)
+	case "g:VertexProperty":
+		return decodeVertexProperty(value)
+	case "g:Property":
+		fields, _ := value.(map[string]interface{})
+		key, _ := fields["key"].(string)
+		v, err := fromGraphSON(fields["value"])
+		return Property{Key: key, Value: v}, err
+	case "g:Path":
+		return decodePath(value)
+	}
+	decoded, err := fromGraphSON(value)
+	return TypedValue{typ, decoded}, err
+}
+
+func graphSONInt(value interface{}) (int64, error) {
+	if n, ok := value.(json.Number); ok {
+		return n.Int64()
+	}
+	return 0, fmt.Errorf("expected a number, got %v", value)
+}
+
+// graphSONFloat also accepts the strings GraphSON uses for NaN and the infinities
+func graphSONFloat(value interface{}) (float64, error) {
+	switch value {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	if n, ok := value.(json.Number); ok {
+		return n.Float64()
+	}
+	return 0, fmt.Errorf("expected a number, got %v", value)
+}
+
+// decodeGraphSONMap decodes the flat key and value list of a g:Map. Keys that can't be map keys in Go,
+// such as lists or typed values holding a map, are replaced by their string representation.
+func decodeGraphSONMap(items []interface{}) (interface{}, error) {
+	keys := make([]interface{}, 0, len(items)/2)
+	values := make([]interface{}, 0, len(items)/2)
+	stringKeys := true
+	for i := 0; i+1 < len(items); i += 2 {
+		key, err := fromGraphSON(items[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := fromGraphSON(items[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if !hashable(reflect.ValueOf(key)) {
+			key = fmt.Sprint(key)
+		}
+		if _, ok := key.(string); !ok {
+			stringKeys = false
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	if stringKeys {
+		m := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			m[key.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, len(keys))
+	for i, key := range keys {
+		m[key] = values[i]
+	}
+	return m, nil
+}
+
+// hashable reports whether v can be a map key. A struct type is comparable even when an interface field
+// holds a map or a slice, which only panics once it is hashed, so the dynamic values are checked too.
+func hashable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Interface:
+		return v.IsNil() || hashable(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashable(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !hashable(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return v.Type().Comparable()
+}
+
+// decodeBulkSet expands the values of a g:BulkSet by their bulk
+func decodeBulkSet(value interface{}) ([]interface{}, error) {
+	items, _ := value.([]interface{})
+	var expanded []interface{}
+	for i := 0; i+1 < len(items); i += 2 {
+		v, err := fromGraphSON(items[i])
+		if err != nil {
+			return nil, err
+		}
+		bulk, err := fromGraphSON(items[i+1])
+		if err != nil {
+			return nil, err
+		}
+		n, _ := intOption(bulk)
+		for ; n > 0; n-- {
+			expanded = append(expanded, v)
+		}
+	}
+	return expanded, nil
+}
+
+func decodeTraverser(value interface{}) (Traverser, error) {
+	fields, _ := value.(map[string]interface{})
+	v, err := fromGraphSON(fields["value"])
+	if err != nil {
+		return Traverser{}, err
+	}
+	bulk, err := fromGraphSON(fields["bulk"])
+	if err != nil {
+		return Traverser{}, err
+	}
+	n, ok := intOption(bulk)
+	if !ok {
+		n = 1
+	}
+	return Traverser{Value: v, Bulk: n}, nil
+}
+
+func decodeVertex(value interface{}) (Vertex, error) {
+	fields, _ := value.(map[string]interface{})
+	id, err := fromGraphSON(fields["id"])
+	if err != nil {
+		return Vertex{}, err
+	}
+	v := Vertex{Id: id}
+	v.Label, _ = fields["label"].(string)
+	properties, _ := fields["properties"].(map[string]interface{})
+	if len(properties) > 0 {
+		v.Properties = make(map[string][]VertexProperty, len(properties))
+	}
+	for key, list := range properties {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			decoded, err := fromGraphSON(item)
+			if err != nil {
+				return Vertex{}, err
+			}
+			if p, ok := decoded.(VertexProperty); ok {
+				v.Properties[key] = append(v.Properties[key], p)
+			}
+		}
+	}
+	return v, nil
+}
+
+func decodeEdge(value interface{}) (Edge, error) {
+	fields, _ := value.(map[string]interface{})
+	var e Edge
+	var err error
+	for key, target := range map[string]*interface{}{"id": &e.Id, "outV": &e.OutV, "inV": &e.InV} {
+		if *target, err = fromGraphSON(fields[key]); err != nil {
+			return Edge{}, err
+		}
+	}
+	e.Label, _ = fields["label"].(string)
+	e.OutVLabel, _ = fields["outVLabel"].(string)
+	e.InVLabel, _ = fields["inVLabel"].(string)
+	properties, _ := fields["properties"].(map[string]interface{})
+	if len(properties) > 0 {
+		e.Properties = make(map[string]Property, len(properties))
+	}
+	for key, item := range properties {
+		decoded, err := fromGraphSON(item)
+		if err != nil {
+			return Edge{}, err
+		}
+		if p, ok := decoded.(Property); ok {
+			e.Properties[key] = p
+		}
+	}
+	return e, nil
+}
+
+func decodeVertexProperty(value interface{}) (VertexProperty, error) {
+	fields, _ := value.(map[string]interface{})
+	var p VertexProperty
+	var err error
+	if p.Id, err = fromGraphSON(fields["id"]); err != nil {
+		return p, err
+	}
+	if p.Value, err = fromGraphSON(fields["value"]); err != nil {
+		return p, err
+	}
+	p.Label, _ = fields["label"].(string)
+	properties, _ := fields["properties"].(map[string]interface{})
+	if len(properties) > 0 {
+		p.Properties = make(map[string]interface{}, len(properties))
+	}
+	for key, item := range properties {
+		decoded, err := fromGraphSON(item)
+		if err != nil {
+			return p, err
+		}
+		if property, ok := decoded.(Property); ok {
+			decoded = property.Value
+		}
+		p.Properties[key] = decoded
+	}
+	return p, nil
+}
+
+func decodePath(value interface{}) (Path, error) {
+	fields, _ := value.(map[string]interface{})
+	labels, err := fromGraphSON(fields["labels"])
+	if err != nil {
+		return Path{}, err
+	}
+	objects, err := fromGraphSON(fields["objects"])
+	if err != nil {
+		return Path{}, err
+	}
+	var p Path
+	p.Objects, _ = objects.([]interface{})
+	list, _ := labels.([]interface{})
+	for _, step := range list {
+		var stepLabels []string
+		items, _ := step.(Set)
+		if items == nil {
+			items, _ = step.([]interface{})
+		}
+		for _, label := range items {
+			if s, ok := label.(string); ok {
+				stepLabels = append(stepLabels, s)
+			}
+		}
+		p.Labels = append(p.Labels, stepLabels)
+	}
+	return p, nil
+}
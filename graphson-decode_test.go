@@ -0,0 +1,118 @@
+package gremlin
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, data string) interface{} {
+	v, err := DecodeGraphSON([]byte(data))
+	assert.Empty(t, err, data)
+	return v
+}
+
+func TestDecodeGraphSON(t *testing.T) {
+	assert.Equal(t, int32(1), decode(t, `{"@type":"g:Int32","@value":1}`))
+	assert.Equal(t, int64(9007199254740993), decode(t, `{"@type":"g:Int64","@value":9007199254740993}`))
+	assert.Equal(t, 1.5, decode(t, `{"@type":"g:Double","@value":1.5}`))
+	assert.Equal(t, float32(1.5), decode(t, `{"@type":"g:Float","@value":1.5}`))
+	assert.Equal(t, "x", decode(t, `"x"`))
+	assert.Equal(t, time.Unix(1, 0), decode(t, `{"@type":"g:Date","@value":1000}`))
+	assert.Equal(t, uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786")),
+		decode(t, `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`))
+	assert.Equal(t, []interface{}{int64(1), "a"}, decode(t, `{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1},"a"]}`))
+	assert.Equal(t, Set{"a"}, decode(t, `{"@type":"g:Set","@value":["a"]}`))
+	assert.Equal(t, []interface{}{"a", "a", "b"}, decode(t, `{"@type":"g:BulkSet","@value":["a",{"@type":"g:Int64","@value":2},"b",{"@type":"g:Int64","@value":1}]}`))
+	assert.Equal(t, map[string]interface{}{"name": []interface{}{"marko"}}, decode(t, `{"name":["marko"]}`))
+	assert.Equal(t, map[string]interface{}{"name": "marko"}, decode(t, `{"@type":"g:Map","@value":["name","marko"]}`))
+	assert.Equal(t, map[interface{}]interface{}{"id": int64(1), int32(2): "x"},
+		decode(t, `{"@type":"g:Map","@value":[{"@type":"g:T","@value":"id"},{"@type":"g:Int64","@value":1},{"@type":"g:Int32","@value":2},"x"]}`))
+	assert.Equal(t, TypedValue{"janusgraph:RelationIdentifier", map[string]interface{}{"relationId": "4r6-39s-69zp-3c8"}},
+		decode(t, `{"@type":"janusgraph:RelationIdentifier","@value":{"relationId":"4r6-39s-69zp-3c8"}}`))
+	// a typed value holding a map can't be a map key
+	assert.Equal(t, map[string]interface{}{"{janusgraph:RelationIdentifier map[relationId:4r6-39s-69zp-3c8]}": int64(1)},
+		decode(t, `{"@type":"g:Map","@value":[{"@type":"janusgraph:RelationIdentifier","@value":{"relationId":"4r6-39s-69zp-3c8"}},{"@type":"g:Int64","@value":1}]}`))
+
+	_, err := DecodeGraphSON([]byte(`{"@type":"g:Int32","@value":"x"}`))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeElements(t *testing.T) {
+	vertex := decode(t, `{"@type":"g:Vertex","@value":{"id":{"@type":"g:Int64","@value":1},"label":"person","properties":{
+		"name":[{"@type":"g:VertexProperty","@value":{"id":{"@type":"g:Int64","@value":0},"value":"marko","label":"name",
+			"properties":{"since":{"@type":"g:Property","@value":{"key":"since","value":{"@type":"g:Int32","@value":2010}}}}}}]}}}`)
+	assert.Equal(t, Vertex{Id: int64(1), Label: "person", Properties: map[string][]VertexProperty{
+		"name": {{Id: int64(0), Label: "name", Value: "marko", Properties: map[string]interface{}{"since": int32(2010)}}},
+	}}, vertex)
+
+	edge := decode(t, `{"@type":"g:Edge","@value":{"id":{"@type":"g:Int32","@value":7},"label":"knows","inVLabel":"person","outVLabel":"person",
+		"inV":{"@type":"g:Int32","@value":2},"outV":{"@type":"g:Int32","@value":1},
+		"properties":{"weight":{"@type":"g:Property","@value":{"key":"weight","value":{"@type":"g:Double","@value":0.5}}}}}}`)
+	assert.Equal(t, Edge{Id: int32(7), Label: "knows", OutV: int32(1), OutVLabel: "person", InV: int32(2), InVLabel: "person",
+		Properties: map[string]Property{"weight": {Key: "weight", Value: 0.5}}}, edge)
+
+	path := decode(t, `{"@type":"g:Path","@value":{"labels":{"@type":"g:List","@value":[{"@type":"g:Set","@value":["a"]},{"@type":"g:Set","@value":[]}]},
+		"objects":{"@type":"g:List","@value":["marko","josh"]}}}`)
+	assert.Equal(t, Path{Labels: [][]string{{"a"}, nil}, Objects: []interface{}{"marko", "josh"}}, path)
+}
+
+func TestResultTraversers(t *testing.T) {
+	res := &Result{Data: []byte(`[{"@type":"g:Traverser","@value":{"bulk":{"@type":"g:Int64","@value":3},"value":"a"}},"b"]`)}
+	traversers, err := res.Traversers()
+	assert.Empty(t, err)
+	assert.Equal(t, []Traverser{{"a", 3}, {"b", 1}}, traversers)
+	values, err := res.Values()
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{"a", "a", "a", "b"}, values)
+
+	res = &Result{Data: []byte(`{"@type":"g:BulkSet","@value":["a",{"@type":"g:Int64","@value":2}]}`)}
+	traversers, err = res.Traversers()
+	assert.Empty(t, err)
+	assert.Equal(t, []Traverser{{"a", 2}}, traversers)
+}
+
+func TestTerminalSteps(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		partial := testResponse(req, StatusPartialContent, `[{"@type":"g:Traverser","@value":{"bulk":{"@type":"g:Int64","@value":2},"value":"a"}}]`)
+		last := testResponse(req, StatusSuccess, `[{"@type":"g:Traverser","@value":{"bulk":{"@type":"g:Int64","@value":1},"value":"b"}}]`)
+		return []*Response{partial, last}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	ctx := context.Background()
+	g := NewGraphTraversalSource(cl, "g")
+
+	traversal := g.V().Values("name")
+	next, err := traversal.Next(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, "a", next)
+	hasNext, err := traversal.HasNext(ctx)
+	assert.Empty(t, err)
+	assert.True(t, hasNext)
+	rest, err := traversal.ToList(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, rest)
+	_, err = traversal.Next(ctx)
+	assert.Equal(t, NoMoreResultsError, err)
+
+	all, err := g.V().Values("name").ToList(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{"a", "a", "b"}, all)
+	set, err := g.V().Values("name").ToSet(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, Set{"a", "b"}, set)
+
+	assert.Empty(t, g.AddV("person").Iterate(ctx))
+	received := server.received()
+	if assert.Len(t, received, 4) {
+		steps := received[3].Args["gremlin"].(map[string]interface{})["@value"].(map[string]interface{})["step"].([]interface{})
+		assert.Equal(t, []interface{}{"none"}, steps[len(steps)-1])
+	}
+}
@@ -0,0 +1,291 @@
+package gremlin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"github.com/satori/go.uuid"
+)
+
+// GraphSONVersion selects the GraphSON serializer used to talk to the server.
+// Clients can also talk GraphBinary, see OptClientGraphBinary.
+type GraphSONVersion int
+
+const (
+	GraphSONv2 GraphSONVersion = 2
+	GraphSONv3 GraphSONVersion = 3
+)
+
+var UnsupportedGraphSONVersionError = errors.New("only GraphSON versions 2 and 3 are supported")
+
+func (v GraphSONVersion) mimeType() string {
+	if v == GraphSONv3 {
+		return "application/vnd.gremlin-v3.0+json"
+	}
+	return graphSONMimeType
+}
+
+// Selects the GraphSON version requests are serialized with and responses are requested in. Defaults to GraphSONv2.
+func OptClientSerializer(version GraphSONVersion) OptClient {
+	return func(c *Client) error {
+		if version != GraphSONv2 && version != GraphSONv3 {
+			return UnsupportedGraphSONVersionError
+		}
+		c.factory.serializer = version
+		c.factory.graphBinary = false
+		return nil
+	}
+}
+
+// GraphSONMarshaler is implemented by types that serialize themselves as GraphSON, typically as a TypedValue.
+// The value returned is serialized again, so it may hold other Go values.
+type GraphSONMarshaler interface {
+	MarshalGraphSON(version GraphSONVersion) (interface{}, error)
+}
+
+// TypedValue is a GraphSON value with its type, such as {"@type": "g:Int64", "@value": 1}
+type TypedValue struct {
+	Type  string      `json:"@type"`
+	Value interface{} `json:"@value"`
+}
+
+// Set is serialized as a g:Set, GraphSON 2 has no sets so it is sent as a list there
+type Set []interface{}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// EncodeGraphSON encodes a Go value as typed GraphSON of the given version, the way binding values are sent.
+// Values returned by DecodeGraphSON encode back to the GraphSON they were decoded from, except for elements and paths.
+func EncodeGraphSON(v interface{}, version GraphSONVersion) ([]byte, error) {
+	g, err := toGraphSON(v, version)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(g)
+}
+
+// toGraphSON converts a Go value into the typed GraphSON form of the given version
+func toGraphSON(v interface{}, version GraphSONVersion) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch val := v.(type) {
+	case GraphSONMarshaler:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		g, err := val.MarshalGraphSON(version)
+		if err != nil {
+			return nil, err
+		}
+		if _, typed := g.(TypedValue); typed {
+			return g, nil
+		}
+		return toGraphSON(g, version)
+	case TypedValue, json.RawMessage:
+		return val, nil
+	case Set:
+		items, err := graphSONList(reflect.ValueOf([]interface{}(val)), version)
+		if err != nil || version == GraphSONv2 {
+			return items, err
+		}
+		return TypedValue{"g:Set", items}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Type() {
+	case timeType:
+		return TypedValue{"g:Date", rv.Interface().(time.Time).UnixNano() / int64(time.Millisecond)}, nil
+	case durationType:
+		return TypedValue{"gx:Duration", isoDuration(time.Duration(rv.Int()))}, nil
+	case uuidType:
+		return TypedValue{"g:UUID", rv.Interface().(uuid.UUID).String()}, nil
+	case bytesType:
+		return TypedValue{"gx:ByteBuffer", base64.StdEncoding.EncodeToString(rv.Bytes())}, nil
+	}
+	if _, ok := v.(json.Marshaler); ok {
+		return v, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool, reflect.String:
+		return v, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return TypedValue{"g:Int32", rv.Convert(reflect.TypeOf(int64(0))).Int()}, nil
+	case reflect.Int:
+		// sized like a literal in a groovy script, so scripts behave the same with bindings
+		if n := rv.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+			return TypedValue{"g:Int32", n}, nil
+		}
+		return TypedValue{"g:Int64", rv.Int()}, nil
+	case reflect.Int64:
+		return TypedValue{"g:Int64", rv.Int()}, nil
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows g:Int64", rv.Uint())
+		}
+		return TypedValue{"g:Int64", int64(rv.Uint())}, nil
+	case reflect.Float32:
+		return TypedValue{"g:Float", rv.Float()}, nil
+	case reflect.Float64:
+		return TypedValue{"g:Double", rv.Float()}, nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return toGraphSON(rv.Elem().Interface(), version)
+	case reflect.Slice, reflect.Array:
+		items, err := graphSONList(rv, version)
+		if err != nil || version == GraphSONv2 {
+			return items, err
+		}
+		return TypedValue{"g:List", items}, nil
+	case reflect.Map:
+		return graphSONMap(rv, version)
+	case reflect.Struct:
+		// structs keep their encoding/json representation
+		if _, err := json.Marshal(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("values of type %s can't be serialized as GraphSON", rv.Type())
+}
+
+func graphSONList(rv reflect.Value, version GraphSONVersion) ([]interface{}, error) {
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		item, err := toGraphSON(rv.Index(i).Interface(), version)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// graphSONMap converts a map, GraphSON 2 only has string keys while GraphSON 3 maps are a flat list of keys and values
+func graphSONMap(rv reflect.Value, version GraphSONVersion) (interface{}, error) {
+	if version == GraphSONv2 {
+		m := make(map[string]interface{}, rv.Len())
+		for _, key := range rv.MapKeys() {
+			if key.Kind() != reflect.String {
+				return nil, fmt.Errorf("GraphSON 2 maps need string keys, not %s", key.Type())
+			}
+			item, err := toGraphSON(rv.MapIndex(key).Interface(), version)
+			if err != nil {
+				return nil, err
+			}
+			m[key.String()] = item
+		}
+		return m, nil
+	}
+	items := make([]interface{}, 0, 2*rv.Len())
+	for _, key := range rv.MapKeys() {
+		k, err := toGraphSON(key.Interface(), version)
+		if err != nil {
+			return nil, err
+		}
+		item, err := toGraphSON(rv.MapIndex(key).Interface(), version)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, k, item)
+	}
+	return TypedValue{"g:Map", items}, nil
+}
+
+// isoDuration formats a duration the way java.time.Duration expects it, such as PT1.5S
+func isoDuration(d time.Duration) string {
+	return "PT" + strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.9f", d.Seconds()), "0"), ".") + "S"
+}
+
+// graphSONBindings converts every binding value into its typed GraphSON form
+func graphSONBindings(bindings Bind, version GraphSONVersion) (Bind, error) {
+	if bindings == nil {
+		return nil, nil
+	}
+	typed := make(Bind, len(bindings))
+	for name, value := range bindings {
+		v, err := toGraphSON(value, version)
+		if err != nil {
+			return nil, &InvalidBindingError{Name: name, Reason: err.Error()}
+		}
+		typed[name] = v
+	}
+	return typed, nil
+}
+
+// resultItems returns the items of the result data, which GraphSON 3 wraps in a g:List.
+// The values of a g:BulkSet are returned as traversers holding the value and its bulk.
+func resultItems(data json.RawMessage) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var list struct {
+			Type  string            `json:"@type"`
+			Value []json.RawMessage `json:"@value"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		switch list.Type {
+		case "g:List", "g:Set":
+			return list.Value, nil
+		case "g:BulkSet":
+			return bulkSetTraversers(list.Value)
+		}
+		return nil, fmt.Errorf("expected a list of results, got %s", list.Type)
+	}
+	err := json.Unmarshal(data, &items)
+	return items, err
+}
+
+// graphSONMapValue is a map that may be sent untyped or as a GraphSON 3 g:Map
+type graphSONMapValue map[string]interface{}
+
+func (m *graphSONMapValue) UnmarshalJSON(b []byte) error {
+	var typed struct {
+		Type  string        `json:"@type"`
+		Value []interface{} `json:"@value"`
+	}
+	if err := json.Unmarshal(b, &typed); err == nil && typed.Type == "g:Map" {
+		*m = graphSONMapValue{}
+		for i := 0; i+1 < len(typed.Value); i += 2 {
+			(*m)[fmt.Sprint(graphSONValue(typed.Value[i]))] = typed.Value[i+1]
+		}
+		return nil
+	}
+	var plain map[string]interface{}
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*m = plain
+	return nil
+}
+
+// bulkSetTraversers turns the flat list of values and bulks of a g:BulkSet into g:Traverser items
+func bulkSetTraversers(values []json.RawMessage) ([]json.RawMessage, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("g:BulkSet holds a value without a bulk")
+	}
+	items := make([]json.RawMessage, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		item, err := json.Marshal(TypedValue{"g:Traverser", map[string]json.RawMessage{"bulk": values[i+1], "value": values[i]}})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
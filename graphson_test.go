@@ -0,0 +1,146 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// point is a user type with its own GraphSON representation
+type point struct {
+	X, Y float64
+}
+
+func (p point) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return TypedValue{"example:Point", map[string]interface{}{"x": p.X, "y": p.Y}}, nil
+}
+
+func graphSONString(t *testing.T, v interface{}, version GraphSONVersion) string {
+	b, err := EncodeGraphSON(v, version)
+	assert.Empty(t, err)
+	return string(b)
+}
+
+func TestToGraphSON(t *testing.T) {
+	id := uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786"))
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		value  interface{}
+		v2, v3 string
+	}{
+		{nil, `null`, `null`},
+		{"john", `"john"`, `"john"`},
+		{true, `true`, `true`},
+		{7, `{"@type":"g:Int32","@value":7}`, `{"@type":"g:Int32","@value":7}`},
+		{math.MaxInt32 + 1, `{"@type":"g:Int64","@value":2147483648}`, `{"@type":"g:Int64","@value":2147483648}`},
+		{int32(7), `{"@type":"g:Int32","@value":7}`, `{"@type":"g:Int32","@value":7}`},
+		{int64(7), `{"@type":"g:Int64","@value":7}`, `{"@type":"g:Int64","@value":7}`},
+		{uint64(7), `{"@type":"g:Int64","@value":7}`, `{"@type":"g:Int64","@value":7}`},
+		{float32(1.5), `{"@type":"g:Float","@value":1.5}`, `{"@type":"g:Float","@value":1.5}`},
+		{1.5, `{"@type":"g:Double","@value":1.5}`, `{"@type":"g:Double","@value":1.5}`},
+		{date, `{"@type":"g:Date","@value":1577934245000}`, `{"@type":"g:Date","@value":1577934245000}`},
+		{1500 * time.Millisecond, `{"@type":"gx:Duration","@value":"PT1.5S"}`, `{"@type":"gx:Duration","@value":"PT1.5S"}`},
+		{id, `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`, `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`},
+		{&id, `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`, `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`},
+		{[]byte("hi"), `{"@type":"gx:ByteBuffer","@value":"aGk="}`, `{"@type":"gx:ByteBuffer","@value":"aGk="}`},
+		{[]int64{1}, `[{"@type":"g:Int64","@value":1}]`, `{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1}]}`},
+		{Set{"a", "b"}, `["a","b"]`, `{"@type":"g:Set","@value":["a","b"]}`},
+		{map[string]int64{"a": 1}, `{"a":{"@type":"g:Int64","@value":1}}`, `{"@type":"g:Map","@value":["a",{"@type":"g:Int64","@value":1}]}`},
+		{point{1, 2}, `{"@type":"example:Point","@value":{"x":1,"y":2}}`, `{"@type":"example:Point","@value":{"x":1,"y":2}}`},
+		{json.RawMessage(`{"raw":1}`), `{"raw":1}`, `{"raw":1}`},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.v2, graphSONString(t, c.value, GraphSONv2), "%#v", c.value)
+		assert.Equal(t, c.v3, graphSONString(t, c.value, GraphSONv3), "%#v", c.value)
+	}
+
+	_, err := toGraphSON(map[int]string{1: "a"}, GraphSONv2)
+	assert.NotNil(t, err)
+	assert.Equal(t, `{"@type":"g:Map","@value":[{"@type":"g:Int32","@value":1},"a"]}`, graphSONString(t, map[int]string{1: "a"}, GraphSONv3))
+	_, err = toGraphSON(func() {}, GraphSONv3)
+	assert.NotNil(t, err)
+	_, err = toGraphSON(uint64(math.MaxUint64), GraphSONv3)
+	assert.NotNil(t, err)
+}
+
+func TestGraphSONSerializerTypesBindings(t *testing.T) {
+	req := Query("g.V(id)").Bindings(Bind{"id": int64(1)})
+	msg, err := graphSONSerializer(req, GraphSONv3)
+	assert.Empty(t, err)
+	mimeType := "application/vnd.gremlin-v3.0+json"
+	assert.Equal(t, byte(len(mimeType)), msg[0])
+	assert.Equal(t, mimeType, string(msg[1:len(mimeType)+1]))
+
+	var form struct {
+		Args struct {
+			Bindings map[string]TypedValue `json:"bindings"`
+		} `json:"args"`
+	}
+	assert.Empty(t, json.Unmarshal(msg[len(mimeType)+1:], &form))
+	assert.Equal(t, "g:Int64", form.Args.Bindings["id"].Type)
+	// the caller's bindings are left alone
+	assert.Equal(t, int64(1), req.Args.Bindings["id"])
+
+	_, err = graphSONSerializer(Query("x").Bindings(Bind{"x": make(chan int)}), GraphSONv2)
+	assert.NotNil(t, err)
+}
+
+func TestGraphSONv3Responses(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		res := testResponse(req, StatusSuccess, `{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1},{"@type":"g:Int64","@value":2}]}`)
+		return []*Response{res}
+	})
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientSerializer(GraphSONv3))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	res, err := cl.Do(context.Background(), Query("g.V(ids)").Bindings(Bind{"ids": Set{int64(1), int64(2)}}))
+	assert.Empty(t, err)
+	assert.Equal(t, `[{"@type":"g:Int64","@value":1},{"@type":"g:Int64","@value":2}]`, string(res.Data))
+	assert.Equal(t, 2, res.Batches[0].ResultCount)
+
+	received := server.received()
+	if assert.Len(t, received, 1) {
+		ids := received[0].Args["bindings"].(map[string]interface{})["ids"].(map[string]interface{})
+		assert.Equal(t, "g:Set", ids["@type"])
+	}
+
+	_, err = NewClientWithOptions(server.Url, OptClientSerializer(GraphSONVersion(1)))
+	assert.Equal(t, UnsupportedGraphSONVersionError, err)
+}
+
+func TestGraphSONv3StatusAttributes(t *testing.T) {
+	var res Response
+	err := json.Unmarshal([]byte(`{"requestId": "1",
+		"status": {"code": 200, "message": "", "attributes": {"@type": "g:Map", "@value": ["warnings", {"@type": "g:List", "@value": ["slow"]}]}},
+		"result": {"data": null, "meta": {"@type": "g:Map", "@value": ["aggregateTo", "list"]}}}`), &res)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"slow"}, res.Status.Attributes.Warnings())
+	assert.Equal(t, "list", res.Result.Meta["aggregateTo"])
+}
+
+func TestEncodeGraphSONRoundTrip(t *testing.T) {
+	values := []interface{}{
+		int32(7), int64(7), float32(1.5), 2.5, "marko", true,
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Local(),
+		uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786")),
+		[]interface{}{int32(1), "a"},
+		Set{"a", "b"},
+		map[string]interface{}{"weight": 0.5},
+		TypedValue{"janusgraph:RelationIdentifier", map[string]interface{}{"relationId": "4r-6-2dx-9"}},
+	}
+	for _, v := range values {
+		b, err := EncodeGraphSON(v, GraphSONv3)
+		assert.Empty(t, err)
+		decoded, err := DecodeGraphSON(b)
+		assert.Empty(t, err)
+		assert.Equal(t, v, decoded, string(b))
+	}
+}
@@ -0,0 +1,121 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ReadOnlyError = errors.New("write queries are not allowed on a read only client")
+)
+
+// Handler executes a request, either by sending it to the server or by passing it further down the chain
+type Handler func(ctx context.Context, req *Request) (*Result, error)
+
+// Interceptor wraps the execution of every request made by a client. It may inspect or rewrite the request,
+// short circuit by returning a result without calling next, or inspect the result returned by next.
+// The request handed to an interceptor is a copy with the client defaults applied, so it can be modified safely.
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*Result, error)
+
+// Adds interceptors to the client. Interceptors run in the order they are registered:
+// the first one registered is the outermost and sees the request first and the result last.
+func OptClientInterceptors(interceptors ...Interceptor) OptClient {
+	return func(c *Client) error {
+		c.interceptors = append(c.interceptors, interceptors...)
+		return nil
+	}
+}
+
+func chainInterceptors(interceptors []Interceptor, final Handler) Handler {
+	h := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx context.Context, req *Request) (*Result, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return h
+}
+
+// LoggingInterceptor logs every request with its duration and outcome
+func LoggingInterceptor(logf func(format string, args ...interface{})) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		start := time.Now()
+		res, err := next(ctx, req)
+		if err != nil {
+			logf("gremlin %s %s failed after %v: %v", req.Op, req.RequestId, time.Since(start), err)
+		} else {
+			logf("gremlin %s %s completed in %v", req.Op, req.RequestId, time.Since(start))
+			for _, warning := range res.Warnings {
+				logf("gremlin %s %s warning: %s", req.Op, req.RequestId, warning)
+			}
+		}
+		return res, err
+	}
+}
+
+// AliasesInterceptor sets the given aliases on every request that doesn't carry aliases of its own,
+// for example to bind "g" to a tenant's graph traversal source
+func AliasesInterceptor(aliases map[string]string) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		if len(req.Args.Aliases) == 0 {
+			req.Args.Aliases = aliases
+		}
+		return next(ctx, req)
+	}
+}
+
+// ReadOnlyInterceptor rejects every request whose script or bytecode modifies the graph with ReadOnlyError
+func ReadOnlyInterceptor() Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		if req.Args != nil && (IsWriteQuery(req.Args.Gremlin) || IsWriteBytecode(req.Args.Bytecode)) {
+			return nil, ReadOnlyError
+		}
+		return next(ctx, req)
+	}
+}
+
+var writeStepPattern = regexp.MustCompile(`\b(addV|addE|addVertex|addEdge|mergeV|mergeE|property|drop|remove|commit)\s*\(`)
+
+// IsWriteQuery reports whether the gremlin script contains a step that modifies the graph
+func IsWriteQuery(script string) bool {
+	return writeStepPattern.MatchString(script)
+}
+
+var writeSteps = map[string]bool{"addV": true, "addE": true, "mergeV": true, "mergeE": true, "property": true, "drop": true}
+
+// IsWriteBytecode reports whether the bytecode, a Bytecode or a *GraphTraversal, or one of the anonymous
+// traversals passed to its steps contains a step that modifies the graph
+func IsWriteBytecode(bytecode interface{}) bool {
+	switch b := bytecode.(type) {
+	case Bytecode:
+		for _, instructions := range [][][]interface{}{b.Sources, b.Steps} {
+			for _, instruction := range instructions {
+				if len(instruction) == 0 {
+					continue
+				}
+				if name, _ := instruction[0].(string); writeSteps[name] {
+					return true
+				}
+				for _, arg := range instruction[1:] {
+					if IsWriteBytecode(arg) {
+						return true
+					}
+				}
+			}
+		}
+	case *Bytecode:
+		return b != nil && IsWriteBytecode(*b)
+	case *GraphTraversal:
+		return b != nil && IsWriteBytecode(b.bytecode)
+	case []interface{}:
+		for _, item := range b {
+			if IsWriteBytecode(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
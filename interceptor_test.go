@@ -0,0 +1,87 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordingInterceptor(name string, calls *[]string) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		*calls = append(*calls, name+" before")
+		res, err := next(ctx, req)
+		*calls = append(*calls, name+" after")
+		return res, err
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var calls []string
+	cache := func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		calls = append(calls, "cache")
+		return &Result{RequestId: req.RequestId, Data: json.RawMessage(`[1]`)}, nil
+	}
+	c := &Client{}
+	for _, op := range []OptClient{
+		OptClientInterceptors(recordingInterceptor("first", &calls), recordingInterceptor("second", &calls)),
+		OptClientInterceptors(cache),
+	} {
+		op(c)
+	}
+
+	data, err := c.ExecContext(context.Background(), Query("g.V().count()"))
+	assert.Empty(t, err)
+	assert.Equal(t, "[1]", string(data))
+	assert.Equal(t, []string{"first before", "second before", "cache", "second after", "first after"}, calls)
+}
+
+func TestInterceptorRewrite(t *testing.T) {
+	var seen *Request
+	capture := func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		seen = req
+		return &Result{}, nil
+	}
+	aliases := map[string]string{"g": "tenant1.g"}
+	c := &Client{
+		defaults:     RequestOptions{BatchSize: 32},
+		interceptors: []Interceptor{AliasesInterceptor(aliases), capture},
+	}
+
+	req := Query("g.V()")
+	_, err := c.Do(context.Background(), req)
+	assert.Empty(t, err)
+	assert.Equal(t, aliases, seen.Args.Aliases)
+	assert.Equal(t, 32, seen.Args.BatchSize)
+	// the caller's request is not modified by the chain
+	assert.Empty(t, req.Args.Aliases)
+	assert.Equal(t, 0, req.Args.BatchSize)
+}
+
+func TestReadOnlyInterceptor(t *testing.T) {
+	c := &Client{interceptors: []Interceptor{
+		ReadOnlyInterceptor(),
+		func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+			return &Result{}, nil
+		},
+	}}
+	_, err := c.Do(context.Background(), Query("g.V().has('name', 'matilda').valueMap()"))
+	assert.Empty(t, err)
+	_, err = c.Do(context.Background(), Query("g.addV('person').property('name', 'matilda')"))
+	assert.Equal(t, ReadOnlyError, err)
+	_, err = c.Do(context.Background(), Query("g.V().has('name', 'matilda').drop()"))
+	assert.Equal(t, ReadOnlyError, err)
+
+	g := NewGraphTraversalSource(nil, "g")
+	_, err = c.Do(context.Background(), BytecodeRequest(g.V().Has("name", "matilda").ValueMap().Bytecode(), "g"))
+	assert.Empty(t, err)
+	_, err = c.Do(context.Background(), BytecodeRequest(g.AddV("person").Property("name", "matilda").Bytecode(), "g"))
+	assert.Equal(t, ReadOnlyError, err)
+	_, err = c.Do(context.Background(), BytecodeRequest(g.V().Has("name", "matilda").Drop().Bytecode(), "g"))
+	assert.Equal(t, ReadOnlyError, err)
+	// writes hidden in anonymous traversals
+	_, err = c.Do(context.Background(), BytecodeRequest(g.V().Has("name", "matilda").SideEffect(Anon().Property("age", 4)).Bytecode(), "g"))
+	assert.Equal(t, ReadOnlyError, err)
+	_, err = c.Do(context.Background(), BytecodeRequest(g.V().Has("name", "matilda").Fold().Coalesce(Anon().Unfold(), Anon().AddV("person")).Bytecode(), "g"))
+	assert.Equal(t, ReadOnlyError, err)
+}
@@ -0,0 +1,211 @@
+package gremlin
+
+import (
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultKeepAliveInterval matches the keep alive interval of the TinkerPop java driver
+const DefaultKeepAliveInterval = 3 * time.Minute
+
+// how long a keep alive ping may take to be written
+const pingWriteTimeout = 10 * time.Second
+
+// DefaultValidateIdle is how long a pooled connection may sit idle before it is pinged on checkout
+const DefaultValidateIdle = 30 * time.Second
+
+// how long the server may take to answer the ping of a connection being validated
+const validateTimeout = time.Second
+
+// states of a pooled socket
+const (
+	connIdle int32 = iota
+	connInUse
+	connClosed
+)
+
+// connInfo is what the factory knows about each socket it dialed
+type connInfo struct {
+	endpoint	*sync.Map
+	url		string
+	created		time.Time
+	lastUsed	int64 // unix nanoseconds, accessed atomically
+	state		int32 // accessed atomically
+	trial		bool // dialed for a trial request of a half open endpoint
+}
+
+// Sends a websocket ping on every pooled connection at the given interval, so that load balancers
+// and firewalls don't drop idle connections and sockets that were dropped anyway are discarded.
+// Set to 0 to disable keep alive pings. Defaults to DefaultKeepAliveInterval.
+func OptClientKeepAlive(interval time.Duration) OptClient {
+	return func(c *Client) error {
+		c.factory.keepAlive = interval
+		return nil
+	}
+}
+
+// Pings a pooled connection before it is used when it has been idle for longer than the given time, discarding it
+// if the server doesn't answer within a second. Sockets that a load balancer dropped without closing them are
+// then replaced before a request is written to them, instead of failing the request and counting against the
+// endpoint. Set to 0 to disable the ping. Defaults to DefaultValidateIdle.
+func OptClientValidateIdle(d time.Duration) OptClient {
+	return func(c *Client) error {
+		c.factory.validateIdle = d
+		return nil
+	}
+}
+
+// Closes connections that have not been used for the given time
+func OptClientMaxIdleTime(d time.Duration) OptClient {
+	return func(c *Client) error {
+		c.factory.maxIdle = d
+		return nil
+	}
+}
+
+// Closes connections once they have been open for the given time, even if they are healthy
+func OptClientMaxLifetime(d time.Duration) OptClient {
+	return func(c *Client) error {
+		c.factory.maxLifetime = d
+		return nil
+	}
+}
+
+func (f *EndpointFactory) track(ws *websocket.Conn, endpoint *sync.Map, urlStr string) {
+	now := time.Now()
+	f.conns.Store(ws, &connInfo{endpoint: endpoint, url: urlStr, created: now, lastUsed: now.UnixNano()})
+}
+
+func (f *EndpointFactory) connInfo(ws *websocket.Conn) *connInfo {
+	if val, ok := f.conns.Load(ws); ok {
+		return val.(*connInfo)
+	}
+	return nil
+}
+
+// checkout validates a socket taken from the pool. Sockets that were closed by the keep alive,
+// that exceeded their idle time or lifetime, whose endpoint is on ice, or that were idle for a while
+// and don't answer a ping are rejected and have to be discarded.
+func (f *EndpointFactory) checkout(ws *websocket.Conn) bool {
+	info := f.connInfo(ws)
+	if info == nil {
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&info.state, connIdle, connInUse) {
+		f.conns.Delete(ws)
+		return false
+	}
+	now := time.Now()
+	if f.expired(info, now) || !f.endpointUsable(info) || (f.validateIdle > 0 && f.idleFor(info, now) > f.validateIdle && !ping(ws)) {
+		atomic.StoreInt32(&info.state, connClosed)
+		f.conns.Delete(ws)
+		return false
+	}
+	return true
+}
+
+func (f *EndpointFactory) idleFor(info *connInfo, now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&info.lastUsed)))
+}
+
+// ping reports whether the server answers a ping within validateTimeout. Only the socket's owner reads
+// from it, so rather than reading the pong through the websocket, which can't give up waiting without
+// breaking the socket, the bytes of the answer are awaited on the network connection underneath.
+func ping(ws *websocket.Conn) bool {
+	conn := ws.UnderlyingConn()
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	cc, ok := conn.(*countingConn)
+	if !ok {
+		return true
+	}
+	// what the server sent earlier, such as the pongs of keep alive pings, says nothing about now
+	cc.await(time.Now().Add(time.Millisecond))
+	deadline := time.Now().Add(validateTimeout)
+	if err := ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+		return false
+	}
+	return cc.await(deadline) == nil
+}
+
+// checkin marks a socket as idle again once a request is done with it, or forgets it if it is being discarded
+func (f *EndpointFactory) checkin(ws *websocket.Conn, usable bool) {
+	info := f.connInfo(ws)
+	if info == nil {
+		return
+	}
+	if !usable {
+		atomic.StoreInt32(&info.state, connClosed)
+		f.conns.Delete(ws)
+		return
+	}
+	atomic.StoreInt64(&info.lastUsed, time.Now().UnixNano())
+	atomic.StoreInt32(&info.state, connIdle)
+}
+
+func (f *EndpointFactory) expired(info *connInfo, now time.Time) bool {
+	if f.maxLifetime > 0 && now.Sub(info.created) > f.maxLifetime {
+		return true
+	}
+	return f.maxIdle > 0 && f.idleFor(info, now) > f.maxIdle
+}
+
+// maintenanceInterval is how often idle sockets are pinged and checked for expiry, 0 if never
+func (f *EndpointFactory) maintenanceInterval() time.Duration {
+	interval := f.keepAlive
+	for _, d := range []time.Duration{f.maxIdle / 2, f.maxLifetime / 2} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// maintain runs sweep at the maintenance interval until done is closed
+func (f *EndpointFactory) maintain(done <-chan struct{}) {
+	interval := f.maintenanceInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			f.sweep(now)
+		}
+	}
+}
+
+// sweep closes idle sockets that expired and pings the rest. Closed sockets stay in the pool
+// until they are taken out, at which point checkout rejects them.
+func (f *EndpointFactory) sweep(now time.Time) {
+	f.conns.Range(func(key, value interface{}) bool {
+		ws, info := key.(*websocket.Conn), value.(*connInfo)
+		idle := atomic.LoadInt32(&info.state) == connIdle
+		if idle && f.expired(info, now) {
+			f.closeIdle(ws, info)
+			return true
+		}
+		if f.keepAlive > 0 {
+			// control frames may be written concurrently with a request using the socket
+			if err := ws.WriteControl(websocket.PingMessage, nil, now.Add(pingWriteTimeout)); err != nil && idle {
+				f.closeIdle(ws, info)
+			}
+		}
+		return true
+	})
+}
+
+func (f *EndpointFactory) closeIdle(ws *websocket.Conn, info *connInfo) {
+	if atomic.CompareAndSwapInt32(&info.state, connIdle, connClosed) {
+		ws.Close()
+		f.conns.Delete(ws)
+	}
+}
@@ -0,0 +1,139 @@
+package gremlin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepAlivePings(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientKeepAlive(20*time.Millisecond))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, server.pingsReceived() >= 2)
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+}
+
+func TestMaxIdleTime(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientKeepAlive(0), OptClientMaxIdleTime(30*time.Millisecond))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(0), cl.Stats().Reconnects)
+
+	// the idle socket is replaced by a fresh one
+	time.Sleep(80 * time.Millisecond)
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(1), cl.Stats().Reconnects)
+}
+
+func TestMaxLifetime(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientKeepAlive(0), OptClientMaxLifetime(10*time.Millisecond))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(1), cl.Stats().Reconnects)
+}
+
+func TestValidateIdle(t *testing.T) {
+	// the first socket goes quiet after its first request, as if a load balancer had dropped it
+	server := &testServer{respond: successResponse("[1]")}
+	quiet := make(chan struct{})
+	var sockets int32
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&sockets, 1) > 1 {
+			server.serve(w, r)
+			return
+		}
+		ws, err := server.Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		req := &testRequest{}
+		json.Unmarshal(msg[int(msg[0])+1:], req)
+		ws.WriteJSON(testResponse(req, StatusSuccess, "[1]"))
+		<-quiet
+	}))
+	server.Url = "ws" + strings.TrimPrefix(server.Server.URL, "http")
+	defer server.Close()
+	defer close(quiet)
+	cl, err := NewClientWithOptions(server.Url, OptClientKeepAlive(0), OptClientValidateIdle(20*time.Millisecond))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	// a socket used recently isn't pinged
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(0), cl.Stats().Reconnects)
+
+	// the idle socket doesn't answer its ping and is replaced, without counting against the endpoint
+	time.Sleep(40 * time.Millisecond)
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(1), cl.Stats().Reconnects)
+	assert.Equal(t, 0, cl.Endpoints()[0].ErrorScore)
+
+	// a healthy idle socket answers and is used again
+	time.Sleep(40 * time.Millisecond)
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(1), cl.Stats().Reconnects)
+	assert.Equal(t, 1, server.pingsReceived())
+}
+
+func TestDroppedConnectionIsRetried(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientKeepAlive(0))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	// break the pooled socket behind the client's back
+	con, err := cl.transport.(*wsTransport).pool.Get()
+	assert.Empty(t, err)
+	con.UnderlyingConn().Close()
+	con.Close()
+
+	data, err := cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, "[1]", string(data))
+	for _, endpoint := range cl.Endpoints() {
+		assert.False(t, endpoint.OnIce)
+		assert.Equal(t, 0, endpoint.ErrorScore)
+	}
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var UsageError = errors.New("usage: list | up [version] | down [steps]")
+
+// Run executes a command line: list shows every migration and whether it has been applied, up applies
+// the pending migrations up to an optional version, and down rolls back the given number of migrations, 1 by default.
+// It lets programs with migrations written in Go offer the same commands as cmd/gremlin-migrate.
+func Run(ctx context.Context, m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return UsageError
+	}
+	var n int64
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.ParseInt(args[1], 10, 64); err != nil || n < 0 {
+			return UsageError
+		}
+	}
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return UsageError
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case "up":
+		done, err := m.Up(ctx, n)
+		report(w, "applied", done)
+		return err
+	case "down":
+		if len(args) == 1 {
+			n = 1
+		}
+		done, err := m.Down(ctx, int(n))
+		report(w, "rolled back", done)
+		return err
+	}
+	return UsageError
+}
+
+func report(w io.Writer, action string, migrations []Migration) {
+	for _, m := range migrations {
+		fmt.Fprintf(w, "%s %d %s\n", action, m.Version, m.Name)
+	}
+	if len(migrations) == 0 {
+		fmt.Fprintln(w, "nothing to do")
+	}
+}
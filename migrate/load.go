@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+)
+
+// migrationFile matches file names such as 0001_add_people.up.groovy
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.(groovy|gremlin)$`)
+
+// Load reads Gremlin script migrations from the files in the root of fsys. Files are named
+// <version>_<name>.up.groovy and <version>_<name>.down.groovy, the down file is optional.
+// Other files are ignored, and the .gremlin extension may be used instead of .groovy.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	type scripts struct {
+		name     string
+		up, down string
+		hasUp    bool
+	}
+	byVersion := map[int64]*scripts{}
+	var versions []int64
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", entry.Name(), err)
+		}
+		s := byVersion[version]
+		if s == nil {
+			s = &scripts{name: match[2]}
+			byVersion[version] = s
+			versions = append(versions, version)
+		}
+		if s.name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is also used by %s", entry.Name(), version, s.name)
+		}
+		b, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			s.up, s.hasUp = string(b), true
+		} else {
+			s.down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		s := byVersion[version]
+		if !s.hasUp {
+			return nil, fmt.Errorf("migration %d %s has no up script", version, s.name)
+		}
+		migrations = append(migrations, Script(version, s.name, s.up, s.down))
+	}
+	return migrations, nil
+}
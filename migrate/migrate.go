@@ -0,0 +1,366 @@
+// Package migrate runs versioned migrations against a graph. The versions that have been applied are
+// stored on a marker vertex, and a lock on the marker keeps concurrent deployers from running migrations
+// at the same time. Each migration runs in a session and is committed together with the update of the marker.
+//
+// The lock is taken by a single conditional traversal in its own transaction. Graphs with optimistic or
+// eventually consistent transactions, such as JanusGraph on Cassandra, may still commit two deployers taking
+// it at the same time. On JanusGraph, create the marker's unique index and give it and the lockedBy property
+// LOCK consistency before running migrations from several places:
+//
+//	marker = mgmt.makePropertyKey('marker').dataType(String.class).make()
+//	lockedBy = mgmt.makePropertyKey('lockedBy').dataType(String.class).make()
+//	index = mgmt.buildIndex('migrationsByMarker', Vertex.class).addKey(marker).unique().buildCompositeIndex()
+//	mgmt.setConsistency(index, ConsistencyModifier.LOCK)
+//	mgmt.setConsistency(lockedBy, ConsistencyModifier.LOCK)
+//
+//	migrator, err := migrate.New(client, migrations, migrate.Options{})
+//	applied, err := migrator.Up(ctx, 0)
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+var (
+	DuplicateVersionError = errors.New("two migrations have the same version")
+	InvalidVersionError   = errors.New("migration versions must be greater than zero")
+)
+
+// Migration changes the graph from the previous version to its own. Up and Down run inside the session's
+// transaction, which is committed once they return without an error. Down may be nil for migrations that
+// can't be rolled back.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, s *gremlin.Session) error
+	Down    func(ctx context.Context, s *gremlin.Session) error
+}
+
+// Script returns a migration that evaluates Gremlin scripts. An empty down script makes the migration irreversible.
+func Script(version int64, name, up, down string) Migration {
+	m := Migration{Version: version, Name: name, Up: exec(up)}
+	if down != "" {
+		m.Down = exec(down)
+	}
+	return m
+}
+
+func exec(script string) func(ctx context.Context, s *gremlin.Session) error {
+	return func(ctx context.Context, s *gremlin.Session) error {
+		_, err := s.Exec(ctx, script, nil)
+		return err
+	}
+}
+
+// LockedError is returned when another deployer holds the lock on the marker
+type LockedError struct {
+	Owner string
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("migrations are locked by %s until %s", e.Owner, e.Until.Format(time.RFC3339))
+}
+
+// MigrationError is returned when a migration fails, the migration's transaction has been rolled back
+type MigrationError struct {
+	Version int64
+	Name    string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %d %s: %s", e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// IrreversibleError is returned when rolling back a migration without a Down function
+type IrreversibleError struct {
+	Version int64
+}
+
+func (e *IrreversibleError) Error() string {
+	return fmt.Sprintf("migration %d can't be rolled back", e.Version)
+}
+
+// UnknownVersionError is returned when rolling back a version that isn't one of the migrations
+type UnknownVersionError struct {
+	Version int64
+}
+
+func (e *UnknownVersionError) Error() string {
+	return fmt.Sprintf("version %d has been applied but there is no migration for it", e.Version)
+}
+
+type Options struct {
+	// Label of the marker vertex, "migrations" by default
+	Label string
+	// Marker names the set of migrations, so several sets can be kept in one graph. "default" by default.
+	Marker string
+	// LockTTL is how long the lock is held before another deployer may take it over, 15 minutes by default.
+	// Migrations are expected to finish well within it.
+	LockTTL time.Duration
+	// Owner identifies the deployer in the lock, the host name and process id by default
+	Owner string
+}
+
+// Migrator applies and rolls back migrations
+type Migrator struct {
+	client     *gremlin.Client
+	migrations []Migration
+	opts       Options
+}
+
+// New returns a migrator for the given migrations, which are sorted by version
+func New(client *gremlin.Client, migrations []Migration, opts Options) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, InvalidVersionError
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, DuplicateVersionError
+		}
+	}
+	if opts.Label == "" {
+		opts.Label = "migrations"
+	}
+	if opts.Marker == "" {
+		opts.Marker = "default"
+	}
+	if opts.LockTTL == 0 {
+		opts.LockTTL = 15 * time.Minute
+	}
+	if opts.Owner == "" {
+		host, _ := os.Hostname()
+		opts.Owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &Migrator{client: client, migrations: sorted, opts: opts}, nil
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Status lists the migrations with their state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration, Applied: applied[migration.Version]}
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations in order, up to and including the target version, or all of them
+// when target is 0. It returns the migrations that were applied, and stops at the first one that fails.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(applied map[int64]bool) error {
+		for _, migration := range m.migrations {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if applied[migration.Version] {
+				continue
+			}
+			applied[migration.Version] = true
+			if err := m.run(ctx, migration, migration.Up, applied); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the given number of most recently applied migrations, newest first.
+// It returns the migrations that were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(applied map[int64]bool) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+		for _, version := range versions {
+			migration, ok := m.migration(version)
+			if !ok {
+				return &UnknownVersionError{Version: version}
+			}
+			if migration.Down == nil {
+				return &IrreversibleError{Version: version}
+			}
+			delete(applied, version)
+			if err := m.run(ctx, migration, migration.Down, applied); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) migration(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// run executes fn in a session and stores the applied versions in the same transaction
+func (m *Migrator) run(ctx context.Context, migration Migration, fn func(ctx context.Context, s *gremlin.Session) error, applied map[int64]bool) error {
+	session := m.client.NewSession()
+	defer func() {
+		// ctx may be done by now, the session would be left open on the server
+		closeCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		session.Close(closeCtx)
+	}()
+	err := session.Transaction(ctx, func(s *gremlin.Session) error {
+		if err := fn(ctx, s); err != nil {
+			return err
+		}
+		_, err := s.Exec(ctx, "g.V().has(markerLabel, 'marker', marker).property('versions', versions).iterate()", m.bindings(gremlin.Bind{
+			"versions": formatVersions(applied),
+		}))
+		return err
+	})
+	if err != nil {
+		return &MigrationError{Version: migration.Version, Name: migration.Name, Err: err}
+	}
+	return nil
+}
+
+func (m *Migrator) bindings(extra gremlin.Bind) gremlin.Bind {
+	b := gremlin.Bind{"markerLabel": m.opts.Label, "marker": m.opts.Marker}
+	for k, v := range extra {
+		b[k] = v
+	}
+	return b
+}
+
+// applied reads the applied versions from the marker
+func (m *Migrator) applied(ctx context.Context) (map[int64]bool, error) {
+	res, err := m.client.Do(ctx, gremlin.Query("g.V().has(markerLabel, 'marker', marker).values('versions')").Bindings(m.bindings(nil)))
+	if err != nil {
+		return nil, err
+	}
+	values, err := res.Values()
+	if err != nil {
+		return nil, err
+	}
+	var versions string
+	if len(values) > 0 {
+		versions, _ = values[0].(string)
+	}
+	return parseVersions(versions)
+}
+
+// cleanupTimeout limits releasing the lock and closing a session, which happen even when the context is done
+const cleanupTimeout = 30 * time.Second
+
+// lockScript takes the lock unless someone else holds it, creating the marker if needed, and returns the
+// owner and expiry of the lock. The lock is checked and taken by a single traversal, committed on its own.
+const lockScript = `now = System.currentTimeMillis()
+lock = g.V().has(markerLabel, 'marker', marker).fold().
+  coalesce(__.unfold(), __.addV(markerLabel).property('marker', marker).property('versions', '')).
+  choose(__.or(__.hasNot('lockedBy'), __.has('lockedBy', owner), __.has('lockedUntil', lt(now))),
+    __.property('lockedBy', owner).property('lockedUntil', now + ttl), __.identity()).
+  project('lockedBy', 'lockedUntil').by('lockedBy').by('lockedUntil').next()
+g.tx().commit()
+[lock['lockedBy'], lock['lockedUntil']]`
+
+// locked runs fn while holding the lock on the marker. The lock is only exclusive when the graph serializes
+// the transactions taking it, see the package documentation for JanusGraph.
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int64]bool) error) (err error) {
+	res, err := m.client.Do(ctx, gremlin.Query(lockScript).Bindings(m.bindings(gremlin.Bind{
+		"owner": m.opts.Owner,
+		"ttl":   int64(m.opts.LockTTL / time.Millisecond),
+	})))
+	if err != nil {
+		return err
+	}
+	values, err := res.Values()
+	if err != nil {
+		return err
+	}
+	if len(values) != 2 {
+		return fmt.Errorf("unexpected lock state %v", values)
+	}
+	if owner, _ := values[0].(string); owner != m.opts.Owner {
+		until, _ := values[1].(int64)
+		return &LockedError{Owner: owner, Until: time.Unix(0, until*int64(time.Millisecond))}
+	}
+	defer func() {
+		// the lock is released even when ctx is done, otherwise other deployers wait for it to expire
+		unlockCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		_, uerr := m.client.Do(unlockCtx, gremlin.Query(
+			"g.V().has(markerLabel, 'marker', marker).has('lockedBy', owner).properties('lockedBy', 'lockedUntil').drop()",
+		).Bindings(m.bindings(gremlin.Bind{"owner": m.opts.Owner})))
+		if uerr != nil {
+			err = errors.Join(err, fmt.Errorf("releasing the lock: %w", uerr))
+		}
+	}()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(applied)
+}
+
+func formatVersions(applied map[int64]bool) string {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	s := make([]string, len(versions))
+	for i, version := range versions {
+		s[i] = strconv.FormatInt(version, 10)
+	}
+	return strings.Join(s, ",")
+}
+
+func parseVersions(s string) (map[int64]bool, error) {
+	applied := map[int64]bool{}
+	for _, field := range strings.Split(s, ",") {
+		if field == "" {
+			continue
+		}
+		version, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the marker holds an invalid version %q", field)
+		}
+		applied[version] = true
+	}
+	return applied, nil
+}
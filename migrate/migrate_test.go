@@ -0,0 +1,311 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGraph holds the state of the marker vertex and the scripts run by migrations
+type fakeGraph struct {
+	mu       sync.Mutex
+	versions string
+	lockedBy string
+	locks    int // lock scripts received
+	scripts  []string
+	fail     string // scripts containing fail are answered with a server error
+}
+
+func (g *fakeGraph) respond(script string, bindings map[string]json.RawMessage) (int, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	binding := func(name string) string {
+		var s string
+		json.Unmarshal(bindings[name], &s)
+		return s
+	}
+	switch {
+	case g.fail != "" && strings.Contains(script, g.fail):
+		return gremlin.StatusServerError, `[]`
+	case script == lockScript:
+		g.locks++
+		if g.lockedBy == "" {
+			g.lockedBy = binding("owner")
+		}
+		return gremlin.StatusSuccess, `[` + strconvQuote(g.lockedBy) + `, {"@type": "g:Int64", "@value": 1700000000000}]`
+	case strings.Contains(script, "values('versions')"):
+		return gremlin.StatusSuccess, `[` + strconvQuote(g.versions) + `]`
+	case strings.Contains(script, "property('versions', versions)"):
+		g.versions = binding("versions")
+	case strings.Contains(script, "properties('lockedBy', 'lockedUntil').drop()"):
+		if g.lockedBy == binding("owner") {
+			g.lockedBy = ""
+		}
+	default:
+		g.scripts = append(g.scripts, script)
+	}
+	return gremlin.StatusSuccess, `[]`
+}
+
+func strconvQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func newServer(graph *fakeGraph) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// skip the mime type header
+			var req struct {
+				RequestId struct {
+					Value string `json:"@value"`
+				} `json:"requestId"`
+				Op   string `json:"op"`
+				Args struct {
+					Gremlin  string                     `json:"gremlin"`
+					Bindings map[string]json.RawMessage `json:"bindings"`
+				} `json:"args"`
+			}
+			json.Unmarshal(msg[int(msg[0])+1:], &req)
+			code, data := gremlin.StatusSuccess, `[]`
+			if req.Op != "close" {
+				code, data = graph.respond(req.Args.Gremlin, req.Args.Bindings)
+			}
+			ws.WriteJSON(gremlin.Response{
+				RequestId: req.RequestId.Value,
+				Status:    &gremlin.ResponseStatus{Code: code},
+				Result:    &gremlin.ResponseResult{Data: json.RawMessage(data)},
+			})
+		}
+	}))
+}
+
+func newMigrator(t *testing.T, server *httptest.Server, migrations []Migration) *Migrator {
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(client.Close)
+	m, err := New(client, migrations, Options{Owner: "deployer"})
+	if !assert.Empty(t, err) {
+		t.FailNow()
+	}
+	return m
+}
+
+var migrations = []Migration{
+	Script(2, "add_knows", "g.addE('knows')", "g.E().hasLabel('knows').drop()"),
+	Script(1, "add_people", "g.addV('person')", "g.V().hasLabel('person').drop()"),
+	Script(3, "backfill", "g.V().property('seen', true)", ""),
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, []Migration{Script(1, "a", "x", ""), Script(1, "b", "y", "")}, Options{})
+	assert.Equal(t, DuplicateVersionError, err)
+	_, err = New(nil, []Migration{Script(0, "a", "x", "")}, Options{})
+	assert.Equal(t, InvalidVersionError, err)
+
+	m, err := New(nil, migrations, Options{})
+	if assert.Empty(t, err) {
+		assert.Equal(t, []int64{1, 2, 3}, []int64{m.migrations[0].Version, m.migrations[1].Version, m.migrations[2].Version})
+		assert.Equal(t, "migrations", m.opts.Label)
+		assert.Equal(t, "default", m.opts.Marker)
+		assert.Equal(t, 15*time.Minute, m.opts.LockTTL)
+		assert.NotEmpty(t, m.opts.Owner)
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	graph := &fakeGraph{}
+	server := newServer(graph)
+	defer server.Close()
+	m := newMigrator(t, server, migrations)
+	ctx := context.Background()
+
+	done, err := m.Up(ctx, 2)
+	assert.Empty(t, err)
+	assert.Len(t, done, 2)
+	assert.Equal(t, "1,2", graph.versions)
+	assert.Equal(t, []string{"g.addV('person')", "g.tx().commit()", "g.addE('knows')", "g.tx().commit()"}, graph.scripts)
+	assert.Equal(t, "", graph.lockedBy)
+
+	done, err = m.Up(ctx, 0)
+	assert.Empty(t, err)
+	if assert.Len(t, done, 1) {
+		assert.Equal(t, int64(3), done[0].Version)
+	}
+	assert.Equal(t, "1,2,3", graph.versions)
+
+	statuses, err := m.Status(ctx)
+	assert.Empty(t, err)
+	for _, s := range statuses {
+		assert.True(t, s.Applied)
+	}
+
+	_, err = m.Down(ctx, 1)
+	assert.Equal(t, &IrreversibleError{Version: 3}, err)
+
+	graph.versions = "1,2"
+	graph.scripts = nil
+	done, err = m.Down(ctx, 5)
+	assert.Empty(t, err)
+	if assert.Len(t, done, 2) {
+		assert.Equal(t, int64(2), done[0].Version)
+		assert.Equal(t, int64(1), done[1].Version)
+	}
+	assert.Equal(t, "", graph.versions)
+	assert.Equal(t, "g.E().hasLabel('knows').drop()", graph.scripts[0])
+
+	graph.versions = "9"
+	_, err = m.Down(ctx, 1)
+	assert.Equal(t, &UnknownVersionError{Version: 9}, err)
+}
+
+func TestUpFailure(t *testing.T) {
+	graph := &fakeGraph{fail: "addE"}
+	server := newServer(graph)
+	defer server.Close()
+	m := newMigrator(t, server, migrations)
+
+	done, err := m.Up(context.Background(), 0)
+	assert.Len(t, done, 1)
+	var migrationErr *MigrationError
+	if assert.True(t, errors.As(err, &migrationErr)) {
+		assert.Equal(t, int64(2), migrationErr.Version)
+		assert.True(t, errors.Is(err, gremlin.ConnectionErrors[gremlin.StatusServerError]))
+	}
+	assert.Equal(t, "1", graph.versions)
+	assert.Contains(t, graph.scripts, "g.tx().rollback()")
+}
+
+func TestUnlockFailure(t *testing.T) {
+	graph := &fakeGraph{fail: "properties('lockedBy', 'lockedUntil').drop()"}
+	server := newServer(graph)
+	defer server.Close()
+	m := newMigrator(t, server, migrations)
+
+	done, err := m.Up(context.Background(), 0)
+	assert.Len(t, done, 3)
+	assert.True(t, errors.Is(err, gremlin.ConnectionErrors[gremlin.StatusServerError]))
+	assert.Contains(t, err.Error(), "releasing the lock")
+	assert.Equal(t, "1,2,3", graph.versions)
+}
+
+func TestLocked(t *testing.T) {
+	graph := &fakeGraph{lockedBy: "someone else"}
+	server := newServer(graph)
+	defer server.Close()
+	m := newMigrator(t, server, migrations)
+
+	_, err := m.Up(context.Background(), 0)
+	assert.Equal(t, &LockedError{Owner: "someone else", Until: time.Unix(1700000000, 0)}, err)
+	assert.Empty(t, graph.scripts)
+	assert.Equal(t, "someone else", graph.lockedBy)
+	assert.Equal(t, 1, graph.locks)
+}
+
+func TestConcurrentDeployers(t *testing.T) {
+	graph := &fakeGraph{}
+	server := newServer(graph)
+	defer server.Close()
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		m, err := New(client, migrations, Options{Owner: fmt.Sprintf("deployer-%d", i)})
+		if !assert.Empty(t, err) {
+			return
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = m.Up(context.Background(), 0)
+		}(i)
+	}
+	wg.Wait()
+
+	// every migration ran once, by whoever held the lock
+	assert.Equal(t, "1,2,3", graph.versions)
+	assert.Len(t, graph.scripts, 6)
+	for _, err := range errs {
+		if err != nil {
+			var locked *LockedError
+			assert.True(t, errors.As(err, &locked), err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load(fstest.MapFS{
+		"0002_add_knows.up.groovy":    {Data: []byte("g.addE('knows')")},
+		"0001_add_people.up.gremlin":  {Data: []byte("g.addV('person')")},
+		"0001_add_people.down.groovy": {Data: []byte("g.V().drop()")},
+		"README.md":                   {Data: []byte("migrations")},
+	})
+	assert.Empty(t, err)
+	if assert.Len(t, loaded, 2) {
+		assert.Equal(t, int64(1), loaded[0].Version)
+		assert.Equal(t, "add_people", loaded[0].Name)
+		assert.NotNil(t, loaded[0].Down)
+		assert.Equal(t, "add_knows", loaded[1].Name)
+		assert.Nil(t, loaded[1].Down)
+	}
+
+	_, err = Load(fstest.MapFS{"0001_add_people.down.groovy": {Data: []byte("g.V().drop()")}})
+	assert.NotEmpty(t, err)
+	_, err = Load(fstest.MapFS{
+		"0001_add_people.up.groovy": {Data: []byte("g.addV()")},
+		"0001_add_knows.up.groovy":  {Data: []byte("g.addE()")},
+	})
+	assert.NotEmpty(t, err)
+}
+
+func TestRun(t *testing.T) {
+	graph := &fakeGraph{}
+	server := newServer(graph)
+	defer server.Close()
+	m := newMigrator(t, server, migrations)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	assert.Empty(t, Run(ctx, m, []string{"up", "1"}, &out))
+	assert.Empty(t, Run(ctx, m, []string{"list"}, &out))
+	assert.Empty(t, Run(ctx, m, []string{"down"}, &out))
+	assert.Empty(t, Run(ctx, m, []string{"down"}, &out))
+	assert.Equal(t, "applied 1 add_people\n"+
+		"1\tadd_people\tapplied\n2\tadd_knows\tpending\n3\tbackfill\tpending\n"+
+		"rolled back 1 add_people\n"+
+		"nothing to do\n", out.String())
+
+	assert.Equal(t, UsageError, Run(ctx, m, nil, &out))
+	assert.Equal(t, UsageError, Run(ctx, m, []string{"sideways"}, &out))
+	assert.Equal(t, UsageError, Run(ctx, m, []string{"down", "x"}, &out))
+}
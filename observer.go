@@ -0,0 +1,62 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// RequestInfo describes a single request execution. It is filled in as the request progresses
+// and handed to the client's Observer once the request has completed.
+type RequestInfo struct {
+	RequestId   string
+	Op          string
+	Processor   string
+	Endpoint    string
+	StatusCode  int
+	ResultCount int
+	BatchCount  int
+	// Attempts is the number of messages written to the server for this request, including
+	// the responses to any authentication challenge
+	Attempts int
+	Duration time.Duration
+	// Warnings sent by the server along with the response
+	Warnings []string
+	// Batches describes each response message the server sent
+	Batches []Batch
+
+	onBatch func(items []json.RawMessage) // set by withBatchHandler
+}
+
+func (info *RequestInfo) lastBatch() *Batch {
+	return &info.Batches[len(info.Batches)-1]
+}
+
+// Observer is notified about every request a client executes. It is the hook used by
+// instrumentation such as the otelgremlin package to create spans and record metrics.
+type Observer interface {
+	// RequestStarted is called before the request is sent. The returned context is passed to RequestFinished.
+	RequestStarted(ctx context.Context, req *Request) context.Context
+	// RequestFinished is called once the response has been read, with the error returned to the caller, if any.
+	RequestFinished(ctx context.Context, info *RequestInfo, err error)
+}
+
+// EndpointStatus is a snapshot of the health of a single server
+type EndpointStatus struct {
+	Url        string
+	ErrorScore int
+	OnIce      bool
+	OnIceUntil time.Time
+	// State is the state of the endpoint's circuit breaker, OnIce is set while it is open
+	State CircuitState
+	// LastError is the last error counted against the endpoint
+	LastError error
+}
+
+// Sets the observer notified about every request executed by the client
+func OptClientObserver(observer Observer) OptClient {
+	return func(c *Client) error {
+		c.observer = observer
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+// Package otelgremlin instruments gremlin clients with OpenTelemetry.
+//
+// Tracing and request metrics are provided by an Observer that is registered on the client:
+//
+//	observer, err := otelgremlin.NewObserver()
+//	client, err := gremlin.NewClientWithOptions(servers, gremlin.OptClientObserver(observer))
+//	otelgremlin.RegisterMetrics(client)
+//
+// Spans are created as children of the span held in the context passed to Client.ExecContext.
+// Requests carry the request span to the server in the fields written by the global propagator, set one
+// with otel.SetTextMapPropagator. Requests sent over http carry them in headers, websocket requests in the
+// traceContext request argument, for example {"traceparent": "00-…"}, which a server plugin can read.
+package otelgremlin
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-gremlin/gremlin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/go-gremlin/gremlin/otelgremlin"
+
+// Attribute keys set on spans and metrics
+const (
+	SystemKey      = attribute.Key("db.system")
+	EndpointKey    = attribute.Key("gremlin.endpoint")
+	OpKey          = attribute.Key("gremlin.op")
+	ProcessorKey   = attribute.Key("gremlin.processor")
+	RequestIdKey   = attribute.Key("gremlin.request_id")
+	StatusCodeKey  = attribute.Key("gremlin.status_code")
+	ResultCountKey = attribute.Key("gremlin.result_count")
+	BatchCountKey  = attribute.Key("gremlin.batch_count")
+	AttemptsKey    = attribute.Key("gremlin.attempts")
+	WarningKey     = attribute.Key("gremlin.warning")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the instrumentation
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider used to create spans. The global provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider used to record metrics. The global provider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, op := range opts {
+		op(c)
+	}
+	return c
+}
+
+type observer struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	requests metric.Int64Counter
+}
+
+// NewObserver returns a gremlin.Observer that creates a span for every request and records
+// request counts and latencies
+func NewObserver(opts ...Option) (gremlin.Observer, error) {
+	c := newConfig(opts)
+	meter := c.meterProvider.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("gremlin.client.request.duration",
+		metric.WithDescription("Duration of gremlin requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	requests, err := meter.Int64Counter("gremlin.client.requests",
+		metric.WithDescription("Number of gremlin requests executed"))
+	if err != nil {
+		return nil, err
+	}
+	return &observer{
+		tracer:   c.tracerProvider.Tracer(instrumentationName),
+		duration: duration,
+		requests: requests,
+	}, nil
+}
+
+func (o *observer) RequestStarted(ctx context.Context, req *gremlin.Request) context.Context {
+	ctx, _ = o.tracer.Start(ctx, "gremlin "+req.Op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			SystemKey.String("gremlin"),
+			OpKey.String(req.Op),
+			ProcessorKey.String(req.Processor),
+			RequestIdKey.String(req.RequestId),
+		))
+	return ctx
+}
+
+func (o *observer) RequestFinished(ctx context.Context, info *gremlin.RequestInfo, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		EndpointKey.String(info.Endpoint),
+		StatusCodeKey.Int(info.StatusCode),
+		ResultCountKey.Int(info.ResultCount),
+		BatchCountKey.Int(info.BatchCount),
+		AttemptsKey.Int(info.Attempts),
+	)
+	for _, warning := range info.Warnings {
+		span.AddEvent("warning", trace.WithAttributes(WarningKey.String(warning)))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	attrs := metric.WithAttributes(
+		OpKey.String(info.Op),
+		ProcessorKey.String(info.Processor),
+		StatusCodeKey.Int(info.StatusCode),
+	)
+	o.requests.Add(ctx, 1, attrs)
+	o.duration.Record(ctx, float64(info.Duration)/float64(time.Second), attrs)
+}
+
+// RegisterMetrics registers asynchronous instruments reporting the client's pool size,
+// in-flight requests and the error score and ice state of each endpoint.
+// Unregister the returned registration when the client is closed.
+func RegisterMetrics(client *gremlin.Client, opts ...Option) (metric.Registration, error) {
+	c := newConfig(opts)
+	meter := c.meterProvider.Meter(instrumentationName)
+	poolSize, err := meter.Int64ObservableGauge("gremlin.client.pool.idle",
+		metric.WithDescription("Number of idle connections in the pool"))
+	if err != nil {
+		return nil, err
+	}
+	inFlight, err := meter.Int64ObservableUpDownCounter("gremlin.client.requests.in_flight",
+		metric.WithDescription("Number of requests currently being executed"))
+	if err != nil {
+		return nil, err
+	}
+	errorScore, err := meter.Int64ObservableGauge("gremlin.endpoint.error_score",
+		metric.WithDescription("Error score of the endpoint, higher scores keep it on ice for longer"))
+	if err != nil {
+		return nil, err
+	}
+	onIce, err := meter.Int64ObservableGauge("gremlin.endpoint.on_ice",
+		metric.WithDescription("1 if the endpoint is currently on ice, 0 otherwise"))
+	if err != nil {
+		return nil, err
+	}
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(poolSize, int64(client.PoolSize()))
+		o.ObserveInt64(inFlight, int64(client.InFlight()))
+		for _, status := range client.Endpoints() {
+			attrs := metric.WithAttributes(EndpointKey.String(status.Url))
+			o.ObserveInt64(errorScore, int64(status.ErrorScore), attrs)
+			var iced int64
+			if status.OnIce {
+				iced = 1
+			}
+			o.ObserveInt64(onIce, iced, attrs)
+		}
+		return nil
+	}, poolSize, inFlight, errorScore, onIce)
+}
@@ -0,0 +1,175 @@
+package otelgremlin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attributeMap(attrs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func findMetric(rm metricdata.ResourceMetrics, name string) *metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for i := range sm.Metrics {
+			if sm.Metrics[i].Name == name {
+				return &sm.Metrics[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestObserverSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	obs, err := NewObserver(WithTracerProvider(tp), WithMeterProvider(mp))
+	assert.Empty(t, err)
+
+	// the request span is a child of the span in the context
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	req := gremlin.Query("g.V().count()")
+	ctx := obs.RequestStarted(parentCtx, req)
+	obs.RequestFinished(ctx, &gremlin.RequestInfo{
+		RequestId:   req.RequestId,
+		Op:          req.Op,
+		Endpoint:    "127.0.0.1:8182",
+		StatusCode:  gremlin.StatusSuccess,
+		ResultCount: 3,
+		BatchCount:  2,
+		Attempts:    1,
+		Duration:    10 * time.Millisecond,
+	}, nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	span := spans[0]
+	assert.Equal(t, "gremlin eval", span.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	attrs := attributeMap(span.Attributes())
+	assert.Equal(t, "127.0.0.1:8182", attrs[EndpointKey].AsString())
+	assert.Equal(t, "eval", attrs[OpKey].AsString())
+	assert.Equal(t, int64(200), attrs[StatusCodeKey].AsInt64())
+	assert.Equal(t, int64(3), attrs[ResultCountKey].AsInt64())
+	assert.Equal(t, int64(2), attrs[BatchCountKey].AsInt64())
+	assert.Equal(t, int64(1), attrs[AttemptsKey].AsInt64())
+
+	// failed requests mark the span as errored
+	ctx = obs.RequestStarted(context.Background(), req)
+	obs.RequestFinished(ctx, &gremlin.RequestInfo{Op: req.Op, StatusCode: gremlin.StatusScriptEvaluationError}, errors.New("script evaluation error"))
+	spans = recorder.Ended()
+	assert.Equal(t, codes.Error, spans[len(spans)-1].Status().Code)
+
+	var rm metricdata.ResourceMetrics
+	assert.Empty(t, reader.Collect(context.Background(), &rm))
+	requests := findMetric(rm, "gremlin.client.requests")
+	if assert.NotNil(t, requests) {
+		var total int64
+		for _, dp := range requests.Data.(metricdata.Sum[int64]).DataPoints {
+			total += dp.Value
+		}
+		assert.Equal(t, int64(2), total)
+	}
+	assert.NotNil(t, findMetric(rm, "gremlin.client.request.duration"))
+}
+
+func TestObserverPropagation(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.Write([]byte(`{"requestId":"41d2e28a-20a4-4ab0-b379-d810dede3786","status":{"code":204,"attributes":{}},"result":{"data":null,"meta":{}}}`))
+	}))
+	defer server.Close()
+
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	obs, err := NewObserver(WithTracerProvider(tp))
+	assert.Empty(t, err)
+	client, err := gremlin.NewClientWithOptions(server.URL, gremlin.OptClientObserver(obs))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer client.Close()
+
+	_, err = client.ExecQuery("g.V().drop()")
+	assert.Empty(t, err)
+	// the server sees the request span as its parent
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		sc := spans[0].SpanContext()
+		assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", (<-headers).Get("traceparent"))
+	}
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer client.Close()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	reg, err := RegisterMetrics(client, WithMeterProvider(mp))
+	assert.Empty(t, err)
+	defer reg.Unregister()
+
+	var rm metricdata.ResourceMetrics
+	assert.Empty(t, reader.Collect(context.Background(), &rm))
+	pool := findMetric(rm, "gremlin.client.pool.idle")
+	if assert.NotNil(t, pool) {
+		assert.Equal(t, int64(1), pool.Data.(metricdata.Gauge[int64]).DataPoints[0].Value)
+	}
+	onIce := findMetric(rm, "gremlin.endpoint.on_ice")
+	if assert.NotNil(t, onIce) {
+		dps := onIce.Data.(metricdata.Gauge[int64]).DataPoints
+		assert.Len(t, dps, 1)
+		assert.Equal(t, int64(0), dps[0].Value)
+	}
+	assert.NotNil(t, findMetric(rm, "gremlin.client.requests.in_flight"))
+	assert.NotNil(t, findMetric(rm, "gremlin.endpoint.error_score"))
+}
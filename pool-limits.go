@@ -0,0 +1,125 @@
+package gremlin
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultMaxIdle is the number of idle connections kept by default
+const DefaultMaxIdle = 30
+
+var (
+	PoolExhaustedError = errors.New("all connections are in use")
+	PoolTimeoutError   = errors.New("timed out waiting for a connection")
+)
+
+// PoolOptions controls how many connections a client opens and what happens when all of them are in use
+type PoolOptions struct {
+	// MaxOpen limits the number of connections in use at the same time, 0 means no limit
+	MaxOpen int
+	// MaxIdle is the number of idle connections kept for reuse, defaults to DefaultMaxIdle
+	MaxIdle int
+	// WaitTimeout limits how long a request waits for a connection when MaxOpen are in use.
+	// 0 waits until the request's context is done.
+	WaitTimeout time.Duration
+	// FailFast returns PoolExhaustedError straight away instead of waiting for a connection
+	FailFast bool
+}
+
+// Sets the limits of the client's connection pool. Requests waiting for a connection are served in the order they arrived.
+func OptClientPool(opts PoolOptions) OptClient {
+	return func(c *Client) error {
+		c.poolOpts = opts
+		c.limiter.max = opts.MaxOpen
+		return nil
+	}
+}
+
+func (o PoolOptions) maxIdle() int {
+	if o.MaxIdle > 0 {
+		return o.MaxIdle
+	}
+	return DefaultMaxIdle
+}
+
+// limiter hands out permits to use a connection, at most max at a time, queueing the requests that have to wait
+type limiter struct {
+	mu      sync.Mutex
+	max     int
+	open    int
+	waiters list.List // of chan struct{}, closed when a permit is handed over
+}
+
+// acquire takes a permit, waiting for one to be released if needed. Waiting stops with ErrClientClosed
+// when shutdown is closed. It reports whether it had to wait.
+func (l *limiter) acquire(ctx context.Context, opts PoolOptions, shutdown <-chan struct{}) (bool, error) {
+	l.mu.Lock()
+	if l.max <= 0 || (l.open < l.max && l.waiters.Len() == 0) {
+		l.open++
+		l.mu.Unlock()
+		return false, nil
+	}
+	if opts.FailFast {
+		l.mu.Unlock()
+		return false, PoolExhaustedError
+	}
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if opts.WaitTimeout > 0 {
+		timer := time.NewTimer(opts.WaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-ready:
+		return true, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = PoolTimeoutError
+	case <-shutdown:
+		err = ErrClientClosed
+	}
+
+	l.mu.Lock()
+	select {
+	case <-ready:
+		// the permit was handed over while giving up, pass it on
+		l.mu.Unlock()
+		l.release()
+	default:
+		l.waiters.Remove(elem)
+		l.mu.Unlock()
+	}
+	return true, err
+}
+
+// release returns a permit, handing it to the longest waiting request if there is one
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max <= 0 {
+		l.open--
+		return
+	}
+	if front := l.waiters.Front(); front != nil {
+		l.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	l.open--
+}
+
+// waiting returns the number of requests waiting for a permit
+func (l *limiter) waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters.Len()
+}
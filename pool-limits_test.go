@@ -0,0 +1,100 @@
+package gremlin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterFIFO(t *testing.T) {
+	l := &limiter{max: 1}
+	_, err := l.acquire(context.Background(), PoolOptions{}, nil)
+	assert.Empty(t, err)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			waited, err := l.acquire(context.Background(), PoolOptions{}, nil)
+			assert.True(t, waited)
+			assert.Empty(t, err)
+			mu.Lock()
+			order = append(order, n)
+			mu.Unlock()
+			l.release()
+		}(i)
+		// make sure the waiters queue up in order
+		for l.waiting() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	l.release()
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	assert.Equal(t, 0, l.open)
+}
+
+func TestLimiterTimeouts(t *testing.T) {
+	l := &limiter{max: 1}
+	_, err := l.acquire(context.Background(), PoolOptions{}, nil)
+	assert.Empty(t, err)
+
+	_, err = l.acquire(context.Background(), PoolOptions{FailFast: true}, nil)
+	assert.Equal(t, PoolExhaustedError, err)
+
+	waited, err := l.acquire(context.Background(), PoolOptions{WaitTimeout: 10 * time.Millisecond}, nil)
+	assert.True(t, waited)
+	assert.Equal(t, PoolTimeoutError, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx, PoolOptions{}, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, l.waiting())
+
+	// shutting the client down stops the wait
+	shutdown := make(chan struct{})
+	close(shutdown)
+	_, err = l.acquire(context.Background(), PoolOptions{}, shutdown)
+	assert.Equal(t, ErrClientClosed, err)
+	assert.Equal(t, 0, l.waiting())
+
+	// the permit can still be taken once released
+	l.release()
+	waited, err = l.acquire(context.Background(), PoolOptions{FailFast: true}, nil)
+	assert.False(t, waited)
+	assert.Empty(t, err)
+}
+
+func TestClientPoolLimits(t *testing.T) {
+	server := newTestServer(slowResponse(50 * time.Millisecond))
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientPool(PoolOptions{MaxOpen: 1, WaitTimeout: time.Second}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cl.ExecQuery("1")
+			assert.Empty(t, err)
+		}()
+	}
+	wg.Wait()
+
+	st := cl.Stats()
+	assert.Equal(t, uint64(2), st.PoolWaits)
+	assert.True(t, st.PoolWaitTime >= 50*time.Millisecond)
+	assert.Equal(t, uint64(0), st.PoolTimeouts)
+	// only a single connection was ever needed
+	assert.Equal(t, uint64(0), st.Reconnects)
+}
@@ -0,0 +1,152 @@
+package gremlin
+
+// Predicate is a Gremlin predicate such as P.gt(30) or TextP.containing("jo"). Predicates are sent
+// as GraphSON or GraphBinary, so they can be used as bindings, for example g.V().has('age', age) with age bound to P.Gt(30).
+type Predicate struct {
+	typ      string
+	operator string
+	value    interface{}
+}
+
+// And returns a predicate that holds when both predicates hold
+func (p Predicate) And(other Predicate) Predicate {
+	return Predicate{"g:P", "and", []interface{}{p, other}}
+}
+
+// Or returns a predicate that holds when either predicate holds
+func (p Predicate) Or(other Predicate) Predicate {
+	return Predicate{"g:P", "or", []interface{}{p, other}}
+}
+
+// Operator returns the name of the predicate, such as gt or textContains
+func (p Predicate) Operator() string {
+	return p.operator
+}
+
+func (p Predicate) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	value, err := toGraphSON(p.value, version)
+	if err != nil {
+		return nil, err
+	}
+	return TypedValue{p.typ, map[string]interface{}{"predicate": p.operator, "value": value}}, nil
+}
+
+type predicates struct{}
+
+// P holds the TinkerPop comparison predicates, used like P.Gt(30)
+var P predicates
+
+func (predicates) Eq(v interface{}) Predicate  { return Predicate{"g:P", "eq", v} }
+func (predicates) Neq(v interface{}) Predicate { return Predicate{"g:P", "neq", v} }
+func (predicates) Lt(v interface{}) Predicate  { return Predicate{"g:P", "lt", v} }
+func (predicates) Lte(v interface{}) Predicate { return Predicate{"g:P", "lte", v} }
+func (predicates) Gt(v interface{}) Predicate  { return Predicate{"g:P", "gt", v} }
+func (predicates) Gte(v interface{}) Predicate { return Predicate{"g:P", "gte", v} }
+
+// Between holds for values from low up to, but not including, high
+func (predicates) Between(low, high interface{}) Predicate {
+	return Predicate{"g:P", "between", []interface{}{low, high}}
+}
+
+// Inside holds for values strictly between low and high
+func (predicates) Inside(low, high interface{}) Predicate {
+	return Predicate{"g:P", "inside", []interface{}{low, high}}
+}
+
+// Outside holds for values strictly below low or above high
+func (predicates) Outside(low, high interface{}) Predicate {
+	return Predicate{"g:P", "outside", []interface{}{low, high}}
+}
+
+func (predicates) Within(values ...interface{}) Predicate {
+	return Predicate{"g:P", "within", values}
+}
+
+func (predicates) Without(values ...interface{}) Predicate {
+	return Predicate{"g:P", "without", values}
+}
+
+type textPredicates struct{}
+
+// TextP holds the TinkerPop string predicates, used like TextP.Containing("jo")
+var TextP textPredicates
+
+func (textPredicates) Containing(s string) Predicate      { return Predicate{"g:TextP", "containing", s} }
+func (textPredicates) NotContaining(s string) Predicate   { return Predicate{"g:TextP", "notContaining", s} }
+func (textPredicates) StartingWith(s string) Predicate    { return Predicate{"g:TextP", "startingWith", s} }
+func (textPredicates) NotStartingWith(s string) Predicate { return Predicate{"g:TextP", "notStartingWith", s} }
+func (textPredicates) EndingWith(s string) Predicate      { return Predicate{"g:TextP", "endingWith", s} }
+func (textPredicates) NotEndingWith(s string) Predicate   { return Predicate{"g:TextP", "notEndingWith", s} }
+
+// Regex needs TinkerPop 3.6 or later
+func (textPredicates) Regex(s string) Predicate    { return Predicate{"g:TextP", "regex", s} }
+func (textPredicates) NotRegex(s string) Predicate { return Predicate{"g:TextP", "notRegex", s} }
+
+type janusGraphText struct{}
+
+// Text holds the JanusGraph full text predicates, which need a mixed index on the property
+var Text janusGraphText
+
+func (janusGraphText) TextContains(s string) Predicate       { return janusGraphP("textContains", s) }
+func (janusGraphText) TextContainsPrefix(s string) Predicate { return janusGraphP("textContainsPrefix", s) }
+func (janusGraphText) TextContainsRegex(s string) Predicate  { return janusGraphP("textContainsRegex", s) }
+func (janusGraphText) TextContainsFuzzy(s string) Predicate  { return janusGraphP("textContainsFuzzy", s) }
+func (janusGraphText) TextContainsPhrase(s string) Predicate { return janusGraphP("textContainsPhrase", s) }
+func (janusGraphText) TextPrefix(s string) Predicate         { return janusGraphP("textPrefix", s) }
+func (janusGraphText) TextRegex(s string) Predicate          { return janusGraphP("textRegex", s) }
+func (janusGraphText) TextFuzzy(s string) Predicate          { return janusGraphP("textFuzzy", s) }
+
+type janusGraphGeo struct{}
+
+// Geo holds the JanusGraph geo predicates, which compare a geoshape property with a Shape
+var Geo janusGraphGeo
+
+func (janusGraphGeo) GeoIntersect(s Shape) Predicate { return janusGraphP("geoIntersect", s) }
+func (janusGraphGeo) GeoWithin(s Shape) Predicate    { return janusGraphP("geoWithin", s) }
+func (janusGraphGeo) GeoDisjoint(s Shape) Predicate  { return janusGraphP("geoDisjoint", s) }
+func (janusGraphGeo) GeoContains(s Shape) Predicate  { return janusGraphP("geoContains", s) }
+
+func janusGraphP(operator string, value interface{}) Predicate {
+	return Predicate{"janusgraph:JanusGraphP", operator, value}
+}
+
+// Shape is a JanusGraph geoshape, sent as GeoJSON in GraphSON and as JanusGraph's geoshape type in
+// GraphBinary, which holds points, circles and boxes only. Coordinates are given latitude first like
+// JanusGraph's Geoshape, GeoJSON itself puts the longitude first.
+type Shape struct {
+	geometry map[string]interface{}
+}
+
+func (s Shape) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return TypedValue{"janusgraph:Geoshape", s.geometry}, nil
+}
+
+type geoshapes struct{}
+
+// Geoshape builds the shapes used as values of geoshape properties and by the Geo predicates
+var Geoshape geoshapes
+
+func (geoshapes) Point(lat, lon float64) Shape {
+	return Shape{map[string]interface{}{"type": "Point", "coordinates": []float64{lon, lat}}}
+}
+
+// Circle is a circle around a point with a radius in kilometers
+func (geoshapes) Circle(lat, lon, radiusKm float64) Shape {
+	return Shape{map[string]interface{}{
+		"type":        "Circle",
+		"coordinates": []float64{lon, lat},
+		"radius":      radiusKm,
+		"properties":  map[string]interface{}{"radius_units": "km"},
+	}}
+}
+
+// Box is sent as a GeoJSON polygon with the given south west and north east corners
+func (geoshapes) Box(southWestLat, southWestLon, northEastLat, northEastLon float64) Shape {
+	return Shape{map[string]interface{}{"type": "Polygon", "coordinates": [][][]float64{{
+		{southWestLon, southWestLat},
+		{northEastLon, southWestLat},
+		{northEastLon, northEastLat},
+		{southWestLon, northEastLat},
+		{southWestLon, southWestLat},
+	}}}}
+}
@@ -0,0 +1,125 @@
+package gremlin
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredicateGraphSON(t *testing.T) {
+	cases := []struct {
+		predicate Predicate
+		v2, v3    string
+	}{
+		{P.Gt(30),
+			`{"@type":"g:P","@value":{"predicate":"gt","value":{"@type":"g:Int32","@value":30}}}`,
+			`{"@type":"g:P","@value":{"predicate":"gt","value":{"@type":"g:Int32","@value":30}}}`},
+		{P.Between(int64(1), int64(5)),
+			`{"@type":"g:P","@value":{"predicate":"between","value":[{"@type":"g:Int64","@value":1},{"@type":"g:Int64","@value":5}]}}`,
+			`{"@type":"g:P","@value":{"predicate":"between","value":{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1},{"@type":"g:Int64","@value":5}]}}}`},
+		{P.Within("a", "b"),
+			`{"@type":"g:P","@value":{"predicate":"within","value":["a","b"]}}`,
+			`{"@type":"g:P","@value":{"predicate":"within","value":{"@type":"g:List","@value":["a","b"]}}}`},
+		{TextP.Containing("jo").Or(TextP.EndingWith("n")),
+			`{"@type":"g:P","@value":{"predicate":"or","value":[{"@type":"g:TextP","@value":{"predicate":"containing","value":"jo"}},{"@type":"g:TextP","@value":{"predicate":"endingWith","value":"n"}}]}}`,
+			`{"@type":"g:P","@value":{"predicate":"or","value":{"@type":"g:List","@value":[{"@type":"g:TextP","@value":{"predicate":"containing","value":"jo"}},{"@type":"g:TextP","@value":{"predicate":"endingWith","value":"n"}}]}}}`},
+		{Text.TextContains("graph"),
+			`{"@type":"janusgraph:JanusGraphP","@value":{"predicate":"textContains","value":"graph"}}`,
+			`{"@type":"janusgraph:JanusGraphP","@value":{"predicate":"textContains","value":"graph"}}`},
+		{Geo.GeoWithin(Geoshape.Circle(37.97, 23.72, 50)),
+			`{"@type":"janusgraph:JanusGraphP","@value":{"predicate":"geoWithin","value":{"@type":"janusgraph:Geoshape","@value":{"coordinates":[23.72,37.97],"properties":{"radius_units":"km"},"radius":50,"type":"Circle"}}}}`,
+			`{"@type":"janusgraph:JanusGraphP","@value":{"predicate":"geoWithin","value":{"@type":"janusgraph:Geoshape","@value":{"coordinates":[23.72,37.97],"properties":{"radius_units":"km"},"radius":50,"type":"Circle"}}}}`},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.v2, graphSONString(t, c.predicate, GraphSONv2), c.predicate.Operator())
+		assert.Equal(t, c.v3, graphSONString(t, c.predicate, GraphSONv3), c.predicate.Operator())
+	}
+}
+
+func TestGeoshapeGraphSON(t *testing.T) {
+	assert.Equal(t, `{"@type":"janusgraph:Geoshape","@value":{"coordinates":[23.72,37.97],"type":"Point"}}`,
+		graphSONString(t, Geoshape.Point(37.97, 23.72), GraphSONv3))
+	assert.Equal(t, `{"@type":"janusgraph:Geoshape","@value":{"coordinates":[[[1,0],[3,0],[3,2],[1,2],[1,0]]],"type":"Polygon"}}`,
+		graphSONString(t, Geoshape.Box(0, 1, 2, 3), GraphSONv2))
+}
+
+func TestPredicateBinding(t *testing.T) {
+	req, err := Queryf("g.V().has('age', %v)", P.Gt(30).And(P.Lt(40)))
+	assert.Empty(t, err)
+	typed, err := graphSONBindings(req.Args.Bindings, GraphSONv2)
+	assert.Empty(t, err)
+	assert.Equal(t, "and", typed["_p0"].(TypedValue).Value.(map[string]interface{})["predicate"])
+
+	_, err = Queryf("g.V().has('age', %v)", P.Eq(make(chan int)))
+	assert.NotNil(t, err)
+}
+
+func TestPredicateGraphBinary(t *testing.T) {
+	janusGraphP := func(name string) []byte {
+		return gb(gbCustom, "janusgraph.P", int32(0x1002), gbValue, name)
+	}
+	geoshape := func(shape byte) []byte {
+		return gb(gbCustom, "janusgraph.Geoshape", int32(0x1000), gbValue, geoshapeFormat, shape)
+	}
+	cases := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{P.Gt(30), gb(gbP, gbValue, "gt", int32(1), gbInt, gbValue, int32(30))},
+		{P.Between(int64(1), int64(5)), gb(gbP, gbValue, "between", int32(2), gbLong, gbValue, int64(1), gbLong, gbValue, int64(5))},
+		{P.Within("a", "b"), gb(gbP, gbValue, "within", int32(2), gbString, gbValue, "a", gbString, gbValue, "b")},
+		{P.Eq([]string{"a"}), gb(gbP, gbValue, "eq", int32(1), gbList, gbValue, int32(1), gbString, gbValue, "a")},
+		{TextP.Containing("jo").Or(TextP.EndingWith("n")), gb(gbP, gbValue, "or", int32(2),
+			gbTextP, gbValue, "containing", int32(1), gbString, gbValue, "jo",
+			gbTextP, gbValue, "endingWith", int32(1), gbString, gbValue, "n")},
+		{Order.Desc, gb(gbOrder, gbValue, gbString, gbValue, "desc")},
+		{Text.TextContains("graph"), gb(janusGraphP("textContains"), gbString, gbValue, "graph")},
+		{Geo.GeoWithin(Geoshape.Circle(37.97, 23.72, 50)), gb(janusGraphP("geoWithin"), geoshape(geoshapeCircle), 37.97, 23.72, 50.0)},
+		{Geoshape.Point(37.97, 23.72), gb(geoshape(geoshapePoint), 37.97, 23.72)},
+		{Geoshape.Box(0, 1, 2, 3), gb(geoshape(geoshapeBox), 0.0, 1.0, 2.0, 3.0)},
+	}
+	for _, c := range cases {
+		w := &graphBinaryWriter{}
+		assert.Empty(t, w.write(c.value), c.value)
+		assert.Equal(t, c.want, w.Bytes(), c.value)
+	}
+
+	// bytecode carries its predicates and tokens
+	w := &graphBinaryWriter{}
+	assert.Empty(t, w.write(NewGraphTraversalSource(nil, "").V().Has("age", P.Gt(30)).Order().By("name", Order.Desc)))
+	assert.Equal(t, gb(gbBytecode, gbValue, int32(4),
+		"V", int32(0),
+		"has", int32(2), gbString, gbValue, "age", gbP, gbValue, "gt", int32(1), gbInt, gbValue, int32(30),
+		"order", int32(0),
+		"by", int32(2), gbString, gbValue, "name", gbOrder, gbValue, gbString, gbValue, "desc",
+		int32(0),
+	), w.Bytes())
+
+	w.Reset()
+	assert.Error(t, w.write(Shape{map[string]interface{}{"type": "LineString", "coordinates": [][]float64{{0, 1}, {2, 3}}}}))
+}
+
+func TestPredicateGraphBinaryResponse(t *testing.T) {
+	message := gb(graphBinaryVersion, gbNull, int32(200), gbNull, int32(0), int32(0),
+		gbList, gbValue, int32(4),
+		gbCustom, "janusgraph.Geoshape", int32(0x1000), gbValue, geoshapeFormat, geoshapePoint, 37.97, 23.72,
+		gbCustom, "janusgraph.RelationIdentifier", int32(0x1001), gbValue, int64(4112), int64(1), int64(1), int64(8200),
+		gbP, gbValue, "within", int32(1), gbInt, gbValue, int32(1),
+		gbCustom, "janusgraph.P", int32(0x1002), gbValue, "textContains", gbString, gbValue, "graph",
+	)
+	b, err := graphBinaryResponse(message)
+	if !assert.Empty(t, err) {
+		return
+	}
+	var res Response
+	assert.Empty(t, json.Unmarshal(b, &res))
+	assert.Equal(t, `{"@type":"g:List","@value":[`+
+		`{"@type":"janusgraph:Geoshape","@value":{"coordinates":[23.72,37.97],"type":"Point"}},`+
+		`{"@type":"janusgraph:RelationIdentifier","@value":{"relationId":"1-368-1-6bs"}},`+
+		`{"@type":"g:P","@value":{"predicate":"within","value":{"@type":"g:List","@value":[{"@type":"g:Int32","@value":1}]}}},`+
+		`{"@type":"janusgraph:JanusGraphP","@value":{"predicate":"textContains","value":"graph"}}]}`, string(res.Result.Data))
+
+	_, err = graphBinaryResponse(gb(graphBinaryVersion, gbNull, int32(200), gbNull, int32(0), int32(0),
+		gbCustom, "example.Point", int32(1), gbValue, 1.0, 2.0))
+	assert.Error(t, err)
+}
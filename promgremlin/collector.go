@@ -0,0 +1,105 @@
+// Package promgremlin exposes the counters of a gremlin client as Prometheus metrics.
+//
+//	prometheus.MustRegister(promgremlin.NewCollector(client, "myservice", prometheus.Labels{"graph": "users"}))
+package promgremlin
+
+import (
+	"strconv"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector reads a client's Stats on every scrape
+type Collector struct {
+	client *gremlin.Client
+
+	requests       *prometheus.Desc
+	latency        *prometheus.Desc
+	bytesOut       *prometheus.Desc
+	bytesIn        *prometheus.Desc
+	wireBytesOut   *prometheus.Desc
+	wireBytesIn    *prometheus.Desc
+	poolGets       *prometheus.Desc
+	poolWaits      *prometheus.Desc
+	poolTimeouts   *prometheus.Desc
+	poolWaitTime   *prometheus.Desc
+	poolWaiting    *prometheus.Desc
+	reconnects     *prometheus.Desc
+	authChallenges *prometheus.Desc
+	endpointsOnIce *prometheus.Desc
+}
+
+// NewCollector creates a collector for the client. The namespace is prepended to every metric name
+// and constLabels are added to every metric, which allows registering collectors for several clients.
+func NewCollector(client *gremlin.Client, namespace string, constLabels prometheus.Labels) *Collector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "gremlin", name), help, labels, constLabels)
+	}
+	return &Collector{
+		client:         client,
+		requests:       desc("requests_total", "Number of requests by response status code.", "code"),
+		latency:        desc("request_duration_seconds", "Latency of requests."),
+		bytesOut:       desc("sent_bytes_total", "Bytes of request messages written."),
+		bytesIn:        desc("received_bytes_total", "Bytes of response messages read."),
+		wireBytesOut:   desc("wire_sent_bytes_total", "Bytes written to the sockets, after compression."),
+		wireBytesIn:    desc("wire_received_bytes_total", "Bytes read from the sockets, before decompression."),
+		poolGets:       desc("pool_gets_total", "Connections taken from the pool."),
+		poolWaits:      desc("pool_waits_total", "Connections that had to wait to be taken from the pool."),
+		poolTimeouts:   desc("pool_timeouts_total", "Connections that could not be taken from the pool in time."),
+		poolWaitTime:   desc("pool_wait_seconds_total", "Time spent waiting for a connection from the pool."),
+		poolWaiting:    desc("pool_waiting", "Requests currently waiting for a connection from the pool."),
+		reconnects:     desc("reconnects_total", "Sockets dialed after the client was created."),
+		authChallenges: desc("auth_challenges_total", "Authentication challenges answered."),
+		endpointsOnIce: desc("endpoints_on_ice", "Endpoints currently on ice."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.latency
+	ch <- c.bytesOut
+	ch <- c.bytesIn
+	ch <- c.wireBytesOut
+	ch <- c.wireBytesIn
+	ch <- c.poolGets
+	ch <- c.poolWaits
+	ch <- c.poolTimeouts
+	ch <- c.poolWaitTime
+	ch <- c.poolWaiting
+	ch <- c.reconnects
+	ch <- c.authChallenges
+	ch <- c.endpointsOnIce
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.client.Stats()
+	for code, n := range st.Requests {
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(n), strconv.Itoa(code))
+	}
+
+	// prometheus buckets are cumulative
+	buckets := make(map[float64]uint64, len(st.Latency.Bounds))
+	var cumulative uint64
+	for i, bound := range st.Latency.Bounds {
+		cumulative += st.Latency.Counts[i]
+		buckets[bound.Seconds()] = cumulative
+	}
+	ch <- prometheus.MustNewConstHistogram(c.latency, st.Latency.Count, st.Latency.Sum.Seconds(), buckets)
+
+	counter := func(desc *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v))
+	}
+	counter(c.bytesOut, st.BytesOut)
+	counter(c.bytesIn, st.BytesIn)
+	counter(c.wireBytesOut, st.WireBytesOut)
+	counter(c.wireBytesIn, st.WireBytesIn)
+	counter(c.poolGets, st.PoolGets)
+	counter(c.poolWaits, st.PoolWaits)
+	counter(c.poolTimeouts, st.PoolTimeouts)
+	ch <- prometheus.MustNewConstMetric(c.poolWaitTime, prometheus.CounterValue, st.PoolWaitTime.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.poolWaiting, prometheus.GaugeValue, float64(st.PoolWaiting))
+	counter(c.reconnects, st.Reconnects)
+	counter(c.authChallenges, st.AuthChallenges)
+	ch <- prometheus.MustNewConstMetric(c.endpointsOnIce, prometheus.GaugeValue, float64(st.EndpointsOnIce))
+}
@@ -0,0 +1,76 @@
+package promgremlin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer answers every request with a single successful result
+func newServer() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// skip the mime type header
+			var req struct {
+				RequestId struct {
+					Value string `json:"@value"`
+				} `json:"requestId"`
+			}
+			json.Unmarshal(msg[int(msg[0])+1:], &req)
+			res := `{"requestId":"` + req.RequestId.Value + `","status":{"code":200},"result":{"data":[2],"meta":{}}}`
+			ws.WriteMessage(websocket.TextMessage, []byte(res))
+		}
+	}))
+}
+
+func TestCollector(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer client.Close()
+
+	_, err = client.ExecQuery("1 + 1")
+	assert.Empty(t, err)
+
+	collector := NewCollector(client, "test", nil)
+	reg := prometheus.NewPedanticRegistry()
+	assert.Empty(t, reg.Register(collector))
+
+	expected := `
+# HELP test_gremlin_requests_total Number of requests by response status code.
+# TYPE test_gremlin_requests_total counter
+test_gremlin_requests_total{code="200"} 1
+# HELP test_gremlin_pool_gets_total Connections taken from the pool.
+# TYPE test_gremlin_pool_gets_total counter
+test_gremlin_pool_gets_total 1
+# HELP test_gremlin_endpoints_on_ice Endpoints currently on ice.
+# TYPE test_gremlin_endpoints_on_ice gauge
+test_gremlin_endpoints_on_ice 0
+`
+	assert.Empty(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_gremlin_requests_total", "test_gremlin_pool_gets_total", "test_gremlin_endpoints_on_ice"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "test_gremlin_request_duration_seconds"))
+	assert.True(t, client.Stats().BytesIn > 0)
+	assert.True(t, client.Stats().BytesOut > 0)
+}
@@ -0,0 +1,200 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// newConnectProxy is a stand-in for an http proxy requiring basic authentication
+func newConnectProxy(t *testing.T, user, pass string, tunnels *int32) *httptest.Server {
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != expected {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		pipe(conn, target)
+	}))
+}
+
+// newSocks5Proxy is a stand-in for a socks5 proxy requiring username and password authentication
+func newSocks5Proxy(t *testing.T, user, pass string, tunnels *int32) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				buf := make([]byte, 256)
+				// greeting: version, methods
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					conn.Close()
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 2})
+				// username and password sub negotiation
+				io.ReadFull(conn, buf[:2])
+				u := make([]byte, buf[1])
+				io.ReadFull(conn, u)
+				io.ReadFull(conn, buf[:1])
+				p := make([]byte, buf[0])
+				io.ReadFull(conn, p)
+				if string(u) != user || string(p) != pass {
+					conn.Write([]byte{1, 1})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{1, 0})
+				// connect request
+				io.ReadFull(conn, buf[:4])
+				var host string
+				switch buf[3] {
+				case 1:
+					io.ReadFull(conn, buf[:4])
+					host = net.IP(buf[:4]).String()
+				case 3:
+					io.ReadFull(conn, buf[:1])
+					name := make([]byte, buf[0])
+					io.ReadFull(conn, name)
+					host = string(name)
+				}
+				io.ReadFull(conn, buf[:2])
+				port := binary.BigEndian.Uint16(buf[:2])
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				atomic.AddInt32(tunnels, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				pipe(conn, target)
+			}(conn)
+		}
+	}()
+	return l
+}
+
+func TestHTTPConnectProxy(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	var tunnels int32
+	proxy := newConnectProxy(t, "user", "secret", &tunnels)
+	defer proxy.Close()
+
+	proxyUrl := "http://user:secret@" + proxy.Listener.Addr().String()
+	cl, err := NewClientWithOptions(server.Url, OptClientProxy(proxyUrl))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+
+	// wrong credentials are rejected by the proxy
+	_, err = NewClientWithOptions(server.Url, OptClientKeepAlive(0),
+		OptClientProxy("http://user:wrong@"+proxy.Listener.Addr().String()))
+	assert.NotEmpty(t, err)
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	var tunnels int32
+	proxy := newSocks5Proxy(t, "user", "secret", &tunnels)
+	defer proxy.Close()
+
+	cl, err := NewClientWithOptions(server.Url, OptClientProxy("socks5://user:secret@"+proxy.Addr().String()))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+}
+
+func TestDialContext(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	var dials int32
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	cl, err := NewClientWithOptions(server.Url, OptClientDialContext(dial))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dials))
+}
+
+func TestUnsupportedProxy(t *testing.T) {
+	_, err := NewClientWithOptions("ws://localhost:8182/gremlin", OptClientProxy("ftp://proxy:21"))
+	assert.Equal(t, UnsupportedProxySchemeError, err)
+
+	// gorilla/websocket has no dialer for https proxies, http clients do
+	_, err = NewClientWithOptions("wss://localhost:8182/gremlin", OptClientProxy("https://proxy:3129"))
+	assert.Equal(t, HTTPSProxyError, err)
+	cl, err := NewClientWithOptions("https://localhost:8182/gremlin", OptClientProxy("https://proxy:3129"))
+	if assert.Empty(t, err) {
+		cl.Close()
+	}
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://gremlin.example.com/gremlin", nil)
+	for proxyUrl, want := range map[string]error{
+		"https://proxy.example.com:3129":  HTTPSProxyError,
+		"http://proxy.example.com:3128":   nil,
+		"socks5://proxy.example.com:1080": nil,
+	} {
+		u, _ := url.Parse(proxyUrl)
+		_, err := websocketProxy(http.ProxyURL(u))(req)
+		assert.Equal(t, want, err, proxyUrl)
+	}
+}
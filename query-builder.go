@@ -0,0 +1,256 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	UnsupportedVerbError = errors.New("only %v placeholders are supported, use %% for a literal percent sign")
+	MissingArgumentError = errors.New("the script has more placeholders than arguments")
+	ExtraArgumentError   = errors.New("the script has fewer placeholders than arguments")
+)
+
+// InvalidBindingError is returned for a binding the server would reject. It matches ErrQuery.
+type InvalidBindingError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidBindingError) Error() string {
+	return fmt.Sprintf("invalid binding %q: %s", e.Name, e.Reason)
+}
+
+func (e *InvalidBindingError) Is(target error) bool {
+	return target == ErrQuery
+}
+
+// words that can't be used as binding names in a gremlin-groovy script
+var groovyReservedWords = map[string]bool{
+	"abstract": true, "as": true, "assert": true, "boolean": true, "break": true, "byte": true,
+	"case": true, "catch": true, "char": true, "class": true, "const": true, "continue": true,
+	"def": true, "default": true, "do": true, "double": true, "else": true, "enum": true,
+	"extends": true, "false": true, "final": true, "finally": true, "float": true, "for": true,
+	"goto": true, "if": true, "implements": true, "import": true, "in": true, "instanceof": true,
+	"int": true, "interface": true, "long": true, "native": true, "new": true, "null": true,
+	"package": true, "private": true, "protected": true, "public": true, "return": true,
+	"short": true, "static": true, "strictfp": true, "super": true, "switch": true,
+	"synchronized": true, "this": true, "threadsafe": true, "throw": true, "throws": true,
+	"trait": true, "transient": true, "true": true, "try": true, "var": true, "void": true,
+	"volatile": true, "while": true,
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// ValidateBindingName checks that name can be used as a variable in a gremlin-groovy script
+func ValidateBindingName(name string) error {
+	if !identifierRegexp.MatchString(name) {
+		return &InvalidBindingError{Name: name, Reason: "not a valid identifier"}
+	}
+	if groovyReservedWords[name] {
+		return &InvalidBindingError{Name: name, Reason: "reserved word"}
+	}
+	return nil
+}
+
+// ValidateBindings checks every binding name, and that every value can be serialized with the given GraphSON version
+func ValidateBindings(bindings Bind, version GraphSONVersion) error {
+	for name, value := range bindings {
+		if err := ValidateBindingName(name); err != nil {
+			return err
+		}
+		if err := serializableBinding(value, version); err != nil {
+			return &InvalidBindingError{Name: name, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// serializableBinding checks that the value can be serialized as GraphSON
+func serializableBinding(value interface{}, version GraphSONVersion) error {
+	g, err := toGraphSON(value, version)
+	if err != nil {
+		return err
+	}
+	_, err = json.Marshal(g)
+	return err
+}
+
+// QueryBuilder builds a script from fragments whose values are sent as bindings rather than
+// being pasted into the script, so they can't change the meaning of the script.
+type QueryBuilder struct {
+	script     strings.Builder
+	bindings   Bind
+	next       int
+	err        error
+	serializer GraphSONVersion
+}
+
+// NewQueryBuilder returns a builder checking binding values against GraphSON 2, the default serializer of clients
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{bindings: Bind{}, serializer: GraphSONv2}
+}
+
+// Serializer sets the GraphSON version binding values are checked against, that of the client the request is for
+func (b *QueryBuilder) Serializer(version GraphSONVersion) *QueryBuilder {
+	b.serializer = version
+	return b
+}
+
+// Add appends a script fragment. Each %v placeholder is replaced with a generated binding holding the matching argument.
+func (b *QueryBuilder) Add(format string, args ...interface{}) *QueryBuilder {
+	if b.err != nil {
+		return b
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.script.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch {
+		case i < len(format) && format[i] == '%':
+			b.script.WriteByte('%')
+		case i < len(format) && format[i] == 'v':
+			if len(args) == 0 {
+				b.err = MissingArgumentError
+				return b
+			}
+			b.script.WriteString(b.bind(args[0]))
+			args = args[1:]
+		default:
+			b.err = UnsupportedVerbError
+			return b
+		}
+	}
+	if len(args) > 0 {
+		b.err = ExtraArgumentError
+	}
+	return b
+}
+
+// Bind adds a binding with the given name, for use in fragments that refer to it by name
+func (b *QueryBuilder) Bind(name string, value interface{}) *QueryBuilder {
+	if b.err != nil {
+		return b
+	}
+	if _, taken := b.bindings[name]; taken {
+		b.err = &InvalidBindingError{Name: name, Reason: "bound twice"}
+		return b
+	}
+	b.err = ValidateBindingName(name)
+	b.bindings[name] = value
+	return b
+}
+
+// bind stores the value under the next free generated name
+func (b *QueryBuilder) bind(value interface{}) string {
+	for {
+		name := "_p" + strconv.Itoa(b.next)
+		b.next++
+		if _, taken := b.bindings[name]; !taken {
+			b.bindings[name] = value
+			return name
+		}
+	}
+}
+
+// Request returns the request for the script built so far, or the first error found while building it
+func (b *QueryBuilder) Request() (*Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := ValidateBindings(b.bindings, b.serializer); err != nil {
+		return nil, err
+	}
+	req := Query(b.script.String())
+	if len(b.bindings) > 0 {
+		req.Bindings(b.bindings)
+	}
+	return req, nil
+}
+
+// Queryf builds a request from a script whose %v placeholders are sent as bindings, for example
+// Queryf("g.V().has('name', %v).limit(%v)", name, 10) sends g.V().has('name', _p0).limit(_p1).
+// Values are checked against GraphSON 2, use a QueryBuilder to check them against another version.
+func Queryf(format string, args ...interface{}) (*Request, error) {
+	return NewQueryBuilder().Add(format, args...).Request()
+}
+
+// LintScript returns a warning for every string or number literal in the script. Values pasted into
+// scripts open the door to injection and stop the server from reusing the compiled script.
+func LintScript(script string) []string {
+	var warnings []string
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "//"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				return warnings
+			}
+			i += end + 3
+		case c == '\'' || c == '"':
+			end := stringLiteralEnd(script, i)
+			warnings = append(warnings, fmt.Sprintf("literal %s at offset %d, use a binding instead", script[i:end], i))
+			i = end - 1
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentifierByte(script[i-1])):
+			end := i
+			for end < len(script) && (isIdentifierByte(script[end]) ||
+				script[end] == '.' && end+1 < len(script) && script[end+1] >= '0' && script[end+1] <= '9') {
+				end++
+			}
+			warnings = append(warnings, fmt.Sprintf("literal %s at offset %d, use a binding instead", script[i:end], i))
+			i = end - 1
+		case isIdentifierByte(c):
+			for i+1 < len(script) && isIdentifierByte(script[i+1]) {
+				i++
+			}
+		}
+	}
+	return warnings
+}
+
+// stringLiteralEnd returns the offset just past the string literal starting at start,
+// which may be triple quoted and contain escaped quotes
+func stringLiteralEnd(script string, start int) int {
+	quote := script[start : start+1]
+	if strings.HasPrefix(script[start:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	for i := start + len(quote); i < len(script); i++ {
+		if script[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(script[i:], quote) {
+			return i + len(quote)
+		}
+	}
+	return len(script)
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// LintInterceptor logs the literals found in every script, see LintScript
+func LintInterceptor(logf func(format string, args ...interface{})) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Result, error) {
+		if req.Args != nil && req.Args.Gremlin != "" {
+			for _, warning := range LintScript(req.Args.Gremlin) {
+				logf("gremlin %s %s: %s", req.Op, req.RequestId, warning)
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,113 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryf(t *testing.T) {
+	req, err := Queryf("g.V().has('name', %v).limit(%v).math('_ %% 2')", "john'); g.V().drop(); ('", 10)
+	assert.Empty(t, err)
+	assert.Equal(t, "g.V().has('name', _p0).limit(_p1).math('_ % 2')", req.Args.Gremlin)
+	assert.Equal(t, Bind{"_p0": "john'); g.V().drop(); ('", "_p1": 10}, req.Args.Bindings)
+
+	req, err = Queryf("g.V().count()")
+	assert.Empty(t, err)
+	assert.Nil(t, req.Args.Bindings)
+
+	_, err = Queryf("g.V(%d)", 1)
+	assert.Equal(t, UnsupportedVerbError, err)
+	_, err = Queryf("g.V(%v, %v)", 1)
+	assert.Equal(t, MissingArgumentError, err)
+	_, err = Queryf("g.V(%v)", 1, 2)
+	assert.Equal(t, ExtraArgumentError, err)
+	_, err = Queryf("g.V(%v)", make(chan int))
+	assert.True(t, errors.Is(err, ErrQuery))
+}
+
+func TestQueryBuilder(t *testing.T) {
+	req, err := NewQueryBuilder().
+		Bind("_p0", "taken").
+		Add("g.V().has('name', %v)", "john").
+		Add(".has('age', gt(%v))", 30).
+		Add(".has('city', _p0)").
+		Request()
+	assert.Empty(t, err)
+	assert.Equal(t, "g.V().has('name', _p1).has('age', gt(_p2)).has('city', _p0)", req.Args.Gremlin)
+	assert.Equal(t, Bind{"_p0": "taken", "_p1": "john", "_p2": 30}, req.Args.Bindings)
+
+	_, err = NewQueryBuilder().Bind("x", 1).Bind("x", 2).Request()
+	assert.True(t, errors.Is(err, ErrQuery))
+
+	// values are checked against the serializer of the client
+	byId := map[int64]string{1: "marko"}
+	_, err = NewQueryBuilder().Add("names[%v]", byId).Request()
+	assert.True(t, errors.Is(err, ErrQuery))
+	_, err = NewQueryBuilder().Serializer(GraphSONv3).Add("names[%v]", byId).Request()
+	assert.Empty(t, err)
+}
+
+func TestValidateBindingName(t *testing.T) {
+	for _, name := range []string{"x", "userName", "_p0", "$x", "g1"} {
+		assert.Empty(t, ValidateBindingName(name), name)
+	}
+	for _, name := range []string{"in", "class", "def", "true", "1x", "user-name", "", "a b"} {
+		err := ValidateBindingName(name)
+		var invalid *InvalidBindingError
+		assert.True(t, errors.As(err, &invalid), name)
+		assert.True(t, errors.Is(err, ErrQuery), name)
+	}
+}
+
+func TestInvalidBindingsRejectedBeforeSending(t *testing.T) {
+	server := newTestServer(successResponse("[1]"))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	_, err = cl.Exec(Query("g.V(in)").Bindings(Bind{"in": 1}))
+	assert.True(t, errors.Is(err, ErrQuery))
+	// GraphSON 2 maps only have string keys
+	_, err = cl.Exec(Query("names[ids]").Bindings(Bind{"ids": map[int64]string{1: "marko"}}))
+	var invalid *InvalidBindingError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, "ids", invalid.Name)
+	}
+	assert.Empty(t, server.received())
+}
+
+func TestLintScript(t *testing.T) {
+	assert.Empty(t, LintScript("g.V().has(key, name).limit(n)"))
+
+	warnings := LintScript(`g.V(v1).has("name", 'jo\'hn').has('age', gt(30)).values(x2).math('_ * 1.5') // 'comment'`)
+	assert.Equal(t, []string{
+		`literal "name" at offset 12, use a binding instead`,
+		`literal 'jo\'hn' at offset 20, use a binding instead`,
+		`literal 'age' at offset 34, use a binding instead`,
+		`literal 30 at offset 44, use a binding instead`,
+		`literal '_ * 1.5' at offset 65, use a binding instead`,
+	}, warnings)
+
+	warnings = LintScript("g.V() /* 'ignored' 1 */.limit(2)")
+	assert.Equal(t, []string{"literal 2 at offset 30, use a binding instead"}, warnings)
+}
+
+func TestLintInterceptor(t *testing.T) {
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	req := Query("g.V().limit(10)")
+	next := func(ctx context.Context, req *Request) (*Result, error) {
+		return &Result{}, nil
+	}
+	_, err := LintInterceptor(logf)(context.Background(), req, next)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"gremlin eval " + req.RequestId + ": literal 10 at offset 12, use a binding instead"}, logged)
+}
@@ -5,6 +5,7 @@ import (
 	_ "fmt"
 	"github.com/satori/go.uuid"
 	"errors"
+	"time"
 )
 
 
@@ -20,15 +21,106 @@ type Request struct {
 }
 
 type RequestArgs struct {
-	Gremlin           string            `json:"gremlin,omitempty"`
-	Session           string            `json:"session,omitempty"`
-	Bindings          Bind              `json:"bindings,omitempty"`
-	Language          string            `json:"language,omitempty"`
-	Rebindings        Bind              `json:"rebindings,omitempty"`
-	Sasl              string            `json:"sasl,omitempty"`
-	BatchSize         int               `json:"batchSize,omitempty"`
-	ManageTransaction bool              `json:"manageTransaction,omitempty"`
-	Aliases           map[string]string `json:"aliases,omitempty"`
+	Gremlin               string            `json:"gremlin,omitempty"`
+	Session               string            `json:"session,omitempty"`
+	Bindings              Bind              `json:"bindings,omitempty"`
+	Language              string            `json:"language,omitempty"`
+	Rebindings            Bind              `json:"rebindings,omitempty"`
+	Sasl                  string            `json:"sasl,omitempty"`
+	BatchSize             int               `json:"batchSize,omitempty"`
+	ManageTransaction     *bool             `json:"manageTransaction,omitempty"`
+	Aliases               map[string]string `json:"aliases,omitempty"`
+	EvaluationTimeout     int64             `json:"evaluationTimeout,omitempty"`
+	MaterializeProperties string            `json:"materializeProperties,omitempty"`
+	UserAgent             string            `json:"userAgent,omitempty"`
+	SideEffect            interface{}       `json:"sideEffect,omitempty"`
+	SideEffectKey         string            `json:"sideEffectKey,omitempty"`
+	// TraceContext carries the trace of a websocket request to the server, as the fields written by the
+	// global propagator such as traceparent. Requests sent over http carry it in headers instead.
+	TraceContext map[string]string `json:"traceContext,omitempty"`
+	// Bytecode is sent as the gremlin argument of bytecode requests, in place of a script
+	Bytecode interface{} `json:"-"`
+}
+
+func (a RequestArgs) MarshalJSON() ([]byte, error) {
+	type args RequestArgs
+	if a.Bytecode == nil {
+		return json.Marshal(args(a))
+	}
+	return json.Marshal(struct {
+		args
+		Gremlin interface{} `json:"gremlin"`
+	}{args(a), a.Bytecode})
+}
+
+// Values accepted by the materializeProperties request argument
+const (
+	MaterializeAll    = "all"
+	MaterializeTokens = "tokens"
+)
+
+// RequestOptions holds the optional Gremlin Server request arguments.
+// Zero values are treated as unset, so options can be layered: client level defaults
+// are only applied where the request itself has not set a value. ManageTransaction is
+// a pointer so that a request can turn off a transaction the client defaults to.
+type RequestOptions struct {
+	BatchSize             int
+	EvaluationTimeout     time.Duration
+	MaterializeProperties string
+	UserAgent             string
+	Language              string
+	Aliases               map[string]string
+	ManageTransaction     *bool
+}
+
+// apply copies every set option onto the args, overriding existing values
+func (o RequestOptions) apply(args *RequestArgs) {
+	if o.BatchSize != 0 {
+		args.BatchSize = o.BatchSize
+	}
+	if o.EvaluationTimeout != 0 {
+		args.EvaluationTimeout = timeoutMillis(o.EvaluationTimeout)
+	}
+	if o.MaterializeProperties != "" {
+		args.MaterializeProperties = o.MaterializeProperties
+	}
+	if o.UserAgent != "" {
+		args.UserAgent = o.UserAgent
+	}
+	if o.Language != "" {
+		args.Language = o.Language
+	}
+	if len(o.Aliases) > 0 {
+		args.Aliases = o.Aliases
+	}
+	if o.ManageTransaction != nil {
+		args.ManageTransaction = o.ManageTransaction
+	}
+}
+
+// applyDefaults copies the set options onto the args only where the args have no value yet
+func (o RequestOptions) applyDefaults(args *RequestArgs) {
+	if args.BatchSize == 0 {
+		args.BatchSize = o.BatchSize
+	}
+	if args.EvaluationTimeout == 0 {
+		args.EvaluationTimeout = timeoutMillis(o.EvaluationTimeout)
+	}
+	if args.MaterializeProperties == "" {
+		args.MaterializeProperties = o.MaterializeProperties
+	}
+	if args.UserAgent == "" {
+		args.UserAgent = o.UserAgent
+	}
+	if args.Language == "" {
+		args.Language = o.Language
+	}
+	if len(args.Aliases) == 0 {
+		args.Aliases = o.Aliases
+	}
+	if args.ManageTransaction == nil {
+		args.ManageTransaction = o.ManageTransaction
+	}
 }
 
 // Formats the requests in the appropriate way
@@ -39,39 +131,63 @@ type FormattedReq struct {
 	Processor string       `json:"processor"`
 }
 
+const graphSONMimeType = "application/vnd.gremlin-v2.0+json"
+
 func GraphSONSerializer(req *Request) ([]byte, error) {
+	return graphSONSerializer(req, GraphSONv2)
+}
+
+// graphSONSerializer serializes the request with the given GraphSON version, typing the binding values
+func graphSONSerializer(req *Request, version GraphSONVersion) ([]byte, error) {
 	form := NewFormattedReq(req)
+	if req.Args != nil && (req.Args.Bindings != nil || req.Args.Bytecode != nil) {
+		args := *req.Args
+		bindings, err := graphSONBindings(req.Args.Bindings, version)
+		if err != nil {
+			return nil, err
+		}
+		args.Bindings = bindings
+		if args.Bytecode, err = toGraphSON(req.Args.Bytecode, version); err != nil {
+			return nil, err
+		}
+		form.Args = &args
+	}
 	msg, err := json.Marshal(form)
 	if err != nil {
 		return nil, err
 	}
 
-	// todo : update this so that you can have multiple versions of graphson
-	// original version: application/vnd.gremlin-v2.0+json
-	mimeType := []byte("application/vnd.gremlin-v2.0+json")
-	var mimeLen = []byte{0x21}
-	res := append(mimeLen, mimeType...)
+	mimeType := []byte(version.mimeType())
+	res := append([]byte{byte(len(mimeType))}, mimeType...)
 	res = append(res, msg...)
 	return res, nil
-
 }
 
 func NewFormattedReq(req *Request) FormattedReq {
-	rId := map[string]string{"@type": "g:UUID", "@value": req.RequestId}
+	rId := graphSONUUID(req.RequestId)
 	sr := FormattedReq{RequestId: rId, Processor: req.Processor, Op: req.Op, Args: req.Args}
 
 	return sr
 }
 
+// graphSONUUID types a uuid string for the server
+func graphSONUUID(id string) map[string]string {
+	return map[string]string{"@type": "g:UUID", "@value": id}
+}
+
 type Bind map[string]interface{}
 
+func newRequestId() string {
+	return uuid.Must(uuid.NewV4()).String()
+}
+
 func Query(query string) *Request {
 	args := &RequestArgs{
 		Gremlin:  query,
 		Language: "gremlin-groovy",
 	}
 	req := &Request{
-		RequestId: uuid.Must(uuid.NewV4()).String(),
+		RequestId: newRequestId(),
 		Op:        "eval",
 		Processor: "", // used to be ""
 		Args:      args,
@@ -85,7 +201,7 @@ func (req *Request) Bindings(bindings Bind) *Request {
 }
 
 func (req *Request) ManageTransaction(flag bool) *Request {
-	req.Args.ManageTransaction = flag
+	req.Args.ManageTransaction = &flag
 	return req
 }
 
@@ -104,6 +220,79 @@ func (req *Request) SetProcessor(processor string) *Request {
 	return req
 }
 
+// BatchSize sets the number of results the server sends per partial content response
+func (req *Request) BatchSize(size int) *Request {
+	req.Args.BatchSize = size
+	return req
+}
+
+// EvaluationTimeout overrides the server side timeout for this request. It is sent in milliseconds,
+// rounded up so that a timeout under a millisecond isn't taken for no timeout.
+func (req *Request) EvaluationTimeout(timeout time.Duration) *Request {
+	req.Args.EvaluationTimeout = timeoutMillis(timeout)
+	return req
+}
+
+// timeoutMillis converts a timeout to the milliseconds the server expects. The server reads 0 as unset,
+// so positive timeouts are rounded up to a millisecond.
+func timeoutMillis(timeout time.Duration) int64 {
+	ms := int64(timeout / time.Millisecond)
+	if timeout%time.Millisecond > 0 {
+		ms++
+	}
+	return ms
+}
+
+// MaterializeProperties controls whether elements are returned with their properties (MaterializeAll)
+// or as references only (MaterializeTokens)
+func (req *Request) MaterializeProperties(mode string) *Request {
+	req.Args.MaterializeProperties = mode
+	return req
+}
+
+func (req *Request) UserAgent(userAgent string) *Request {
+	req.Args.UserAgent = userAgent
+	return req
+}
+
+// Options sets every non zero option on the request, overriding any value already present
+func (req *Request) Options(opts RequestOptions) *Request {
+	opts.apply(req.Args)
+	return req
+}
+
+// withDefaults returns a copy of the request with the given defaults filled in for unset arguments,
+// leaving the caller's request untouched. The bindings and aliases are copied too, so interceptors
+// can change them without reaching the caller's maps or the client's defaults.
+func (req *Request) withDefaults(opts RequestOptions) *Request {
+	args := &RequestArgs{}
+	if req.Args != nil {
+		*args = *req.Args
+	}
+	opts.applyDefaults(args)
+	if args.Bindings != nil {
+		bindings := make(Bind, len(args.Bindings))
+		for k, v := range args.Bindings {
+			bindings[k] = v
+		}
+		args.Bindings = bindings
+	}
+	if args.Aliases != nil {
+		aliases := make(map[string]string, len(args.Aliases))
+		for k, v := range args.Aliases {
+			aliases[k] = v
+		}
+		args.Aliases = aliases
+	}
+	if args.ManageTransaction != nil {
+		manage := *args.ManageTransaction
+		args.ManageTransaction = &manage
+	}
+	r := *req
+	r.Args = args
+	return &r
+}
+
 // Adding this in for backwards compatability.
 func (req *Request) Exec() (data []byte, err error) {
 	if defaultClient == nil {
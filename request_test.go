@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/satori/go.uuid"
 	"encoding/json"
+	"time"
 )
 
 
@@ -22,6 +23,62 @@ func TestQuery(t *testing.T) {
 
 
 
+func TestRequestOptions(t *testing.T) {
+	req := Query("g.V()").BatchSize(10).EvaluationTimeout(2 * time.Second)
+	assert.Equal(t, 10, req.Args.BatchSize)
+	assert.Equal(t, int64(2000), req.Args.EvaluationTimeout)
+
+	req.Options(RequestOptions{UserAgent: "tests", MaterializeProperties: MaterializeTokens})
+	assert.Equal(t, "tests", req.Args.UserAgent)
+	assert.Equal(t, MaterializeTokens, req.Args.MaterializeProperties)
+	assert.Equal(t, 10, req.Args.BatchSize)
+
+	// client defaults only fill in what the request has not set
+	defaults := RequestOptions{BatchSize: 64, EvaluationTimeout: time.Minute, UserAgent: "client"}
+	merged := req.withDefaults(defaults)
+	assert.Equal(t, 10, merged.Args.BatchSize)
+	assert.Equal(t, int64(2000), merged.Args.EvaluationTimeout)
+	assert.Equal(t, "tests", merged.Args.UserAgent)
+	assert.Equal(t, 64, Query("g.V()").withDefaults(defaults).Args.BatchSize)
+
+	// timeouts under a millisecond are rounded up rather than sent as unset
+	assert.Equal(t, int64(1), Query("g.V()").EvaluationTimeout(time.Microsecond).Args.EvaluationTimeout)
+	assert.Equal(t, int64(3), Query("g.V()").EvaluationTimeout(2500*time.Microsecond).Args.EvaluationTimeout)
+	assert.Equal(t, int64(1), Query("g.V()").Options(RequestOptions{EvaluationTimeout: time.Nanosecond}).Args.EvaluationTimeout)
+	assert.Equal(t, int64(1), Query("g.V()").withDefaults(RequestOptions{EvaluationTimeout: time.Nanosecond}).Args.EvaluationTimeout)
+	assert.Equal(t, int64(0), Query("g.V()").EvaluationTimeout(0).Args.EvaluationTimeout)
+
+	// the original request is left untouched
+	merged.Args.BatchSize = 1
+	assert.Equal(t, 10, req.Args.BatchSize)
+
+	msg, err := json.Marshal(merged.Args)
+	assert.Empty(t, err)
+	assert.Contains(t, string(msg), `"evaluationTimeout":2000`)
+	assert.Contains(t, string(msg), `"materializeProperties":"tokens"`)
+
+	// a request can turn off the transaction the client manages by default
+	manage := true
+	defaults = RequestOptions{ManageTransaction: &manage}
+	assert.Equal(t, true, *Query("g.V()").withDefaults(defaults).Args.ManageTransaction)
+	optOut := Query("g.V()").ManageTransaction(false).withDefaults(defaults)
+	assert.Equal(t, false, *optOut.Args.ManageTransaction)
+	msg, err = json.Marshal(optOut.Args)
+	assert.Empty(t, err)
+	assert.Contains(t, string(msg), `"manageTransaction":false`)
+
+	// the copy has its own bindings and aliases
+	bindings := Bind{"name": "marko"}
+	aliases := map[string]string{"g": "graph.traversal()"}
+	copied := Query("g.V()").Bindings(bindings).Aliases(aliases).withDefaults(RequestOptions{})
+	copied.Args.Bindings["name"] = "vadas"
+	copied.Args.Aliases["g"] = "other"
+	assert.Equal(t, "marko", bindings["name"])
+	assert.Equal(t, "graph.traversal()", aliases["g"])
+}
+
+
+
 func TestReadWrite(t *testing.T) {
 	assert.NotEmpty(t, testendpoint)
 
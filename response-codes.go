@@ -2,15 +2,21 @@ package gremlin
 
 import "errors"
 
+// Status codes of the TinkerPop 3.x server protocol
 const (
 	StatusSuccess                  = 200
 	StatusNoContent                = 204
 	StatusPartialContent           = 206
 	StatusUnauthorized             = 401
+	StatusForbidden                = 403
 	StatusAuthenticate             = 407
+	StatusTooManyRequests          = 429
+	StatusRequestSerializationError = 497
 	StatusMalformedRequest         = 498
 	StatusInvalidRequestArguments  = 499
 	StatusServerError              = 500
+	StatusFailStep                 = 595
+	StatusServerErrorTemporary     = 596
 	StatusScriptEvaluationError    = 597
 	StatusServerTimeout            = 598
 	StatusServerSerializationError = 599
@@ -18,10 +24,15 @@ const (
 
 var ErrorMsg = map[int]string{
 	StatusUnauthorized:             "Unauthorized",
+	StatusForbidden:                "Forbidden",
 	StatusAuthenticate:             "Authenticate",
+	StatusTooManyRequests:          "Too Many Requests",
+	StatusRequestSerializationError: "Request Serialization Error",
 	StatusMalformedRequest:         "Malformed Request",
 	StatusInvalidRequestArguments:  "Invalid Request Arguments",
 	StatusServerError:              "Server Error",
+	StatusFailStep:                 "Fail Step",
+	StatusServerErrorTemporary:     "Server Error Temporary",
 	StatusScriptEvaluationError:    "Script Evaluation Error",
 	StatusServerTimeout:            "Server Timeout",
 	StatusServerSerializationError: "Server Serialization Error",
@@ -29,11 +40,41 @@ var ErrorMsg = map[int]string{
 
 var ConnectionErrors = map[int]error {
 	StatusUnauthorized:             errors.New("unauthorized"),
+	StatusForbidden:                errors.New("forbidden"),
 	StatusAuthenticate:             errors.New("authenticate"),
+	StatusTooManyRequests:          errors.New("too many requests"),
+	StatusRequestSerializationError: errors.New("request serialization error"),
 	StatusMalformedRequest:         errors.New("malformed request"),
 	StatusInvalidRequestArguments:  errors.New("invalid request arguments"),
 	StatusServerError:              errors.New("server error"),
+	StatusFailStep:                 errors.New("fail step"),
+	StatusServerErrorTemporary:     errors.New("temporary server error"),
 	StatusScriptEvaluationError:    errors.New("script evaluation error"),
 	StatusServerTimeout:            errors.New("server timeout"),
 	StatusServerSerializationError: errors.New("server serialization error"),
-}
\ No newline at end of file
+}
+
+// errorKind returns the kind of error an error status code stands for
+func errorKind(code int) error {
+	switch code {
+	case StatusUnauthorized, StatusForbidden, StatusAuthenticate:
+		return ErrAuth
+	case StatusRequestSerializationError, StatusMalformedRequest, StatusServerSerializationError:
+		return ErrProtocol
+	case StatusInvalidRequestArguments, StatusFailStep, StatusScriptEvaluationError:
+		return ErrQuery
+	case StatusServerTimeout:
+		return ErrTimeout
+	case StatusTooManyRequests:
+		return ErrServer
+	}
+	if code >= 500 {
+		return ErrServer
+	}
+	return ErrProtocol
+}
+
+// temporaryStatus reports whether the request may succeed if it is sent again later
+func temporaryStatus(code int) bool {
+	return code == StatusTooManyRequests || code == StatusServerErrorTemporary
+}
@@ -0,0 +1,221 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+var errorKinds = []error{ErrTransport, ErrProtocol, ErrServer, ErrQuery, ErrAuth, ErrTimeout}
+
+func TestResponseErrorKinds(t *testing.T) {
+	cases := []struct {
+		code      int
+		kind      error
+		penalizes bool
+	}{
+		{StatusUnauthorized, ErrAuth, false},
+		{StatusForbidden, ErrAuth, false},
+		{StatusAuthenticate, ErrAuth, false},
+		{StatusTooManyRequests, ErrServer, true},
+		{StatusRequestSerializationError, ErrProtocol, false},
+		{StatusMalformedRequest, ErrProtocol, false},
+		{StatusInvalidRequestArguments, ErrQuery, false},
+		{StatusServerError, ErrServer, true},
+		{StatusFailStep, ErrQuery, false},
+		{StatusServerErrorTemporary, ErrServer, true},
+		{StatusScriptEvaluationError, ErrQuery, false},
+		{StatusServerTimeout, ErrTimeout, false},
+		{StatusServerSerializationError, ErrProtocol, false},
+		{555, ErrServer, true},
+		{444, ErrProtocol, false},
+	}
+	for _, c := range cases {
+		err := &ResponseError{Code: c.code, Message: "message"}
+		for _, kind := range errorKinds {
+			assert.Equal(t, kind == c.kind, errors.Is(err, kind), "%d is %v", c.code, kind)
+		}
+		assert.Equal(t, c.penalizes, penalizesEndpoint(err), "%d penalizes the endpoint", c.code)
+		if known, ok := ConnectionErrors[c.code]; ok {
+			assert.True(t, errors.Is(err, known))
+		} else {
+			assert.True(t, errors.Is(err, UnknownErr))
+		}
+		assert.True(t, strings.HasSuffix(err.Error(), ": message"))
+		assert.Equal(t, c.code == StatusTooManyRequests || c.code == StatusServerErrorTemporary, err.Temporary())
+	}
+	// every known code has a message and an error
+	for code := range ErrorMsg {
+		assert.NotNil(t, ConnectionErrors[code], "%d", code)
+	}
+}
+
+func TestStatusAttributes(t *testing.T) {
+	var res Response
+	err := json.Unmarshal([]byte(`{"requestId": "1", "status": {"code": 597, "message": "No such property: x", "attributes": {
+		"exceptions": ["groovy.lang.MissingPropertyException", "javax.script.ScriptException"],
+		"stackTrace": "groovy.lang.MissingPropertyException: No such property: x",
+		"aggregateTo": "list",
+		"warnings": "deprecated step"}}}`), &res)
+	assert.Empty(t, err)
+	attrs := res.Status.Attributes
+	assert.Equal(t, []string{"groovy.lang.MissingPropertyException", "javax.script.ScriptException"}, attrs.Exceptions())
+	assert.Equal(t, "groovy.lang.MissingPropertyException: No such property: x", attrs.StackTrace())
+	assert.Equal(t, "list", attrs.AggregateTo())
+	assert.Equal(t, []string{"deprecated step"}, attrs.Warnings())
+
+	// graphson 3 wraps lists in a typed value
+	attrs = StatusAttributes{}
+	err = json.Unmarshal([]byte(`{"exceptions": {"@type": "g:List", "@value": ["java.lang.IllegalStateException"]}}`), &attrs)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"java.lang.IllegalStateException"}, attrs.Exceptions())
+	assert.Empty(t, attrs.Warnings())
+	assert.Equal(t, "", attrs.StackTrace())
+}
+
+func TestServerWarnings(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		partial := testResponse(req, StatusPartialContent, "[1]")
+		partial.Status.Attributes = StatusAttributes{"warnings": []string{"first"}}
+		last := testResponse(req, StatusSuccess, "[2]")
+		last.Status.Attributes = StatusAttributes{"warnings": []string{"second", "third"}}
+		return []*Response{partial, last}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	res, err := cl.Do(context.Background(), Query("g.V()"))
+	assert.Empty(t, err)
+	assert.Equal(t, "[1,2]", string(res.Data))
+	assert.Equal(t, []string{"first", "second", "third"}, res.Warnings)
+}
+
+func TestResponseErrorAttributes(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		res := testResponse(req, StatusServerErrorTemporary, "")
+		res.Status.Message = "try again"
+		res.Status.Attributes = StatusAttributes{"exceptions": []string{"org.janusgraph.core.JanusGraphException"}}
+		return []*Response{res}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	_, err = cl.ExecQuery("g.V()")
+	var respErr *ResponseError
+	if assert.True(t, errors.As(err, &respErr)) {
+		assert.Equal(t, StatusServerErrorTemporary, respErr.Code)
+		assert.Equal(t, "try again", respErr.Message)
+		assert.True(t, respErr.Temporary())
+		assert.Equal(t, []string{"org.janusgraph.core.JanusGraphException"}, respErr.Attributes.Exceptions())
+	}
+}
+
+func TestTransportErrorKinds(t *testing.T) {
+	cause := errors.New("broken pipe")
+	var err error = &TransportError{Endpoint: "ws://a:8182", Err: cause}
+	assert.True(t, errors.Is(err, ErrTransport))
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, penalizesEndpoint(err))
+	assert.Equal(t, "ws://a:8182: broken pipe", err.Error())
+
+	err = &ProtocolError{Err: cause}
+	assert.True(t, errors.Is(err, ErrProtocol))
+	assert.False(t, penalizesEndpoint(err))
+
+	err = &EndpointsUnavailableError{}
+	assert.True(t, errors.Is(err, ErrAllEndpointsUnavailable))
+}
+
+func TestEndpointHealthByStatusCode(t *testing.T) {
+	for _, code := range []int{StatusUnauthorized, StatusMalformedRequest, StatusInvalidRequestArguments,
+		StatusServerError, StatusScriptEvaluationError, StatusServerTimeout, StatusServerSerializationError} {
+		server := newTestServer(func(req *testRequest) []*Response {
+			return []*Response{testResponse(req, code, "")}
+		})
+		opts := CircuitBreakerOptions{MinRequests: 2, FailureRate: 1}
+		cl, err := NewClientWithOptions(server.Url, OptClientCircuitBreaker(opts))
+		if !assert.Empty(t, err) {
+			server.Close()
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			_, err = cl.ExecQuery("1")
+			assert.True(t, errors.Is(err, errorKind(code)))
+		}
+		status := cl.Endpoints()[0]
+		assert.Equal(t, code == StatusServerError, status.OnIce, "%d puts the endpoint on ice", code)
+		if code == StatusServerError {
+			assert.True(t, errors.Is(status.LastError, ErrServer))
+		}
+		cl.Close()
+		server.Close()
+	}
+}
+
+func TestTransportFailureCountsAgainstEndpoint(t *testing.T) {
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// hang up as soon as a request arrives
+		ws.ReadMessage()
+		ws.Close()
+	}))
+	defer server.Close()
+	cl, err := NewClientWithOptions("ws"+strings.TrimPrefix(server.URL, "http"),
+		OptClientCircuitBreaker(CircuitBreakerOptions{MinRequests: 1}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	_, err = cl.ExecQuery("1")
+	assert.True(t, errors.Is(err, ErrTransport))
+	assert.False(t, errors.Is(err, ErrQuery))
+	assert.True(t, cl.Endpoints()[0].OnIce)
+}
+
+func TestProtocolErrorKeepsEndpointHealthy(t *testing.T) {
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+			ws.WriteMessage(websocket.TextMessage, []byte(`{"requestId": "1"}`))
+		}
+	}))
+	defer server.Close()
+	cl, err := NewClientWithOptions("ws"+strings.TrimPrefix(server.URL, "http"),
+		OptClientCircuitBreaker(CircuitBreakerOptions{MinRequests: 1}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	_, err = cl.ExecQuery("1")
+	assert.True(t, errors.Is(err, ErrProtocol))
+	assert.True(t, errors.Is(err, MissingStatusError))
+	assert.False(t, cl.Endpoints()[0].OnIce)
+}
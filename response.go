@@ -12,9 +12,71 @@ type Response struct {
 }
 
 type ResponseStatus struct {
-	Code       int                    `json:"code"`
-	Attributes map[string]interface{} `json:"attributes"`
-	Message    string                 `json:"message"`
+	Code       int              `json:"code"`
+	Attributes StatusAttributes `json:"attributes"`
+	Message    string           `json:"message"`
+}
+
+// StatusAttributes are the attributes the server sends along with the status of a response
+type StatusAttributes map[string]interface{}
+
+// UnmarshalJSON accepts the attributes both as a plain object and as a GraphSON 3 g:Map
+func (a *StatusAttributes) UnmarshalJSON(b []byte) error {
+	var m graphSONMapValue
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*a = StatusAttributes(m)
+	return nil
+}
+
+// Exceptions returns the class names of the exception that failed the request and of its causes
+func (a StatusAttributes) Exceptions() []string {
+	return a.strings("exceptions")
+}
+
+// StackTrace returns the stack trace of the exception that failed the request
+func (a StatusAttributes) StackTrace() string {
+	s, _ := graphSONValue(a["stackTrace"]).(string)
+	return s
+}
+
+// AggregateTo returns the kind of collection a side effect is aggregated into (list, set, map, bulkset or none)
+func (a StatusAttributes) AggregateTo() string {
+	s, _ := graphSONValue(a["aggregateTo"]).(string)
+	return s
+}
+
+// Warnings returns the warnings the server attached to the response, they don't fail the request
+func (a StatusAttributes) Warnings() []string {
+	return a.strings("warnings")
+}
+
+// strings returns an attribute that is either a single string or a list of strings
+func (a StatusAttributes) strings(key string) []string {
+	switch v := graphSONValue(a[key]).(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var s []string
+		for _, item := range v {
+			if str, ok := graphSONValue(item).(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// graphSONValue strips the type of a typed GraphSON value, returning plain values as they are
+func graphSONValue(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		if _, typed := m["@type"]; typed {
+			return m["@value"]
+		}
+	}
+	return v
 }
 
 type ResponseResult struct {
@@ -22,6 +84,19 @@ type ResponseResult struct {
 	Meta map[string]interface{} `json:"meta"`
 }
 
+// UnmarshalJSON accepts the meta data both as a plain object and as a GraphSON 3 g:Map
+func (r *ResponseResult) UnmarshalJSON(b []byte) error {
+	var res struct {
+		Data json.RawMessage  `json:"data"`
+		Meta graphSONMapValue `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	r.Data, r.Meta = res.Data, res.Meta
+	return nil
+}
+
 // Implementation of the stringer interface. Useful for exploration
 func (r Response) String() string {
 	return fmt.Sprintf("Response \nRequestId: %v, \nStatus: {%#v}, \nResult: {%#v}\n", r.RequestId, r.Status, r.Result)
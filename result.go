@@ -0,0 +1,128 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Result holds the response to a request. Data is the JSON array of results, aggregated
+// over all partial content responses, or nil if the server returned no content.
+type Result struct {
+	RequestId  string
+	StatusCode int
+	Data       json.RawMessage
+	// Warnings sent by the server, the request succeeded regardless
+	Warnings []string
+	// Batches describes each response message the result was aggregated from
+	Batches []Batch
+	// Endpoint is the url of the server that answered the request
+	Endpoint string
+
+	client  *Client
+	aliases map[string]string
+}
+
+// Batch is the status and meta data of a single response message
+type Batch struct {
+	StatusCode  int
+	ResultCount int
+	Meta        map[string]interface{}
+	Attributes  StatusAttributes
+}
+
+type batchHandlerKey struct{}
+
+// withBatchHandler returns a context whose request hands the results of every response message to handle as
+// they are read, instead of aggregating them into the data of the result. Transports that read the response
+// in one piece leave the results in the data.
+func withBatchHandler(ctx context.Context, handle func(items []json.RawMessage)) context.Context {
+	return context.WithValue(ctx, batchHandlerKey{}, handle)
+}
+
+func batchHandler(ctx context.Context) func(items []json.RawMessage) {
+	handle, _ := ctx.Value(batchHandlerKey{}).(func(items []json.RawMessage))
+	return handle
+}
+
+func newBatch(res *Response) Batch {
+	b := Batch{StatusCode: res.Status.Code, Attributes: res.Status.Attributes}
+	if res.Result != nil {
+		b.Meta = res.Result.Meta
+	}
+	return b
+}
+
+// Meta returns the result meta data of all batches, values of later batches override those of earlier ones
+func (r *Result) Meta() map[string]interface{} {
+	meta := map[string]interface{}{}
+	for _, b := range r.Batches {
+		for k, v := range b.Meta {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+// Attributes returns the status attributes of all batches, values of later batches override those of earlier ones
+func (r *Result) Attributes() StatusAttributes {
+	attrs := StatusAttributes{}
+	for _, b := range r.Batches {
+		for k, v := range b.Attributes {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// AggregateTo returns the kind of collection a side effect is aggregated into (list, set, map, bulkset or none).
+// The server sends it in the result meta data, older versions in the status attributes.
+func (r *Result) AggregateTo() string {
+	if s, ok := graphSONValue(r.Meta()["aggregateTo"]).(string); ok {
+		return s
+	}
+	return r.Attributes().AggregateTo()
+}
+
+// Traversers decodes the results with DecodeGraphSON. Results that aren't traversers, such as the results
+// of a script, have a bulk of 1.
+func (r *Result) Traversers() ([]Traverser, error) {
+	if len(r.Data) == 0 {
+		return nil, nil
+	}
+	items, err := resultItems(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTraversers(items)
+}
+
+func decodeTraversers(items []json.RawMessage) ([]Traverser, error) {
+	traversers := make([]Traverser, 0, len(items))
+	for _, item := range items {
+		v, err := DecodeGraphSON(item)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := v.(Traverser)
+		if !ok {
+			t = Traverser{Value: v, Bulk: 1}
+		}
+		traversers = append(traversers, t)
+	}
+	return traversers, nil
+}
+
+// Values decodes the results with DecodeGraphSON, repeating the value of each traverser by its bulk
+func (r *Result) Values() ([]interface{}, error) {
+	traversers, err := r.Traversers()
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, t := range traversers {
+		for i := int64(0); i < t.Bulk; i++ {
+			values = append(values, t.Value)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,124 @@
+package gremlin
+
+import (
+	"context"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultMeta(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		partial := testResponse(req, StatusPartialContent, "[1,2]")
+		partial.Result.Meta = map[string]interface{}{"host": "a", "batch": 1.0}
+		last := testResponse(req, StatusSuccess, "[3]")
+		last.Result.Meta = map[string]interface{}{"batch": 2.0}
+		last.Status.Attributes = StatusAttributes{"host": "/127.0.0.1:8182"}
+		return []*Response{partial, last}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	res, err := cl.Do(context.Background(), Query("g.V()"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	assert.Len(t, res.Batches, 2)
+	assert.Equal(t, StatusPartialContent, res.Batches[0].StatusCode)
+	assert.Equal(t, 2, res.Batches[0].ResultCount)
+	assert.Equal(t, 1, res.Batches[1].ResultCount)
+	assert.Equal(t, "a", res.Batches[0].Meta["host"])
+	assert.Equal(t, map[string]interface{}{"host": "a", "batch": 2.0}, res.Meta())
+	assert.Equal(t, "/127.0.0.1:8182", res.Attributes()["host"])
+}
+
+func TestSideEffects(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		switch req.Op {
+		case "keys":
+			return []*Response{testResponse(req, StatusSuccess, `["x","y"]`)}
+		case "gather":
+			res := testResponse(req, StatusSuccess, `[{"@type":"g:Int64","@value":1}]`)
+			res.Result.Meta["aggregateTo"] = "bulkset"
+			return []*Response{res}
+		case "close":
+			return []*Response{testResponse(req, StatusNoContent, "")}
+		}
+		return []*Response{testResponse(req, StatusSuccess, "[]")}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	ctx := context.Background()
+	res, err := cl.Do(ctx, Query("g.V().aggregate('x')").Aliases(map[string]string{"g": "tenant.g"}))
+	if !assert.Empty(t, err) {
+		return
+	}
+	sideEffects := res.SideEffects()
+	keys, err := sideEffects.Keys(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"x", "y"}, keys)
+
+	x, err := sideEffects.Get(ctx, "x")
+	assert.Empty(t, err)
+	assert.Equal(t, "bulkset", x.AggregateTo())
+	assert.Equal(t, `[{"@type":"g:Int64","@value":1}]`, string(x.Data))
+
+	assert.Empty(t, sideEffects.Close(ctx))
+
+	received := server.received()
+	if assert.Len(t, received, 4) {
+		sideEffect := map[string]interface{}{"@type": "g:UUID", "@value": res.RequestId}
+		for _, req := range received[1:] {
+			assert.Equal(t, "traversal", req.Processor)
+			assert.Equal(t, sideEffect, req.Args["sideEffect"])
+		}
+		assert.Equal(t, "x", received[2].Args["sideEffectKey"])
+		assert.Equal(t, map[string]interface{}{"g": "tenant.g"}, received[2].Args["aliases"])
+	}
+
+	_, err = (&Result{}).SideEffects().Keys(ctx)
+	assert.Equal(t, NoSideEffectsError, err)
+}
+
+func TestSideEffectsOnTheirEndpoint(t *testing.T) {
+	first := newTestServer(successResponse("[]"))
+	defer first.Close()
+	second := newTestServer(successResponse("[]"))
+	defer second.Close()
+	cl, err := NewClient(first.Url + "," + second.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	ctx := context.Background()
+	res, err := cl.Do(ctx, Query("g.V().aggregate('x')"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	server, other := first, second
+	if res.Endpoint == second.Url {
+		server, other = second, first
+	} else {
+		assert.Equal(t, first.Url, res.Endpoint)
+	}
+	sideEffects := res.SideEffects()
+	for i := 0; i < 2; i++ {
+		emptyPool(t, cl)
+		_, err = sideEffects.Keys(ctx)
+		assert.Empty(t, err)
+	}
+	emptyPool(t, cl)
+	assert.Empty(t, sideEffects.Close(ctx))
+
+	assert.Len(t, server.received(), 4)
+	assert.Empty(t, other.received())
+}
@@ -0,0 +1,368 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+var ConflictError = errors.New("the plan has conflicts that can't be applied, see Plan.Conflicts")
+
+// InvalidSchemaError is returned for a desired schema that can't be created
+type InvalidSchemaError struct {
+	Reason string
+}
+
+func (e *InvalidSchemaError) Error() string {
+	return "invalid schema: " + e.Reason
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &InvalidSchemaError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Change is a schema element to create, only one of its fields is set
+type Change struct {
+	PropertyKey *PropertyKey
+	VertexLabel *VertexLabel
+	EdgeLabel   *EdgeLabel
+	Index       *Index
+}
+
+func (c Change) String() string {
+	switch {
+	case c.PropertyKey != nil:
+		return fmt.Sprintf("property key %s (%s, %s)", c.PropertyKey.Name, c.PropertyKey.DataType, c.PropertyKey.Cardinality)
+	case c.VertexLabel != nil:
+		return "vertex label " + c.VertexLabel.Name
+	case c.EdgeLabel != nil:
+		return fmt.Sprintf("edge label %s (%s)", c.EdgeLabel.Name, c.EdgeLabel.Multiplicity)
+	case c.Index != nil:
+		i := c.Index
+		s := fmt.Sprintf("composite index %s on %s(%s)", i.Name, i.Element, strings.Join(i.Keys, ", "))
+		if !i.Composite() {
+			s = fmt.Sprintf("mixed index %s on %s(%s) in %s", i.Name, i.Element, strings.Join(i.Keys, ", "), i.Backend)
+		}
+		if i.Unique {
+			s += " unique"
+		}
+		if i.IndexOnly != "" {
+			s += " only " + i.IndexOnly
+		}
+		return s
+	}
+	return ""
+}
+
+// Plan holds the changes that bring a graph's schema to the desired schema. JanusGraph can't change
+// the definition of existing schema elements, differences to them are reported as conflicts instead.
+// Schema elements that aren't part of the desired schema are left alone.
+type Plan struct {
+	Changes   []Change
+	Conflicts []string
+	Warnings  []string
+}
+
+// Empty reports whether the schema is already up to date
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0 && len(p.Conflicts) == 0
+}
+
+// String describes the plan, for a dry run
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "schema is up to date\n"
+	}
+	var b strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintf(&b, "+ %s\n", c)
+	}
+	for _, c := range p.Conflicts {
+		fmt.Fprintf(&b, "! %s\n", c)
+	}
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	return b.String()
+}
+
+// Diff compares the current schema with the desired one
+func Diff(current, desired *Schema) (*Plan, error) {
+	if err := validate(current, desired); err != nil {
+		return nil, err
+	}
+	current, desired = withDefaults(current), withDefaults(desired)
+	plan := &Plan{}
+
+	keys := map[string]PropertyKey{}
+	for _, k := range current.PropertyKeys {
+		keys[k.Name] = k
+	}
+	for _, k := range desired.PropertyKeys {
+		existing, ok := keys[k.Name]
+		switch {
+		case !ok:
+			key := k
+			plan.Changes = append(plan.Changes, Change{PropertyKey: &key})
+		case existing.DataType != k.DataType:
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("property key %s is a %s, not a %s", k.Name, existing.DataType, k.DataType))
+		case existing.Cardinality != k.Cardinality:
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("property key %s has cardinality %s, not %s", k.Name, existing.Cardinality, k.Cardinality))
+		}
+	}
+
+	vertexLabels := map[string]bool{}
+	for _, l := range current.VertexLabels {
+		vertexLabels[l.Name] = true
+	}
+	for _, l := range desired.VertexLabels {
+		if !vertexLabels[l.Name] {
+			label := l
+			plan.Changes = append(plan.Changes, Change{VertexLabel: &label})
+		}
+	}
+
+	edgeLabels := map[string]EdgeLabel{}
+	for _, l := range current.EdgeLabels {
+		edgeLabels[l.Name] = l
+	}
+	for _, l := range desired.EdgeLabels {
+		existing, ok := edgeLabels[l.Name]
+		switch {
+		case !ok:
+			label := l
+			plan.Changes = append(plan.Changes, Change{EdgeLabel: &label})
+		case existing.Multiplicity != l.Multiplicity:
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("edge label %s has multiplicity %s, not %s", l.Name, existing.Multiplicity, l.Multiplicity))
+		}
+	}
+
+	indexes := map[string]Index{}
+	for _, i := range current.Indexes {
+		indexes[i.Name] = i
+	}
+	for _, i := range desired.Indexes {
+		existing, ok := indexes[i.Name]
+		if !ok {
+			index := i
+			plan.Changes = append(plan.Changes, Change{Index: &index})
+			for _, key := range i.Keys {
+				if _, exists := keys[key]; exists {
+					plan.Warnings = append(plan.Warnings, fmt.Sprintf("index %s covers the existing property key %s, reindex it to include existing data", i.Name, key))
+					break
+				}
+			}
+			continue
+		}
+		if difference := indexDifference(existing, i); difference != "" {
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("index %s %s", i.Name, difference))
+		}
+		if existing.Status != "" && existing.Status != "ENABLED" {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("index %s is %s, not ENABLED", i.Name, existing.Status))
+		}
+	}
+	return plan, nil
+}
+
+// withDefaults returns a copy of the schema with the default cardinality, multiplicity and element type filled in
+func withDefaults(s *Schema) *Schema {
+	c := &Schema{
+		PropertyKeys: append([]PropertyKey(nil), s.PropertyKeys...),
+		VertexLabels: s.VertexLabels,
+		EdgeLabels:   append([]EdgeLabel(nil), s.EdgeLabels...),
+		Indexes:      append([]Index(nil), s.Indexes...),
+	}
+	for i := range c.PropertyKeys {
+		if c.PropertyKeys[i].Cardinality == "" {
+			c.PropertyKeys[i].Cardinality = Single
+		}
+	}
+	for i := range c.EdgeLabels {
+		if c.EdgeLabels[i].Multiplicity == "" {
+			c.EdgeLabels[i].Multiplicity = Multi
+		}
+	}
+	for i := range c.Indexes {
+		if c.Indexes[i].Element == "" {
+			c.Indexes[i].Element = VertexIndex
+		}
+	}
+	return c
+}
+
+// indexDifference describes how an existing index differs from the desired one
+func indexDifference(existing, desired Index) string {
+	switch {
+	case existing.Element != desired.Element:
+		return fmt.Sprintf("indexes %s elements, not %s elements", existing.Element, desired.Element)
+	case existing.Backend != desired.Backend:
+		return fmt.Sprintf("is backed by %q, not %q", existing.Backend, desired.Backend)
+	case existing.Unique != desired.Unique:
+		return fmt.Sprintf("has unique set to %t", existing.Unique)
+	case existing.IndexOnly != desired.IndexOnly:
+		return fmt.Sprintf("is restricted to label %q, not %q", existing.IndexOnly, desired.IndexOnly)
+	case strings.Join(existing.Keys, ",") != strings.Join(desired.Keys, ","):
+		return fmt.Sprintf("has keys %s, not %s", strings.Join(existing.Keys, ", "), strings.Join(desired.Keys, ", "))
+	}
+	return ""
+}
+
+// validate checks that the desired schema can be created on top of the current one
+func validate(current, desired *Schema) error {
+	keys := map[string]bool{}
+	for _, k := range current.PropertyKeys {
+		keys[k.Name] = true
+	}
+	for _, k := range desired.PropertyKeys {
+		if k.Name == "" {
+			return invalid("property key without a name")
+		}
+		if _, ok := dataTypeClasses[k.DataType]; !ok {
+			return invalid("property key %s has unknown data type %q", k.Name, k.DataType)
+		}
+		switch k.Cardinality {
+		case "", Single, List, Set:
+		default:
+			return invalid("property key %s has unknown cardinality %q", k.Name, k.Cardinality)
+		}
+		keys[k.Name] = true
+	}
+
+	labels := map[ElementType]map[string]bool{VertexIndex: {}, EdgeIndex: {}}
+	for _, l := range current.VertexLabels {
+		labels[VertexIndex][l.Name] = true
+	}
+	for _, l := range current.EdgeLabels {
+		labels[EdgeIndex][l.Name] = true
+	}
+	for _, l := range desired.VertexLabels {
+		if l.Name == "" {
+			return invalid("vertex label without a name")
+		}
+		labels[VertexIndex][l.Name] = true
+	}
+	for _, l := range desired.EdgeLabels {
+		if l.Name == "" {
+			return invalid("edge label without a name")
+		}
+		switch l.Multiplicity {
+		case "", Multi, Simple, Many2One, One2Many, One2One:
+		default:
+			return invalid("edge label %s has unknown multiplicity %q", l.Name, l.Multiplicity)
+		}
+		labels[EdgeIndex][l.Name] = true
+	}
+
+	for _, i := range desired.Indexes {
+		element := i.Element
+		if element == "" {
+			element = VertexIndex
+		}
+		switch {
+		case i.Name == "":
+			return invalid("index without a name")
+		case element != VertexIndex && element != EdgeIndex:
+			return invalid("index %s has unknown element type %q", i.Name, i.Element)
+		case len(i.Keys) == 0:
+			return invalid("index %s has no keys", i.Name)
+		case i.Unique && !i.Composite():
+			return invalid("mixed index %s can't be unique", i.Name)
+		case len(i.Mappings) > 0 && i.Composite():
+			return invalid("composite index %s can't have mappings", i.Name)
+		case i.IndexOnly != "" && !labels[element][i.IndexOnly]:
+			return invalid("index %s is restricted to unknown label %s", i.Name, i.IndexOnly)
+		}
+		for _, key := range i.Keys {
+			if !keys[key] {
+				return invalid("index %s uses unknown property key %s", i.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Request returns the management script creating the plan's changes in a single transaction for the
+// graph bound to the given variable. Names are sent as bindings.
+func (p *Plan) Request(graph string) (*gremlin.Request, error) {
+	if err := gremlin.ValidateBindingName(graph); err != nil {
+		return nil, err
+	}
+	b := gremlin.NewQueryBuilder()
+	b.Add(graph + ".openManagement().with { mgmt ->\n  try {\n")
+	for _, c := range p.Changes {
+		b.Add("    ")
+		switch {
+		case c.PropertyKey != nil:
+			b.Add("mgmt.makePropertyKey(%v).dataType("+dataTypeClasses[c.PropertyKey.DataType]+")", c.PropertyKey.Name)
+			b.Add(".cardinality(org.janusgraph.core.Cardinality." + string(c.PropertyKey.Cardinality) + ").make()")
+		case c.VertexLabel != nil:
+			b.Add("mgmt.makeVertexLabel(%v).make()", c.VertexLabel.Name)
+		case c.EdgeLabel != nil:
+			b.Add("mgmt.makeEdgeLabel(%v).multiplicity(org.janusgraph.core.Multiplicity."+string(c.EdgeLabel.Multiplicity)+").make()", c.EdgeLabel.Name)
+		case c.Index != nil:
+			addIndex(b, c.Index)
+		}
+		b.Add("\n")
+	}
+	b.Add("    mgmt.commit()\n  } catch (e) {\n    mgmt.rollback()\n    throw e\n  }\n}")
+	return b.Request()
+}
+
+func addIndex(b *gremlin.QueryBuilder, i *Index) {
+	b.Add("mgmt.buildIndex(%v, "+string(i.Element)+".class)", i.Name)
+	for _, key := range i.Keys {
+		if mapping := i.Mappings[key]; mapping != DefaultMapping {
+			b.Add(".addKey(mgmt.getPropertyKey(%v), org.janusgraph.core.schema.Mapping."+string(mapping)+".asParameter())", key)
+		} else {
+			b.Add(".addKey(mgmt.getPropertyKey(%v))", key)
+		}
+	}
+	if i.IndexOnly != "" {
+		b.Add(".indexOnly(mgmt.get"+string(i.Element)+"Label(%v))", i.IndexOnly)
+	}
+	if i.Unique {
+		b.Add(".unique()")
+	}
+	if i.Composite() {
+		b.Add(".buildCompositeIndex()")
+	} else {
+		b.Add(".buildMixedIndex(%v)", i.Backend)
+	}
+}
+
+// Plan compares the graph's schema with the desired schema
+func (m *Manager) Plan(ctx context.Context, desired *Schema) (*Plan, error) {
+	current, err := m.Inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(current, desired)
+}
+
+// Apply creates the changes of the plan. Plans with conflicts are refused with ConflictError.
+func (m *Manager) Apply(ctx context.Context, plan *Plan) error {
+	if len(plan.Conflicts) > 0 {
+		return ConflictError
+	}
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+	req, err := plan.Request(m.graph)
+	if err != nil {
+		return err
+	}
+	_, err = m.client.Do(ctx, req)
+	return err
+}
+
+// Migrate plans the changes needed for the desired schema and applies them, unless dryRun is set
+func (m *Manager) Migrate(ctx context.Context, desired *Schema, dryRun bool) (*Plan, error) {
+	plan, err := m.Plan(ctx, desired)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	return plan, m.Apply(ctx, plan)
+}
@@ -0,0 +1,226 @@
+// Package schema manages the schema of a JanusGraph database: property keys, vertex and edge labels
+// and graph indexes. The desired schema is described with Go values, compared with the schema read
+// through JanusGraph's management system, and the missing parts are created by a generated script.
+//
+//	manager, err := schema.NewManager(client, "graph")
+//	plan, err := manager.Plan(ctx, desired)
+//	fmt.Print(plan) // dry run
+//	err = manager.Apply(ctx, plan)
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-gremlin/gremlin"
+)
+
+// DataType is the data type of a property key, named after the Java class JanusGraph stores
+type DataType string
+
+const (
+	String    DataType = "String"
+	Character DataType = "Character"
+	Boolean   DataType = "Boolean"
+	Byte      DataType = "Byte"
+	Short     DataType = "Short"
+	Integer   DataType = "Integer"
+	Long      DataType = "Long"
+	Float     DataType = "Float"
+	Double    DataType = "Double"
+	Date      DataType = "Date"
+	Geoshape  DataType = "Geoshape"
+	UUID      DataType = "UUID"
+)
+
+// dataTypeClasses holds the class each data type is declared with in a management script
+var dataTypeClasses = map[DataType]string{
+	String: "String.class", Character: "Character.class", Boolean: "Boolean.class", Byte: "Byte.class",
+	Short: "Short.class", Integer: "Integer.class", Long: "Long.class", Float: "Float.class",
+	Double: "Double.class", Date: "Date.class", Geoshape: "org.janusgraph.core.attribute.Geoshape.class", UUID: "UUID.class",
+}
+
+// Cardinality is the number of values a vertex may have for a property key
+type Cardinality string
+
+const (
+	Single Cardinality = "SINGLE"
+	List   Cardinality = "LIST"
+	Set    Cardinality = "SET"
+)
+
+// Multiplicity constrains the number of edges with a label between vertices
+type Multiplicity string
+
+const (
+	Multi    Multiplicity = "MULTI"
+	Simple   Multiplicity = "SIMPLE"
+	Many2One Multiplicity = "MANY2ONE"
+	One2Many Multiplicity = "ONE2MANY"
+	One2One  Multiplicity = "ONE2ONE"
+)
+
+// ElementType is the kind of element an index is built for
+type ElementType string
+
+const (
+	VertexIndex ElementType = "Vertex"
+	EdgeIndex   ElementType = "Edge"
+)
+
+// Mapping is how a mixed index stores a string property
+type Mapping string
+
+const (
+	DefaultMapping Mapping = ""
+	Text           Mapping = "TEXT"
+	StringMapping  Mapping = "STRING"
+	TextString     Mapping = "TEXTSTRING"
+)
+
+type PropertyKey struct {
+	Name     string
+	DataType DataType
+	// Cardinality defaults to Single
+	Cardinality Cardinality
+}
+
+type VertexLabel struct {
+	Name string
+}
+
+type EdgeLabel struct {
+	Name string
+	// Multiplicity defaults to Multi
+	Multiplicity Multiplicity
+}
+
+// Index is a graph index. Composite indexes are used for exact matches and can enforce uniqueness,
+// mixed indexes are kept in an indexing backend and support the Text and Geo predicates.
+type Index struct {
+	Name    string
+	Element ElementType
+	Keys    []string
+	// Backend is the indexing backend of a mixed index, such as "search". Indexes without a backend are composite.
+	Backend string
+	Unique  bool
+	// IndexOnly restricts the index to the elements with this label
+	IndexOnly string
+	// Mappings holds the mapping of the string keys of a mixed index
+	Mappings map[string]Mapping
+	// Status of the index, only set for indexes read from the server
+	Status string
+}
+
+// Composite reports whether the index is a composite index
+func (i Index) Composite() bool {
+	return i.Backend == ""
+}
+
+// Schema is a set of schema elements, either the desired schema or the one read from the server
+type Schema struct {
+	PropertyKeys []PropertyKey
+	VertexLabels []VertexLabel
+	EdgeLabels   []EdgeLabel
+	Indexes      []Index
+}
+
+// Manager reads and changes the schema of a graph through the management system
+type Manager struct {
+	client *gremlin.Client
+	graph  string
+}
+
+// NewManager returns a manager for the graph bound to the given variable on the server, usually "graph"
+func NewManager(client *gremlin.Client, graph string) (*Manager, error) {
+	if err := gremlin.ValidateBindingName(graph); err != nil {
+		return nil, err
+	}
+	return &Manager{client: client, graph: graph}, nil
+}
+
+// inspectScript reads the schema into maps and lists, rolling back the management transaction it opened
+const inspectScript = `mgmt = %[1]s.openManagement()
+try {
+  keys = mgmt.getRelationTypes(PropertyKey.class).collect { [name: it.name(), dataType: it.dataType().simpleName, cardinality: it.cardinality().name()] }
+  vertexLabels = mgmt.getVertexLabels().collect { [name: it.name()] }
+  edgeLabels = mgmt.getRelationTypes(EdgeLabel.class).collect { [name: it.name(), multiplicity: it.multiplicity().name()] }
+  indexes = [Vertex.class, Edge.class].collectMany { element -> mgmt.getGraphIndexes(element).collect { index -> [
+    name: index.name(), element: element.simpleName, keys: index.getFieldKeys().collect { it.name() },
+    backend: index.isMixedIndex() ? index.getBackingIndex() : '', unique: index.isUnique(),
+    indexOnly: mgmt.getIndexOnlyConstraint(index.name())?.name() ?: '',
+    status: index.getFieldKeys().collect { index.getIndexStatus(it).name() }.unique().join(',')] } }
+  [propertyKeys: keys, vertexLabels: vertexLabels, edgeLabels: edgeLabels, indexes: indexes]
+} finally {
+  mgmt.rollback()
+}`
+
+var UnexpectedSchemaError = errors.New("the server returned the schema in an unexpected form")
+
+// Inspect reads the current schema of the graph
+func (m *Manager) Inspect(ctx context.Context) (*Schema, error) {
+	res, err := m.client.Do(ctx, gremlin.Query(fmt.Sprintf(inspectScript, m.graph)))
+	if err != nil {
+		return nil, err
+	}
+	values, err := res.Values()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, UnexpectedSchemaError
+	}
+	fields, ok := values[0].(map[string]interface{})
+	if !ok {
+		return nil, UnexpectedSchemaError
+	}
+
+	s := &Schema{}
+	for _, key := range records(fields["propertyKeys"]) {
+		s.PropertyKeys = append(s.PropertyKeys, PropertyKey{
+			Name:        str(key["name"]),
+			DataType:    DataType(str(key["dataType"])),
+			Cardinality: Cardinality(str(key["cardinality"])),
+		})
+	}
+	for _, label := range records(fields["vertexLabels"]) {
+		s.VertexLabels = append(s.VertexLabels, VertexLabel{Name: str(label["name"])})
+	}
+	for _, label := range records(fields["edgeLabels"]) {
+		s.EdgeLabels = append(s.EdgeLabels, EdgeLabel{Name: str(label["name"]), Multiplicity: Multiplicity(str(label["multiplicity"]))})
+	}
+	for _, index := range records(fields["indexes"]) {
+		i := Index{
+			Name:      str(index["name"]),
+			Element:   ElementType(str(index["element"])),
+			Backend:   str(index["backend"]),
+			IndexOnly: str(index["indexOnly"]),
+			Status:    str(index["status"]),
+		}
+		i.Unique, _ = index["unique"].(bool)
+		keys, _ := index["keys"].([]interface{})
+		for _, key := range keys {
+			i.Keys = append(i.Keys, str(key))
+		}
+		s.Indexes = append(s.Indexes, i)
+	}
+	return s, nil
+}
+
+// records returns the maps of a decoded list
+func records(v interface{}) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	var maps []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,190 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-gremlin/gremlin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// currentSchema is the schema the fake server reports, in the form returned by the inspect script
+const currentSchema = `[{
+	"propertyKeys": [{"name": "name", "dataType": "String", "cardinality": "SINGLE"}, {"name": "age", "dataType": "Integer", "cardinality": "SINGLE"}],
+	"vertexLabels": [{"name": "person"}],
+	"edgeLabels": [{"name": "knows", "multiplicity": "MULTI"}],
+	"indexes": [{"name": "byName", "element": "Vertex", "keys": ["name"], "backend": "", "unique": true, "indexOnly": "", "status": "ENABLED"}]
+}]`
+
+// newServer answers inspect scripts with currentSchema and records the other scripts it receives
+func newServer(scripts *[]string) *httptest.Server {
+	var mu sync.Mutex
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// skip the mime type header
+			var req struct {
+				RequestId struct {
+					Value string `json:"@value"`
+				} `json:"requestId"`
+				Args struct {
+					Gremlin string `json:"gremlin"`
+				} `json:"args"`
+			}
+			json.Unmarshal(msg[int(msg[0])+1:], &req)
+			data := json.RawMessage(`[]`)
+			if strings.Contains(req.Args.Gremlin, "getRelationTypes") {
+				data = json.RawMessage(currentSchema)
+			} else {
+				mu.Lock()
+				*scripts = append(*scripts, req.Args.Gremlin)
+				mu.Unlock()
+			}
+			ws.WriteJSON(gremlin.Response{
+				RequestId: req.RequestId.Value,
+				Status:    &gremlin.ResponseStatus{Code: gremlin.StatusSuccess},
+				Result:    &gremlin.ResponseResult{Data: data},
+			})
+		}
+	}))
+}
+
+func newManager(t *testing.T, server *httptest.Server) *Manager {
+	client, err := gremlin.NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if !assert.Empty(t, err) {
+		t.FailNow()
+	}
+	manager, err := NewManager(client, "graph")
+	assert.Empty(t, err)
+	return manager
+}
+
+var desired = &Schema{
+	PropertyKeys: []PropertyKey{{Name: "name", DataType: String}, {Name: "bio", DataType: String}, {Name: "tags", DataType: String, Cardinality: Set}},
+	VertexLabels: []VertexLabel{{Name: "person"}, {Name: "company"}},
+	EdgeLabels:   []EdgeLabel{{Name: "knows"}, {Name: "worksFor", Multiplicity: Many2One}},
+	Indexes: []Index{
+		{Name: "byName", Keys: []string{"name"}, Unique: true},
+		{Name: "search", Keys: []string{"bio", "name"}, Backend: "search", Mappings: map[string]Mapping{"bio": Text}, IndexOnly: "person"},
+	},
+}
+
+func TestInspect(t *testing.T) {
+	var scripts []string
+	server := newServer(&scripts)
+	defer server.Close()
+	manager := newManager(t, server)
+
+	current, err := manager.Inspect(context.Background())
+	assert.Empty(t, err)
+	assert.Equal(t, &Schema{
+		PropertyKeys: []PropertyKey{{Name: "name", DataType: String, Cardinality: Single}, {Name: "age", DataType: Integer, Cardinality: Single}},
+		VertexLabels: []VertexLabel{{Name: "person"}},
+		EdgeLabels:   []EdgeLabel{{Name: "knows", Multiplicity: Multi}},
+		Indexes:      []Index{{Name: "byName", Element: VertexIndex, Keys: []string{"name"}, Unique: true, Status: "ENABLED"}},
+	}, current)
+}
+
+func TestDiff(t *testing.T) {
+	current := &Schema{
+		PropertyKeys: []PropertyKey{{Name: "name", DataType: String, Cardinality: Single}},
+		VertexLabels: []VertexLabel{{Name: "person"}},
+		EdgeLabels:   []EdgeLabel{{Name: "knows", Multiplicity: Multi}},
+		Indexes:      []Index{{Name: "byName", Element: VertexIndex, Keys: []string{"name"}, Unique: true, Status: "ENABLED"}},
+	}
+	plan, err := Diff(current, desired)
+	assert.Empty(t, err)
+	assert.Equal(t, `+ property key bio (String, SINGLE)
++ property key tags (String, SET)
++ vertex label company
++ edge label worksFor (MANY2ONE)
++ mixed index search on Vertex(bio, name) in search only person
+warning: index search covers the existing property key name, reindex it to include existing data
+`, plan.String())
+
+	plan, err = Diff(desired, desired)
+	assert.Empty(t, err)
+	assert.True(t, plan.Empty())
+	assert.Equal(t, "schema is up to date\n", plan.String())
+
+	current.PropertyKeys[0].DataType = Integer
+	current.Indexes[0].Unique = false
+	plan, err = Diff(current, desired)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"property key name is a Integer, not a String", "index byName has unique set to false"}, plan.Conflicts)
+
+	_, err = Diff(&Schema{}, &Schema{Indexes: []Index{{Name: "byAge", Keys: []string{"age"}}}})
+	assert.IsType(t, &InvalidSchemaError{}, err)
+	_, err = Diff(&Schema{}, &Schema{PropertyKeys: []PropertyKey{{Name: "x", DataType: "Object"}}})
+	assert.IsType(t, &InvalidSchemaError{}, err)
+}
+
+func TestPlanRequest(t *testing.T) {
+	plan, err := Diff(&Schema{}, desired)
+	if !assert.Empty(t, err) {
+		return
+	}
+	req, err := plan.Request("graph")
+	assert.Empty(t, err)
+	assert.Equal(t, `graph.openManagement().with { mgmt ->
+  try {
+    mgmt.makePropertyKey(_p0).dataType(String.class).cardinality(org.janusgraph.core.Cardinality.SINGLE).make()
+    mgmt.makePropertyKey(_p1).dataType(String.class).cardinality(org.janusgraph.core.Cardinality.SINGLE).make()
+    mgmt.makePropertyKey(_p2).dataType(String.class).cardinality(org.janusgraph.core.Cardinality.SET).make()
+    mgmt.makeVertexLabel(_p3).make()
+    mgmt.makeVertexLabel(_p4).make()
+    mgmt.makeEdgeLabel(_p5).multiplicity(org.janusgraph.core.Multiplicity.MULTI).make()
+    mgmt.makeEdgeLabel(_p6).multiplicity(org.janusgraph.core.Multiplicity.MANY2ONE).make()
+    mgmt.buildIndex(_p7, Vertex.class).addKey(mgmt.getPropertyKey(_p8)).unique().buildCompositeIndex()
+    mgmt.buildIndex(_p9, Vertex.class).addKey(mgmt.getPropertyKey(_p10), org.janusgraph.core.schema.Mapping.TEXT.asParameter()).addKey(mgmt.getPropertyKey(_p11)).indexOnly(mgmt.getVertexLabel(_p12)).buildMixedIndex(_p13)
+    mgmt.commit()
+  } catch (e) {
+    mgmt.rollback()
+    throw e
+  }
+}`, req.Args.Gremlin)
+	assert.Equal(t, "bio", req.Args.Bindings["_p10"])
+	assert.Equal(t, "search", req.Args.Bindings["_p13"])
+
+	_, err = plan.Request("graph; g.V().drop()")
+	assert.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	var scripts []string
+	server := newServer(&scripts)
+	defer server.Close()
+	manager := newManager(t, server)
+	ctx := context.Background()
+
+	plan, err := manager.Migrate(ctx, desired, true)
+	assert.Empty(t, err)
+	assert.Len(t, plan.Changes, 5)
+	assert.Empty(t, scripts)
+
+	_, err = manager.Migrate(ctx, desired, false)
+	assert.Empty(t, err)
+	if assert.Len(t, scripts, 1) {
+		assert.Contains(t, scripts[0], "mgmt.commit()")
+	}
+
+	conflicting := &Schema{PropertyKeys: []PropertyKey{{Name: "age", DataType: Long}}}
+	_, err = manager.Migrate(ctx, conflicting, false)
+	assert.Equal(t, ConflictError, err)
+	assert.Len(t, scripts, 1)
+}
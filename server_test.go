@@ -0,0 +1,149 @@
+package gremlin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRequest is a request as received by the test server
+type testRequest struct {
+	RequestId struct {
+		Value string `json:"@value"`
+	} `json:"requestId"`
+	Op        string                 `json:"op"`
+	Processor string                 `json:"processor"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+// testServer is a minimal stand-in for Gremlin Server, answering each request with the responses
+// returned by its respond function
+type testServer struct {
+	*httptest.Server
+	Url      string
+	Upgrader websocket.Upgrader
+
+	mu          sync.Mutex
+	requests    []*testRequest
+	closeFrames int
+	pings       int
+	respond     func(req *testRequest) []*Response
+	// gate, when set, holds back every response but the first of a request until it receives
+	gate chan struct{}
+}
+
+func newTestServer(respond func(req *testRequest) []*Response) *testServer {
+	s := &testServer{respond: respond}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.Url = "ws" + strings.TrimPrefix(s.Server.URL, "http")
+	return s
+}
+
+// successResponse answers with a single batch holding the given JSON data
+func successResponse(data string) func(req *testRequest) []*Response {
+	return func(req *testRequest) []*Response {
+		return []*Response{testResponse(req, StatusSuccess, data)}
+	}
+}
+
+func testResponse(req *testRequest, code int, data string) *Response {
+	res := &Response{
+		RequestId: req.RequestId.Value,
+		Status:    &ResponseStatus{Code: code},
+		Result:    &ResponseResult{Meta: map[string]interface{}{}},
+	}
+	if data != "" {
+		res.Result.Data = json.RawMessage(data)
+	}
+	return res
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	ws.SetPingHandler(func(data string) error {
+		s.mu.Lock()
+		s.pings++
+		s.mu.Unlock()
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				s.mu.Lock()
+				s.closeFrames++
+				s.mu.Unlock()
+			}
+			return
+		}
+		// answer in the format of the mime type header
+		graphBinary := string(msg[1:int(msg[0])+1]) == graphBinaryMimeType
+		req := &testRequest{}
+		if graphBinary {
+			req, err = readTestGraphBinaryRequest(msg[int(msg[0])+1:])
+		} else {
+			err = json.Unmarshal(msg[int(msg[0])+1:], req)
+		}
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		for i, res := range s.respond(req) {
+			if i > 0 && s.gate != nil {
+				<-s.gate
+			}
+			messageType, b := websocket.TextMessage, []byte(nil)
+			if graphBinary {
+				messageType, b = websocket.BinaryMessage, testGraphBinaryResponse(res)
+			} else {
+				b, _ = json.Marshal(res)
+			}
+			if err := ws.WriteMessage(messageType, b); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *testServer) received() []*testRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*testRequest(nil), s.requests...)
+}
+
+func (s *testServer) closeFramesReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFrames
+}
+
+func (s *testServer) pingsReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pings
+}
+
+// emptyPool discards the idle connections of the client, so the next socket the pool dials goes to the next server
+func emptyPool(t *testing.T, cl *Client) {
+	p := cl.transport.(*wsTransport).pool
+	for p.Len() > 0 {
+		con, err := p.Get()
+		if !assert.Empty(t, err) {
+			return
+		}
+		con.MarkUnusable()
+		con.Close()
+	}
+}
@@ -0,0 +1,110 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var SessionClosedError = errors.New("the session has been closed")
+
+// Session sends requests to the session processor of the server, which keeps the variables and the open
+// transaction of a session between requests. Sessions live on a single server, so every request of a session,
+// and its close, goes to the server its first request went to. Sessions that are still open when the client
+// is shut down are closed then.
+type Session struct {
+	client  *Client
+	id      string
+	aliases map[string]string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSession starts a session with a new id. The session is created on the server by its first request.
+func (c *Client) NewSession() *Session {
+	return &Session{client: c, id: newRequestId()}
+}
+
+// Id returns the id the session is known by on the server
+func (s *Session) Id() string {
+	return s.id
+}
+
+// Aliases sets the aliases sent with every request of the session, such as {"g": "tenant_traversal"}
+func (s *Session) Aliases(aliases map[string]string) *Session {
+	s.aliases = aliases
+	return s
+}
+
+// Do executes the request in the session. The request is left untouched, a copy is sent with the session's id.
+func (s *Session) Do(ctx context.Context, req *Request) (*Result, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, SessionClosedError
+	}
+	r := *req
+	r.Processor = "session"
+	r.Args = &RequestArgs{}
+	if req.Args != nil {
+		*r.Args = *req.Args
+	}
+	r.Args.Session = s.id
+	if r.Args.Aliases == nil {
+		r.Args.Aliases = s.aliases
+	}
+	return s.client.Do(ctx, &r)
+}
+
+// Exec evaluates a script in the session
+func (s *Session) Exec(ctx context.Context, script string, bindings Bind) (*Result, error) {
+	req := Query(script)
+	if bindings != nil {
+		req.Bindings(bindings)
+	}
+	return s.Do(ctx, req)
+}
+
+// Commit commits the session's open transaction
+func (s *Session) Commit(ctx context.Context) error {
+	_, err := s.Exec(ctx, "g.tx().commit()", nil)
+	return err
+}
+
+// Rollback rolls back the session's open transaction
+func (s *Session) Rollback(ctx context.Context) error {
+	_, err := s.Exec(ctx, "g.tx().rollback()", nil)
+	return err
+}
+
+// Transaction runs fn and commits the session's transaction if fn succeeds, or rolls it back if fn fails.
+// The error of fn is returned in preference to the error of the rollback.
+func (s *Session) Transaction(ctx context.Context, fn func(s *Session) error) error {
+	if err := fn(s); err != nil {
+		s.Rollback(ctx)
+		return err
+	}
+	return s.Commit(ctx)
+}
+
+// Close closes the session on the server, rolling back its open transaction. Closing a session twice does nothing.
+func (s *Session) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	_, err := s.client.Do(ctx, &Request{
+		RequestId: newRequestId(),
+		Op:        "close",
+		Processor: "session",
+		Args:      &RequestArgs{Session: s.id},
+	})
+	s.client.sessions.Delete(s.id)
+	return err
+}
@@ -0,0 +1,96 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	server := newTestServer(successResponse("[]"))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	ctx := context.Background()
+
+	session := cl.NewSession().Aliases(map[string]string{"g": "tenant.g"})
+	req := Query("x = 1")
+	_, err = session.Do(ctx, req)
+	assert.Empty(t, err)
+	assert.Equal(t, "", req.Args.Session)
+
+	assert.Empty(t, session.Transaction(ctx, func(s *Session) error {
+		_, err := s.Exec(ctx, "g.addV()", nil)
+		return err
+	}))
+	failure := errors.New("failed")
+	assert.Equal(t, failure, session.Transaction(ctx, func(s *Session) error {
+		return failure
+	}))
+
+	assert.Empty(t, session.Close(ctx))
+	assert.Empty(t, session.Close(ctx))
+	_, err = session.Exec(ctx, "x", nil)
+	assert.Equal(t, SessionClosedError, err)
+
+	received := server.received()
+	if assert.Len(t, received, 5) {
+		for _, r := range received {
+			assert.Equal(t, "session", r.Processor)
+			assert.Equal(t, session.Id(), r.Args["session"])
+		}
+		assert.Equal(t, map[string]interface{}{"g": "tenant.g"}, received[0].Args["aliases"])
+		assert.Equal(t, "g.tx().commit()", received[2].Args["gremlin"])
+		assert.Equal(t, "g.tx().rollback()", received[3].Args["gremlin"])
+		assert.Equal(t, "close", received[4].Op)
+	}
+}
+
+func TestSessionStaysOnItsEndpoint(t *testing.T) {
+	first := newTestServer(successResponse("[1]"))
+	defer first.Close()
+	second := newTestServer(successResponse("[1]"))
+	defer second.Close()
+	cl, err := NewClient(first.Url + "," + second.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	ctx := context.Background()
+
+	session := cl.NewSession()
+	for i := 0; i < 4; i++ {
+		_, err := session.Exec(ctx, "x", nil)
+		assert.Empty(t, err)
+		emptyPool(t, cl)
+		_, err = cl.ExecQuery("1")
+		assert.Empty(t, err)
+	}
+	assert.Empty(t, session.Close(ctx))
+
+	var sessionOn, otherOn []*testRequest
+	for _, r := range first.received() {
+		if r.Args["session"] != nil {
+			sessionOn = append(sessionOn, r)
+		}
+	}
+	for _, r := range second.received() {
+		if r.Args["session"] != nil {
+			otherOn = append(otherOn, r)
+		}
+	}
+	if len(sessionOn) == 0 {
+		sessionOn, otherOn = otherOn, sessionOn
+	}
+	assert.Empty(t, otherOn)
+	if assert.Len(t, sessionOn, 5) {
+		assert.Equal(t, "close", sessionOn[4].Op)
+	}
+	// both servers were used by the requests outside the session
+	assert.NotEmpty(t, first.received())
+	assert.NotEmpty(t, second.received())
+}
@@ -0,0 +1,70 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrClientClosed = errors.New("gremlin client is closed")
+)
+
+// how long to wait for a close frame or session close request to be written
+const closeWriteTimeout = time.Second
+
+// begin admits a request, unless the client has been shut down
+func (c *Client) begin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClientClosed
+	}
+	c.active.Add(1)
+	return nil
+}
+
+// Shutdown gracefully closes the client. New requests are rejected with ErrClientClosed straight away,
+// requests in flight are allowed to finish until ctx is done, after which they are cancelled and return
+// ErrClientClosed. Once no requests are left, open sessions are closed on the server and the transport
+// is closed, for websockets this means every pooled connection is closed with a close frame.
+//
+// Shutdown returns the context's error if requests had to be cancelled. Once the client has been closed,
+// calling Shutdown again does nothing.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.active.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.closeOnce.Do(func() {
+		if err != nil {
+			close(c.shutdown)
+		}
+		<-drained
+		c.closeSessions()
+		c.transport.Close()
+	})
+	return err
+}
+
+// closeSessions asks the server to close every session used by the client
+func (c *Client) closeSessions() {
+	c.sessions.Range(func(key, _ interface{}) bool {
+		c.transport.CloseSession(key.(string))
+		c.sessions.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,165 @@
+package gremlin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func slowResponse(delay time.Duration) func(req *testRequest) []*Response {
+	return func(req *testRequest) []*Response {
+		if req.Op == "eval" {
+			time.Sleep(delay)
+		}
+		return []*Response{testResponse(req, StatusSuccess, "[1]")}
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	server := newTestServer(slowResponse(100 * time.Millisecond))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := cl.Exec(Query("1").Session("7e8a8c6a-5e4a-4d7b-a8a4-6c1b7b6f0c11"))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Empty(t, cl.Shutdown(ctx))
+	assert.Empty(t, <-done)
+
+	// new requests are rejected, and closing again is harmless
+	_, err = cl.ExecQuery("1")
+	assert.Equal(t, ErrClientClosed, err)
+	assert.Empty(t, cl.Shutdown(ctx))
+	cl.Close()
+
+	// the session was closed and the connection was closed with a close frame
+	requests := server.received()
+	last := requests[len(requests)-1]
+	assert.Equal(t, "close", last.Op)
+	assert.Equal(t, "session", last.Processor)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, server.closeFramesReceived())
+}
+
+func TestShutdownCancelsAtDeadline(t *testing.T) {
+	server := newTestServer(slowResponse(time.Second))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := cl.ExecQuery("1")
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, cl.Shutdown(ctx))
+	assert.Equal(t, ErrClientClosed, <-done)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+}
+
+func TestShutdownWhileDialing(t *testing.T) {
+	// the server accepts the first socket, and never answers the handshake of the next
+	server := &testServer{respond: slowResponse(time.Second)}
+	block := make(chan struct{})
+	var sockets int32
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&sockets, 1) > 1 {
+			<-block
+			return
+		}
+		server.serve(w, r)
+	}))
+	server.Url = "ws" + strings.TrimPrefix(server.Server.URL, "http")
+	defer server.Close()
+	defer close(block)
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := cl.ExecQuery("1")
+			done <- err
+		}()
+	}
+	for atomic.LoadInt32(&sockets) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, cl.Shutdown(ctx))
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.Equal(t, ErrClientClosed, <-done)
+	assert.Equal(t, ErrClientClosed, <-done)
+}
+
+func TestShutdownClosesSessionOnItsEndpoint(t *testing.T) {
+	first := newTestServer(successResponse("[1]"))
+	defer first.Close()
+	second := newTestServer(successResponse("[1]"))
+	defer second.Close()
+	cl, err := NewClient(first.Url + "," + second.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+
+	// the session starts on the first server, then the pool only holds a socket to the second
+	_, err = cl.Exec(Query("1").Session("7e8a8c6a-5e4a-4d7b-a8a4-6c1b7b6f0c11"))
+	assert.Empty(t, err)
+	con, err := cl.transport.(*wsTransport).pool.Get()
+	assert.Empty(t, err)
+	con.MarkUnusable()
+	con.Close()
+	_, err = cl.ExecQuery("1")
+	assert.Empty(t, err)
+
+	assert.Empty(t, cl.Shutdown(context.Background()))
+	received := first.received()
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, "close", received[1].Op)
+		assert.Equal(t, "7e8a8c6a-5e4a-4d7b-a8a4-6c1b7b6f0c11", received[1].Args["session"])
+	}
+	assert.Len(t, second.received(), 1)
+}
+
+func TestExecContextCancel(t *testing.T) {
+	server := newTestServer(slowResponse(time.Second))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = cl.ExecContext(ctx, Query("1"))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// cancelling a request doesn't put the endpoint on ice
+	assert.False(t, cl.Endpoints()[0].OnIce)
+}
@@ -0,0 +1,85 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var NoSideEffectsError = errors.New("the result was not returned by a client, its side effects can't be retrieved")
+
+// SideEffects retrieves the side effects of a traversal that was sent to the traversal processor.
+// The server keeps them around for a while after the traversal completed, Close releases them straight away.
+// The side effects are kept by the server that ran the traversal, so every op goes to that server.
+type SideEffects struct {
+	client    *Client
+	requestId string
+	aliases   map[string]string
+	endpoint  string
+}
+
+// SideEffects returns the side effects of the traversal that produced the result
+func (r *Result) SideEffects() *SideEffects {
+	return &SideEffects{client: r.client, requestId: r.RequestId, aliases: r.aliases, endpoint: r.Endpoint}
+}
+
+// Keys returns the keys of the side effects, using the traversal processor's keys op
+func (s *SideEffects) Keys(ctx context.Context) ([]string, error) {
+	res, err := s.do(ctx, "keys", &RequestArgs{})
+	if err != nil || len(res.Data) == 0 {
+		return nil, err
+	}
+	var items []interface{}
+	if err := json.Unmarshal(res.Data, &items); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if key, ok := graphSONValue(item).(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Get returns the side effect stored under key, using the traversal processor's gather op.
+// Result.AggregateTo tells what kind of collection the data was aggregated into.
+func (s *SideEffects) Get(ctx context.Context, key string) (*Result, error) {
+	return s.do(ctx, "gather", &RequestArgs{SideEffectKey: key, Aliases: s.aliases})
+}
+
+// Close releases the side effects on the server
+func (s *SideEffects) Close(ctx context.Context) error {
+	_, err := s.do(ctx, "close", &RequestArgs{})
+	return err
+}
+
+func (s *SideEffects) do(ctx context.Context, op string, args *RequestArgs) (*Result, error) {
+	if s.client == nil {
+		return nil, NoSideEffectsError
+	}
+	args.SideEffect = graphSONUUID(s.requestId)
+	req := &Request{
+		RequestId: newRequestId(),
+		Op:        op,
+		Processor: "traversal",
+		Args:      args,
+	}
+	if s.endpoint != "" {
+		ctx = withEndpoint(ctx, s.endpoint)
+	}
+	return s.client.Do(ctx, req)
+}
+
+type endpointKey struct{}
+
+// withEndpoint returns a context whose request is sent to the endpoint with the url instead of any endpoint.
+// The http transport sends requests to any endpoint regardless.
+func withEndpoint(ctx context.Context, urlStr string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, urlStr)
+}
+
+func endpointFor(ctx context.Context) string {
+	urlStr, _ := ctx.Value(endpointKey{}).(string)
+	return urlStr
+}
@@ -0,0 +1,145 @@
+package gremlin
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the request latency histogram
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats is a point in time snapshot of a client's counters. All counters are totals since the client was created.
+type Stats struct {
+	// Requests counts the responses by final status code. Requests that failed without
+	// a response from the server, for example because the socket broke, are counted under 0.
+	Requests map[int]uint64
+	Latency  LatencyHistogram
+	// BytesOut and BytesIn count the websocket message payloads written and read
+	BytesOut uint64
+	BytesIn  uint64
+	// WireBytesOut and WireBytesIn count the bytes written to and read from the sockets, including
+	// websocket framing and after compression. Compare them to BytesOut and BytesIn to see the compression ratio.
+	WireBytesOut uint64
+	WireBytesIn  uint64
+	// PoolGets counts connections taken from the pool, PoolWaits those that had to wait for
+	// a connection to be released and PoolTimeouts those that gave up waiting.
+	// PoolWaitTime is the total time spent waiting and PoolWaiting the number of requests waiting right now.
+	PoolGets     uint64
+	PoolWaits    uint64
+	PoolTimeouts uint64
+	PoolWaitTime time.Duration
+	PoolWaiting  int
+	// Reconnects counts the sockets dialed after the client was created
+	Reconnects     uint64
+	AuthChallenges uint64
+	EndpointsOnIce int
+}
+
+// LatencyHistogram holds request latencies bucketed by DefaultLatencyBuckets.
+// Counts has one more entry than Bounds, the last one holding the latencies above the largest bound.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// clientStats collects the counters behind Stats
+type clientStats struct {
+	bytesOut       uint64
+	bytesIn        uint64
+	wireBytesOut   uint64
+	wireBytesIn    uint64
+	poolGets       uint64
+	poolWaits      uint64
+	poolTimeouts   uint64
+	poolWaitTime   uint64 // nanoseconds
+	reconnects     uint64
+	authChallenges uint64
+
+	mu       sync.Mutex
+	requests map[int]uint64
+	latency  LatencyHistogram
+}
+
+func newClientStats() *clientStats {
+	return &clientStats{
+		requests: map[int]uint64{},
+		latency: LatencyHistogram{
+			Bounds: DefaultLatencyBuckets,
+			Counts: make([]uint64, len(DefaultLatencyBuckets)+1),
+		},
+	}
+}
+
+func (s *clientStats) add(counter *uint64, n int) {
+	atomic.AddUint64(counter, uint64(n))
+}
+
+func (s *clientStats) poolWait(d time.Duration) {
+	atomic.AddUint64(&s.poolWaits, 1)
+	atomic.AddUint64(&s.poolWaitTime, uint64(d))
+}
+
+func (s *clientStats) request(code int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[code]++
+	i := 0
+	for i < len(s.latency.Bounds) && latency > s.latency.Bounds[i] {
+		i++
+	}
+	s.latency.Counts[i]++
+	s.latency.Count++
+	s.latency.Sum += latency
+}
+
+func (s *clientStats) snapshot() Stats {
+	st := Stats{
+		BytesOut:       atomic.LoadUint64(&s.bytesOut),
+		BytesIn:        atomic.LoadUint64(&s.bytesIn),
+		WireBytesOut:   atomic.LoadUint64(&s.wireBytesOut),
+		WireBytesIn:    atomic.LoadUint64(&s.wireBytesIn),
+		PoolGets:       atomic.LoadUint64(&s.poolGets),
+		PoolWaits:      atomic.LoadUint64(&s.poolWaits),
+		PoolTimeouts:   atomic.LoadUint64(&s.poolTimeouts),
+		PoolWaitTime:   time.Duration(atomic.LoadUint64(&s.poolWaitTime)),
+		Reconnects:     atomic.LoadUint64(&s.reconnects),
+		AuthChallenges: atomic.LoadUint64(&s.authChallenges),
+		Requests:       map[int]uint64{},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for code, n := range s.requests {
+		st.Requests[code] = n
+	}
+	st.Latency = s.latency
+	st.Latency.Counts = append([]uint64(nil), s.latency.Counts...)
+	return st
+}
+
+// Stats returns a snapshot of the client's request, traffic and pool counters
+func (c *Client) Stats() Stats {
+	st := c.stats.snapshot()
+	st.PoolWaiting = c.limiter.waiting()
+	for _, status := range c.Endpoints() {
+		if status.OnIce {
+			st.EndpointsOnIce++
+		}
+	}
+	return st
+}
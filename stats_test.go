@@ -0,0 +1,33 @@
+package gremlin
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsSnapshot(t *testing.T) {
+	s := newClientStats()
+	s.request(StatusSuccess, 3*time.Millisecond)
+	s.request(StatusSuccess, time.Minute)
+	s.request(StatusScriptEvaluationError, time.Millisecond)
+	s.add(&s.bytesIn, 100)
+	s.add(&s.poolGets, 3)
+
+	st := s.snapshot()
+	assert.Equal(t, uint64(2), st.Requests[StatusSuccess])
+	assert.Equal(t, uint64(1), st.Requests[StatusScriptEvaluationError])
+	assert.Equal(t, uint64(100), st.BytesIn)
+	assert.Equal(t, uint64(3), st.PoolGets)
+
+	assert.Equal(t, uint64(3), st.Latency.Count)
+	assert.Equal(t, time.Minute+4*time.Millisecond, st.Latency.Sum)
+	assert.Equal(t, uint64(1), st.Latency.Counts[0])
+	assert.Equal(t, uint64(1), st.Latency.Counts[1])
+	assert.Equal(t, uint64(1), st.Latency.Counts[len(st.Latency.Bounds)])
+
+	// snapshots don't share state with the collector
+	s.request(StatusSuccess, time.Millisecond)
+	assert.Equal(t, uint64(2), st.Requests[StatusSuccess])
+	assert.Equal(t, uint64(1), st.Latency.Counts[0])
+}
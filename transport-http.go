@@ -0,0 +1,209 @@
+package gremlin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	HTTPUnsupportedRequestError = errors.New("the http transport only supports eval requests without a session")
+)
+
+// httpTransport sends requests to the http endpoint of Gremlin Server, served by its
+// HttpChannelizer or WsAndHttpChannelizer
+type httpTransport struct {
+	factory  *EndpointFactory
+	client   *http.Client
+	stats    *clientStats
+	auth     []OptAuth
+	shutdown <-chan struct{}
+}
+
+// httpRequest is the JSON body of an http request
+type httpRequest struct {
+	Gremlin  string            `json:"gremlin"`
+	Bindings Bind              `json:"bindings,omitempty"`
+	Language string            `json:"language,omitempty"`
+	Aliases  map[string]string `json:"aliases,omitempty"`
+}
+
+// httpError is the JSON body of an error returned by the http endpoint
+type httpError struct {
+	Message string `json:"message"`
+}
+
+func newHTTPTransport(c *Client) *httpTransport {
+	return &httpTransport{
+		factory: c.factory,
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:               c.factory.dialer.Proxy,
+			DialContext:         c.factory.netDialContext(),
+			MaxIdleConnsPerHost: c.poolOpts.maxIdle(),
+		}},
+		stats:    c.stats,
+		auth:     c.Auth,
+		shutdown: c.shutdown,
+	}
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, req *Request, info *RequestInfo) ([]byte, error) {
+	if req.Op != "eval" || req.Args.Session != "" {
+		return nil, HTTPUnsupportedRequestError
+	}
+	endpoint, err := t.factory.findValidEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	urlStr, err := urlStrForEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	info.Endpoint = urlStr
+
+	bindings, err := graphSONBindings(req.Args.Bindings, t.factory.serializer)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(httpRequest{
+		Gremlin:  req.Args.Gremlin,
+		Bindings: bindings,
+		Language: req.Args.Language,
+		Aliases:  req.Args.Aliases,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, urlStr, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", t.factory.mimeType())
+	if len(t.auth) > 0 {
+		auth, err := NewAuthInfo(t.auth...)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.SetBasicAuth(auth.User, auth.Pass)
+	}
+	// carry the trace of the request to the server, the global propagator does nothing unless one is set
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	ctx, cancel := withShutdown(ctx, t.shutdown)
+	defer cancel()
+	info.Attempts++
+	t.stats.add(&t.stats.bytesOut, len(body))
+	resp, err := t.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, t.transportError(ctx, endpoint, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, t.transportError(ctx, endpoint, err)
+	}
+	t.stats.add(&t.stats.bytesIn, len(b))
+
+	data, err := t.decodeResponse(resp.StatusCode, b, info)
+	if penalizesEndpoint(err) {
+		t.factory.endpointFailed(endpoint, err)
+	} else {
+		t.factory.endpointSucceeded(endpoint)
+	}
+	return data, err
+}
+
+// decodeResponse reads the gremlin response out of the body of the http response
+func (t *httpTransport) decodeResponse(statusCode int, b []byte, info *RequestInfo) ([]byte, error) {
+	if t.factory.graphBinary {
+		// errors are still sent as text, so a body that isn't GraphBinary is left as it is
+		if converted, err := graphBinaryResponse(b); err == nil {
+			b = converted
+		}
+	}
+	var res *Response
+	if json.Unmarshal(b, &res) != nil || res == nil || res.Status == nil {
+		// errors are reported with an http status and a message rather than a gremlin response
+		info.StatusCode = statusForHTTP(statusCode)
+		if info.StatusCode == StatusSuccess || info.StatusCode == StatusNoContent {
+			return nil, &ProtocolError{Endpoint: info.Endpoint, Err: MissingStatusError}
+		}
+		return nil, &ResponseError{Code: info.StatusCode, Message: string(bytes.TrimSpace(b))}
+	}
+	info.StatusCode = res.Status.Code
+	info.Warnings = append(info.Warnings, res.Status.Attributes.Warnings()...)
+	info.Batches = append(info.Batches, newBatch(res))
+	switch res.Status.Code {
+	case StatusNoContent:
+		return nil, nil
+	case StatusSuccess, StatusPartialContent:
+		info.BatchCount++
+		items, err := resultItems(res.Result.Data)
+		if err != nil {
+			return res.Result.Data, nil
+		}
+		info.ResultCount = len(items)
+		info.lastBatch().ResultCount = len(items)
+		if t.factory.serializer == GraphSONv3 {
+			// results are returned as a plain JSON array whatever the version
+			return json.Marshal(items)
+		}
+		return res.Result.Data, nil
+	default:
+		return nil, &ResponseError{Code: res.Status.Code, Message: res.Status.Message, Attributes: res.Status.Attributes}
+	}
+}
+
+// transportError puts the endpoint on ice, unless the request failed because it was cancelled
+func (t *httpTransport) transportError(ctx context.Context, endpoint *sync.Map, err error) error {
+	select {
+	case <-t.shutdown:
+		return ErrClientClosed
+	default:
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	urlStr, _ := urlStrForEndpoint(endpoint)
+	err = &TransportError{Endpoint: urlStr, Err: err}
+	t.factory.endpointUnreachable(endpoint, err)
+	return err
+}
+
+// statusForHTTP maps the http status of an error response to the matching gremlin status code
+func statusForHTTP(code int) int {
+	switch code {
+	case http.StatusOK:
+		return StatusSuccess
+	case http.StatusNoContent:
+		return StatusNoContent
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return StatusUnauthorized
+	case http.StatusBadRequest:
+		return StatusMalformedRequest
+	case http.StatusInternalServerError:
+		return StatusServerError
+	}
+	return 0
+}
+
+// CloseSession does nothing, sessions are not supported over http
+func (t *httpTransport) CloseSession(session string) {}
+
+// Idle returns 0, idle connections are managed by the http client
+func (t *httpTransport) Idle() int {
+	return 0
+}
+
+func (t *httpTransport) Close() {
+	if tr, ok := t.client.Transport.(*http.Transport); ok {
+		tr.CloseIdleConnections()
+	}
+}
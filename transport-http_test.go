@@ -0,0 +1,173 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newHTTPTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, graphSONMimeType, r.Header.Get("Accept"))
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Username and/or password are incorrect"}`))
+			return
+		}
+		var body httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.Gremlin != "n * n" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"No such property: x for class: Script1","Exception-Class":"groovy.lang.MissingPropertyException"}`))
+			return
+		}
+		// bindings are sent typed
+		n := graphSONValue(body.Bindings["n"]).(float64)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"requestId": "41d2e28a-20a4-4ab0-b379-d810dede3786",
+			"status":    map[string]interface{}{"code": 200, "message": "", "attributes": map[string]interface{}{}},
+			"result":    map[string]interface{}{"data": []interface{}{map[string]interface{}{"@type": "g:Int64", "@value": n * n}}, "meta": map[string]interface{}{}},
+		})
+	}))
+}
+
+func TestHTTPTransport(t *testing.T) {
+	server := newHTTPTestServer(t)
+	defer server.Close()
+
+	cl, err := NewClient(server.URL, OptAuthUserPass("user", "pass"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, ok := cl.transport.(*httpTransport)
+	assert.True(t, ok)
+
+	res, err := cl.Exec(Query("n * n").Bindings(Bind{"n": 3}))
+	assert.Empty(t, err)
+	var m []map[string]interface{}
+	assert.Empty(t, json.Unmarshal(res, &m))
+	assert.Equal(t, 9.0, m[0]["@value"])
+
+	// script errors are reported with an http status
+	_, err = cl.ExecQuery("x")
+	assert.True(t, errors.Is(err, ConnectionErrors[StatusServerError]))
+
+	// sessions need a websocket
+	_, err = cl.Exec(Query("n * n").Session("7e8a8c6a-5e4a-4d7b-a8a4-6c1b7b6f0c11"))
+	assert.Equal(t, HTTPUnsupportedRequestError, err)
+}
+
+func TestHTTPTransportUnauthorized(t *testing.T) {
+	server := newHTTPTestServer(t)
+	defer server.Close()
+
+	cl, err := NewClient(server.URL, OptAuthUserPass("user", "wrong"))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+	_, err = cl.Exec(Query("n * n").Bindings(Bind{"n": 3}))
+	assert.True(t, errors.Is(err, ConnectionErrors[StatusUnauthorized]))
+}
+
+func TestHTTPTransportTraceContext(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"requestId":"41d2e28a-20a4-4ab0-b379-d810dede3786","status":{"code":204,"attributes":{}},"result":{"data":null,"meta":{}}}`))
+	}))
+	defer server.Close()
+
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	cl, err := NewClient(server.URL)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	_, err = cl.ExecContext(ctx, Query("g.V().drop()"))
+	assert.Empty(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceparent)
+}
+
+func TestWebSocketTraceContext(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	for _, c := range []struct {
+		opt  OptClient
+		want interface{}
+	}{
+		{OptClientSerializer(GraphSONv3), map[string]interface{}{"traceparent": traceparent}},
+		// GraphBinary requests are read back as GraphSON 3
+		{OptClientGraphBinary(), map[string]interface{}{"@type": "g:Map", "@value": []interface{}{"traceparent", traceparent}}},
+	} {
+		server := newTestServer(successResponse(`[]`))
+		cl, err := NewClientWithOptions(server.Url, c.opt)
+		if !assert.Empty(t, err) {
+			server.Close()
+			return
+		}
+		req := Query("g.V().drop()")
+		_, err = cl.ExecContext(ctx, req)
+		assert.Empty(t, err)
+		_, err = cl.Exec(Query("g.V()"))
+		assert.Empty(t, err)
+		cl.Close()
+		server.Close()
+
+		// the trace goes in a request argument, the caller's request is left alone
+		received := server.received()
+		if assert.Len(t, received, 2) {
+			assert.Equal(t, c.want, received[0].Args["traceContext"])
+			assert.NotContains(t, received[1].Args, "traceContext")
+		}
+		assert.Empty(t, req.Args.TraceContext)
+	}
+}
+
+func TestSplitServersSchemes(t *testing.T) {
+	servers, err := SplitServers("ws://server1:8182/gremlin, wss://server2:8182/gremlin")
+	assert.Empty(t, err)
+	assert.Len(t, servers, 2)
+
+	_, err = SplitServers("http://server1:8182, https://server2:8182")
+	assert.Empty(t, err)
+
+	_, err = SplitServers("ws://server1:8182/gremlin, http://server2:8182")
+	assert.Equal(t, MixedSchemesError, err)
+
+	_, err = SplitServers("server1:8182")
+	assert.Equal(t, UnsupportedSchemeError, err)
+}
@@ -0,0 +1,495 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/jessicacglenn/pool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// wsTransport sends requests over pooled websocket connections
+type wsTransport struct {
+	pool		pool.Pool
+	factory		*EndpointFactory
+	stats		*clientStats
+	auth		[]OptAuth
+	shutdown	<-chan struct{}
+	stopMaintenance	chan struct{}
+	sessions	sync.Map // session id -> url of the endpoint its first request went to
+}
+
+func newWebSocketTransport(c *Client) (*wsTransport, error) {
+	t := &wsTransport{
+		factory: c.factory,
+		stats: c.stats,
+		auth: c.Auth,
+		shutdown: c.shutdown,
+		stopMaintenance: make(chan struct{}),
+	}
+	p, err := pool.NewChannelPool(1, c.poolOpts.maxIdle(), t.factory.connectSocket)
+	if err != nil {
+		return nil, err
+	}
+	t.pool = p
+	// the sockets opened to fill the pool are not reconnects
+	atomic.StoreUint64(&t.stats.reconnects, 0)
+	go t.factory.maintain(t.stopMaintenance)
+	return t, nil
+}
+
+// Idle returns the number of idle connections held in the pool
+func (t *wsTransport) Idle() int {
+	return t.pool.Len()
+}
+
+func (t *wsTransport) RoundTrip(ctx context.Context, req *Request, info *RequestInfo) ([]byte, error) {
+	// side effect ops go to the endpoint in ctx and the requests of a session to the endpoint it started on,
+	// as those endpoints hold their state
+	pinned := endpointFor(ctx)
+	if req.Args != nil && req.Args.Session != "" {
+		if urlStr, ok := t.sessions.Load(req.Args.Session); ok {
+			pinned = urlStr.(string)
+			if req.Op == "close" {
+				return nil, t.closeSession(ctx, req, pinned, info)
+			}
+		}
+	}
+	req = withTraceContext(ctx, req)
+	ws, release, err := t.connFor(ctx, pinned)
+	if err != nil {
+		return nil, err
+	}
+	b, err := t.executeForConn(ctx, req, ws, release, info)
+	if _, stale := err.(*writeError); stale {
+		// the request never reached the server, so it is safe to try once more on a fresh connection
+		if ws, release, err = t.connFor(ctx, pinned); err != nil {
+			return nil, err
+		}
+		b, err = t.executeForConn(ctx, req, ws, release, info)
+	}
+	if werr, ok := err.(*writeError); ok {
+		err = &TransportError{Endpoint: info.Endpoint, Err: werr.err}
+	}
+	return b, err
+}
+
+// connFor takes a socket to the endpoint, or to any endpoint if urlStr is empty. The returned function
+// releases the socket once the request is done with it.
+func (t *wsTransport) connFor(ctx context.Context, urlStr string) (*websocket.Conn, func(usable bool) error, error) {
+	if urlStr == "" {
+		con, err := t.getConn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return con.Conn, pooled(con), nil
+	}
+	if con := t.idleConn(urlStr); con != nil {
+		return con.Conn, pooled(con), nil
+	}
+	// the pool only takes back the sockets it dialed itself, so one dialed for the endpoint is closed after use
+	ws, err := t.factory.dialEndpoint(ctx, urlStr)
+	if err != nil {
+		return nil, nil, &TransportError{Endpoint: urlStr, Err: err}
+	}
+	return ws, func(bool) error {
+		t.factory.checkin(ws, false)
+		return ws.Close()
+	}, nil
+}
+
+// pooled releases a socket taken from the pool, returning it to the pool if it can be used again
+func pooled(con *pool.PoolConn) func(usable bool) error {
+	return func(usable bool) error {
+		if !usable {
+			con.MarkUnusable()
+		}
+		return con.Close()
+	}
+}
+
+// withTraceContext returns a copy of the request carrying the trace in ctx in its traceContext argument,
+// as websocket messages have no headers. The global propagator does nothing unless one is set.
+func withTraceContext(ctx context.Context, req *Request) *Request {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 || req.Args == nil {
+		return req
+	}
+	copied, args := *req, *req.Args
+	args.TraceContext = carrier
+	copied.Args = &args
+	return &copied
+}
+
+// getConn takes a connection from the pool. Taking one may mean dialing a new socket, which can take
+// as long as the server takes to answer, so getConn stops waiting when ctx is done or the client is
+// shut down. A socket dialed after that is left in the pool.
+func (t *wsTransport) getConn(ctx context.Context) (*pool.PoolConn, error) {
+	type taken struct {
+		con *pool.PoolConn
+		err error
+	}
+	result := make(chan taken, 1)
+	go func() {
+		con, err := t.takeConn()
+		result <- taken{con, err}
+	}()
+	var err error
+	select {
+	case r := <-result:
+		return r.con, r.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-t.shutdown:
+		err = ErrClientClosed
+	}
+	go func() {
+		if r := <-result; r.err == nil {
+			t.factory.checkin(r.con.Conn, true)
+			r.con.Close()
+		}
+	}()
+	return nil, err
+}
+
+// takeConn takes a connection from the pool, discarding any that fail validation
+func (t *wsTransport) takeConn() (*pool.PoolConn, error) {
+	for {
+		con, err := t.pool.Get()
+		if err != nil {
+			return nil, err
+		}
+		t.stats.add(&t.stats.poolGets, 1)
+		if t.factory.checkout(con.Conn) {
+			return con, nil
+		}
+		con.MarkUnusable()
+		con.Close()
+	}
+}
+
+func (t *wsTransport) executeForConn(ctx context.Context, req *Request, ws *websocket.Conn, release func(usable bool) error, info *RequestInfo) ([]byte, error) {
+	info.Endpoint = t.factory.endpointUrl(ws)
+	stop := t.watchCancel(ctx, ws)
+	b, err := t.roundTrip(req, ws, info)
+	if _, stale := err.(*writeError); !stale && req.Args != nil && req.Args.Session != "" {
+		t.sessions.LoadOrStore(req.Args.Session, info.Endpoint)
+	}
+
+	usable := true
+	if cerr := stop(); cerr != nil {
+		// the deadlines set to interrupt the request leave the socket unusable.
+		// a cancelled request says nothing about the health of the endpoint.
+		usable = false
+		if err != nil {
+			err = cerr
+		}
+	} else if _, stale := err.(*writeError); stale {
+		// a socket that can't be written to was dropped while it sat in the pool,
+		// the endpoint itself may well be healthy
+		usable = false
+	} else if errors.Is(err, ErrTransport) {
+		// update the endpoint to mark success/error, this allows us to back off endpoints that are continuing to fail
+		t.factory.failedEndpoint(ws, err)
+		usable = false
+	} else if _, answered := err.(*ResponseError); err == nil || answered {
+		// a bad script (597), bad bindings (499) or a rejected login were answered by a healthy server,
+		// they don't count against the endpoint and the socket can be used again. server faults do count.
+		if penalizesEndpoint(err) {
+			t.factory.failedEndpoint(ws, err)
+		} else {
+			t.factory.successfulEndpoint(ws)
+		}
+	} else {
+		// the rest of the response can't be told apart from the next one
+		usable = false
+	}
+	t.factory.checkin(ws, usable)
+
+	// if the request was successful, return to the pool, otherwise close and remove from the pool.
+	if cerr := release(usable); err == nil {
+		err = cerr
+	}
+	return b, err
+}
+
+// writeError is returned when a request could not be written to the socket
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string {
+	return e.err.Error()
+}
+
+// roundTrip writes the request to the connection and reads the response, answering any authentication challenge
+func (t *wsTransport) roundTrip(req *Request, con *websocket.Conn, info *RequestInfo) ([]byte, error) {
+	requestMessage, err := t.factory.serialize(req)
+	if err != nil {
+		return nil, err
+	}
+	info.Attempts++
+	if err := con.WriteMessage(websocket.BinaryMessage, requestMessage); err != nil {
+		return nil, &writeError{err}
+	}
+	t.stats.add(&t.stats.bytesOut, len(requestMessage))
+	return t.readResponse(con, info)
+}
+
+
+// this doesn't seem to be useful outside of the Exec function (in this context)
+func (t *wsTransport) readResponse(con *websocket.Conn, info *RequestInfo) (data []byte, err error) {
+	// Data buffer
+	var message []byte
+	var dataItems []json.RawMessage
+	inBatchMode := false
+	// Receive data
+	for {
+		if _, message, err = con.ReadMessage(); err != nil {
+			err = &TransportError{Endpoint: info.Endpoint, Err: err}
+			return
+		}
+		t.stats.add(&t.stats.bytesIn, len(message))
+		if t.factory.graphBinary {
+			if message, err = graphBinaryResponse(message); err != nil {
+				err = &ProtocolError{Endpoint: info.Endpoint, Err: err}
+				return
+			}
+		}
+		var res *Response
+		if err = json.Unmarshal(message, &res); err != nil {
+			err = &ProtocolError{Endpoint: info.Endpoint, Err: err}
+			return
+		}
+		if res == nil || res.Status == nil {
+			err = &ProtocolError{Endpoint: info.Endpoint, Err: MissingStatusError}
+			return
+		}
+		var items []json.RawMessage
+		info.StatusCode = res.Status.Code
+		info.Warnings = append(info.Warnings, res.Status.Attributes.Warnings()...)
+		if res.Status.Code != StatusAuthenticate {
+			info.Batches = append(info.Batches, newBatch(res))
+		}
+		switch res.Status.Code {
+		case StatusNoContent:
+
+			return
+
+		case StatusAuthenticate:
+			t.stats.add(&t.stats.authChallenges, 1)
+			return t.authenticate(con, res.RequestId, info)
+		case StatusPartialContent:
+			inBatchMode = true
+			if items, err = resultItems(res.Result.Data); err != nil {
+				err = &ProtocolError{Endpoint: info.Endpoint, Err: err}
+				return
+			}
+			if info.onBatch != nil {
+				info.onBatch(items)
+			} else {
+				dataItems = append(dataItems, items...)
+			}
+			info.ResultCount += len(items)
+			info.BatchCount++
+			info.lastBatch().ResultCount = len(items)
+
+
+		case StatusSuccess:
+			info.BatchCount++
+			if inBatchMode {
+				if items, err = resultItems(res.Result.Data); err != nil {
+					err = &ProtocolError{Endpoint: info.Endpoint, Err: err}
+					return
+				}
+				info.ResultCount += len(items)
+				if info.onBatch != nil {
+					info.onBatch(items)
+				} else {
+					dataItems = append(dataItems, items...)
+					data, err = json.Marshal(dataItems)
+				}
+			} else {
+				data = res.Result.Data
+				if all, perr := resultItems(data); perr == nil {
+					items = all
+					info.ResultCount = len(items)
+					if info.onBatch != nil {
+						info.onBatch(items)
+						data = nil
+					} else if t.factory.serializer == GraphSONv3 {
+						// results are returned as a plain JSON array whatever the version
+						data, err = json.Marshal(items)
+					}
+				}
+			}
+			info.lastBatch().ResultCount = len(items)
+
+			return
+
+		default:
+			err = &ResponseError{Code: res.Status.Code, Message: res.Status.Message, Attributes: res.Status.Attributes}
+			return
+		}
+	}
+}
+
+func (t *wsTransport) authenticate(con *websocket.Conn, requestId string, info *RequestInfo) ([]byte, error) {
+	auth, err := NewAuthInfo(t.auth...)
+	if err != nil {
+		return nil, err
+	}
+	var sasl []byte
+	sasl = append(sasl, 0)
+	sasl = append(sasl, []byte(auth.User)...)
+	sasl = append(sasl, 0)
+	sasl = append(sasl, []byte(auth.Pass)...)
+	saslEnc := base64.StdEncoding.EncodeToString(sasl)
+	args := &RequestArgs{Sasl: saslEnc}
+	authReq := &Request{
+		RequestId: requestId,
+		Processor: "trasversal",
+		Op:        "authentication",
+		Args:      args,
+	}
+	return t.roundTrip(authReq, con, info)
+}
+
+// watchCancel interrupts the request on the connection when ctx is done or the client is shut down.
+// The returned function stops watching and reports why the request was interrupted, if it was.
+func (t *wsTransport) watchCancel(ctx context.Context, con *websocket.Conn) func() error {
+	stop := make(chan struct{})
+	reason := make(chan error, 1)
+	go func() {
+		var err error
+		select {
+		case <-stop:
+			reason <- nil
+			return
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-t.shutdown:
+			err = ErrClientClosed
+		}
+		// unblock any pending read or write
+		now := time.Now()
+		con.SetReadDeadline(now)
+		con.SetWriteDeadline(now)
+		reason <- err
+	}()
+	return func() error {
+		close(stop)
+		return <-reason
+	}
+}
+
+// CloseSession asks the server the session's requests went to to close it
+func (t *wsTransport) CloseSession(session string) {
+	urlStr, ok := t.sessions.Load(session)
+	if !ok {
+		// no request of the session reached a server
+		return
+	}
+	req := &Request{
+		RequestId: newRequestId(),
+		Op:        "close",
+		Processor: "session",
+		Args:      &RequestArgs{Session: session},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeWriteTimeout)
+	defer cancel()
+	t.closeSession(ctx, req, urlStr.(string), &RequestInfo{})
+}
+
+// closeSession sends the session close request to the endpoint the session lives on
+func (t *wsTransport) closeSession(ctx context.Context, req *Request, urlStr string, info *RequestInfo) error {
+	t.sessions.Delete(req.Args.Session)
+	deadline := time.Now().Add(closeWriteTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return t.closeSessionOn(req, urlStr, deadline, info)
+}
+
+// closeSessionOn sends the session close request on an idle socket to the endpoint, or on a socket dialed for it
+func (t *wsTransport) closeSessionOn(req *Request, urlStr string, deadline time.Time, info *RequestInfo) error {
+	info.Endpoint = urlStr
+	con := t.idleConn(urlStr)
+	var ws *websocket.Conn
+	if con != nil {
+		ws = con.Conn
+	} else {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		var err error
+		if ws, err = t.factory.dialContext(ctx, urlStr); err != nil {
+			return &TransportError{Endpoint: urlStr, Err: err}
+		}
+		defer ws.Close()
+	}
+	ws.SetWriteDeadline(deadline)
+	ws.SetReadDeadline(deadline)
+	_, err := t.roundTrip(req, ws, info)
+	if werr, ok := err.(*writeError); ok {
+		err = &TransportError{Endpoint: urlStr, Err: werr.err}
+	}
+	if con != nil {
+		usable := err == nil
+		if _, answered := err.(*ResponseError); answered {
+			usable = true
+		}
+		if usable {
+			ws.SetWriteDeadline(time.Time{})
+			ws.SetReadDeadline(time.Time{})
+		} else {
+			con.MarkUnusable()
+		}
+		t.factory.checkin(ws, usable)
+		con.Close()
+	}
+	return err
+}
+
+// idleConn takes an idle socket to the endpoint out of the pool, returning the others to it.
+// It returns nil if there is none.
+func (t *wsTransport) idleConn(urlStr string) *pool.PoolConn {
+	for i := t.pool.Len(); i > 0; i-- {
+		con, err := t.pool.Get()
+		if err != nil {
+			return nil
+		}
+		if t.factory.endpointUrl(con.Conn) != urlStr {
+			con.Close()
+			continue
+		}
+		if t.factory.checkout(con.Conn) {
+			return con
+		}
+		con.MarkUnusable()
+		con.Close()
+	}
+	return nil
+}
+
+// Close sends a close frame on every idle connection before closing the pool
+func (t *wsTransport) Close() {
+	close(t.stopMaintenance)
+	for t.pool.Len() > 0 {
+		con, err := t.pool.Get()
+		if err != nil {
+			break
+		}
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		con.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteTimeout))
+		con.MarkUnusable()
+		con.Close()
+	}
+	t.pool.Close()
+}
@@ -0,0 +1,38 @@
+package gremlin
+
+import (
+	"context"
+)
+
+// Transport sends requests to the servers of a client. The scheme of the server urls selects
+// the transport: websockets for ws and wss, http POST requests for http and https.
+type Transport interface {
+	// RoundTrip sends the request and returns the aggregated result data, filling in info as it goes
+	RoundTrip(ctx context.Context, req *Request, info *RequestInfo) ([]byte, error)
+	// CloseSession asks the server to close a session used by the client
+	CloseSession(session string)
+	// Idle returns the number of idle connections held by the transport
+	Idle() int
+	// Close releases the transport's connections
+	Close()
+}
+
+func newTransport(c *Client) (Transport, error) {
+	if c.factory.http {
+		return newHTTPTransport(c), nil
+	}
+	return newWebSocketTransport(c)
+}
+
+// withShutdown returns a context that is also cancelled when shutdown is closed
+func withShutdown(ctx context.Context, shutdown <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
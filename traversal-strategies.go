@@ -0,0 +1,103 @@
+package gremlin
+
+// TraversalStrategy is a strategy added to a traversal source with WithStrategies. Strategies are
+// sent as part of the bytecode and applied by the server to every traversal of the source.
+type TraversalStrategy interface {
+	GraphSONMarshaler
+	// StrategyName is the name of the strategy's class, such as PartitionStrategy
+	StrategyName() string
+}
+
+// strategyGraphSON sends a strategy as its name with its configuration, leaving out nil values
+func strategyGraphSON(name string, config map[string]interface{}, version GraphSONVersion) (interface{}, error) {
+	typed := map[string]interface{}{}
+	for key, value := range config {
+		g, err := toGraphSON(value, version)
+		if err != nil {
+			return nil, err
+		}
+		if g != nil {
+			typed[key] = g
+		}
+	}
+	return TypedValue{"g:" + name, typed}, nil
+}
+
+// PartitionStrategy confines traversals to the partitions they may read, and writes new elements to a partition
+// by setting the partition key on them. It is a way to keep the data of several tenants in one graph.
+type PartitionStrategy struct {
+	PartitionKey          string
+	WritePartition        string
+	ReadPartitions        []string
+	IncludeMetaProperties bool
+}
+
+func (s PartitionStrategy) StrategyName() string { return "PartitionStrategy" }
+
+func (s PartitionStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	config := map[string]interface{}{"partitionKey": s.PartitionKey}
+	if s.WritePartition != "" {
+		config["writePartition"] = s.WritePartition
+	}
+	if s.ReadPartitions != nil {
+		config["readPartitions"] = s.ReadPartitions
+	}
+	if s.IncludeMetaProperties {
+		config["includeMetaProperties"] = true
+	}
+	return strategyGraphSON(s.StrategyName(), config, version)
+}
+
+// SubgraphStrategy limits traversals to the vertices, edges and vertex properties matched by the given
+// traversals, such as Anon().Has("tenant", "a"). Unset traversals don't filter anything.
+type SubgraphStrategy struct {
+	Vertices         *GraphTraversal
+	Edges            *GraphTraversal
+	VertexProperties *GraphTraversal
+}
+
+func (s SubgraphStrategy) StrategyName() string { return "SubgraphStrategy" }
+
+func (s SubgraphStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return strategyGraphSON(s.StrategyName(), map[string]interface{}{
+		"vertices":         s.Vertices,
+		"edges":            s.Edges,
+		"vertexProperties": s.VertexProperties,
+	}, version)
+}
+
+// ReadOnlyStrategy fails traversals that would change the graph
+type ReadOnlyStrategy struct{}
+
+func (s ReadOnlyStrategy) StrategyName() string { return "ReadOnlyStrategy" }
+
+func (s ReadOnlyStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return strategyGraphSON(s.StrategyName(), nil, version)
+}
+
+// OptionsStrategy passes options to the server and to the steps of the traversal, see GraphTraversalSource.With
+type OptionsStrategy struct {
+	Options map[string]interface{}
+}
+
+func (s OptionsStrategy) StrategyName() string { return "OptionsStrategy" }
+
+func (s OptionsStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return strategyGraphSON(s.StrategyName(), s.Options, version)
+}
+
+// ElementIdStrategy stores element ids in a property, for graphs that don't accept user supplied ids.
+// The server uses __id when IdPropertyKey is empty.
+type ElementIdStrategy struct {
+	IdPropertyKey string
+}
+
+func (s ElementIdStrategy) StrategyName() string { return "ElementIdStrategy" }
+
+func (s ElementIdStrategy) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	config := map[string]interface{}{}
+	if s.IdPropertyKey != "" {
+		config["idPropertyKey"] = s.IdPropertyKey
+	}
+	return strategyGraphSON(s.StrategyName(), config, version)
+}
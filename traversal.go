@@ -0,0 +1,572 @@
+package gremlin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	AnonymousTraversalError = errors.New("anonymous traversals can't be executed, spawn the traversal from a GraphTraversalSource")
+	NoMoreResultsError      = errors.New("the traversal has no more results")
+)
+
+// Bytecode is a traversal in the form evaluated by the traversal processor of Gremlin Server.
+// Every instruction is the name of a step or source method followed by its arguments.
+type Bytecode struct {
+	Sources [][]interface{}
+	Steps   [][]interface{}
+}
+
+func (b *Bytecode) addSource(name string, args ...interface{}) {
+	b.Sources = append(b.Sources, append([]interface{}{name}, args...))
+}
+
+func (b *Bytecode) addStep(name string, args ...interface{}) {
+	b.Steps = append(b.Steps, append([]interface{}{name}, args...))
+}
+
+// clone copies the instructions so that appending to the copy leaves b alone
+func (b Bytecode) clone() Bytecode {
+	return Bytecode{
+		Sources: append([][]interface{}(nil), b.Sources...),
+		Steps:   append([][]interface{}(nil), b.Steps...),
+	}
+}
+
+func (b Bytecode) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	value := map[string]interface{}{}
+	for key, instructions := range map[string][][]interface{}{"source": b.Sources, "step": b.Steps} {
+		if len(instructions) == 0 {
+			continue
+		}
+		typed := make([][]interface{}, len(instructions))
+		for i, instruction := range instructions {
+			typed[i] = []interface{}{instruction[0]}
+			for _, arg := range instruction[1:] {
+				g, err := toGraphSON(arg, version)
+				if err != nil {
+					return nil, err
+				}
+				typed[i] = append(typed[i], g)
+			}
+		}
+		value[key] = typed
+	}
+	return TypedValue{"g:Bytecode", value}, nil
+}
+
+// BytecodeRequest builds a request evaluating the bytecode against the given traversal source of the server
+func BytecodeRequest(bytecode Bytecode, traversalSource string) *Request {
+	return &Request{
+		RequestId: newRequestId(),
+		Op:        "bytecode",
+		Processor: "traversal",
+		Args: &RequestArgs{
+			Bytecode: bytecode,
+			Aliases:  map[string]string{"g": traversalSource},
+		},
+	}
+}
+
+// GraphTraversalSource spawns traversals that are sent to the server as bytecode. Its methods return
+// a new source, so a source configured with strategies can be shared, for example one per tenant.
+type GraphTraversalSource struct {
+	client          *Client
+	traversalSource string
+	bytecode        Bytecode
+	options         map[string]interface{}
+}
+
+// NewGraphTraversalSource returns a source for traversals against the traversal source with the given
+// name in the server's configuration, "g" when empty
+func NewGraphTraversalSource(client *Client, traversalSource string) *GraphTraversalSource {
+	if traversalSource == "" {
+		traversalSource = "g"
+	}
+	return &GraphTraversalSource{client: client, traversalSource: traversalSource}
+}
+
+func (g *GraphTraversalSource) clone() *GraphTraversalSource {
+	c := *g
+	c.bytecode = g.bytecode.clone()
+	c.options = make(map[string]interface{}, len(g.options))
+	for key, value := range g.options {
+		c.options[key] = value
+	}
+	return &c
+}
+
+// WithStrategies returns a source whose traversals are run with the given strategies
+func (g *GraphTraversalSource) WithStrategies(strategies ...TraversalStrategy) *GraphTraversalSource {
+	c := g.clone()
+	args := make([]interface{}, len(strategies))
+	for i, strategy := range strategies {
+		args[i] = strategy
+	}
+	c.bytecode.addSource("withStrategies", args...)
+	return c
+}
+
+// With sets an option of the traversal, sent with an OptionsStrategy. The request arguments batchSize,
+// evaluationTimeout, userAgent and materializeProperties are also set on the request itself.
+func (g *GraphTraversalSource) With(key string, value interface{}) *GraphTraversalSource {
+	c := g.clone()
+	c.options[key] = value
+	return c
+}
+
+// WithOptions sets the request options that apply to traversals, see With
+func (g *GraphTraversalSource) WithOptions(opts RequestOptions) *GraphTraversalSource {
+	c := g.clone()
+	if opts.BatchSize != 0 {
+		c.options["batchSize"] = opts.BatchSize
+	}
+	if opts.EvaluationTimeout != 0 {
+		c.options["evaluationTimeout"] = timeoutMillis(opts.EvaluationTimeout)
+	}
+	if opts.UserAgent != "" {
+		c.options["userAgent"] = opts.UserAgent
+	}
+	if opts.MaterializeProperties != "" {
+		c.options["materializeProperties"] = opts.MaterializeProperties
+	}
+	return c
+}
+
+// requestOptions picks the options that are also request arguments
+func (g *GraphTraversalSource) requestOptions() RequestOptions {
+	var opts RequestOptions
+	if n, ok := intOption(g.options["batchSize"]); ok {
+		opts.BatchSize = int(n)
+	}
+	if n, ok := intOption(g.options["evaluationTimeout"]); ok {
+		opts.EvaluationTimeout = time.Duration(n) * time.Millisecond
+	}
+	if s, ok := g.options["userAgent"].(string); ok {
+		opts.UserAgent = s
+	}
+	if s, ok := g.options["materializeProperties"].(string); ok {
+		opts.MaterializeProperties = s
+	}
+	return opts
+}
+
+func intOption(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// spawn starts a traversal with the source's instructions
+func (g *GraphTraversalSource) spawn(step string, args ...interface{}) *GraphTraversal {
+	t := &GraphTraversal{source: g, bytecode: g.bytecode.clone()}
+	if len(g.options) > 0 {
+		t.bytecode.addSource("withStrategies", OptionsStrategy{Options: g.options})
+	}
+	t.bytecode.addStep(step, args...)
+	return t
+}
+
+func (g *GraphTraversalSource) V(ids ...interface{}) *GraphTraversal { return g.spawn("V", ids...) }
+func (g *GraphTraversalSource) E(ids ...interface{}) *GraphTraversal { return g.spawn("E", ids...) }
+func (g *GraphTraversalSource) AddV(label ...interface{}) *GraphTraversal {
+	return g.spawn("addV", label...)
+}
+func (g *GraphTraversalSource) AddE(label interface{}) *GraphTraversal { return g.spawn("addE", label) }
+func (g *GraphTraversalSource) Inject(values ...interface{}) *GraphTraversal {
+	return g.spawn("inject", values...)
+}
+
+// GraphTraversal builds a traversal step by step. Unlike the source, steps add to the traversal they are called on.
+// The traversal is sent by the first terminal step, Next, HasNext, ToList, ToSet or Iterate, and later terminal steps
+// carry on with the results that haven't been consumed yet. Results are handed out as the server sends them, so the
+// first Next doesn't wait for the last batch. The context of the first terminal step bounds the whole request.
+// A traversal must not be used by several goroutines at once.
+type GraphTraversal struct {
+	source   *GraphTraversalSource
+	bytecode Bytecode
+
+	stream     *traversalStream // nil until the traversal is sent
+	traversers []Traverser      // results taken from the stream that haven't been consumed yet
+}
+
+// Anon starts an anonymous traversal, the __ of other Gremlin variants, for use as the argument of a step
+func Anon() *GraphTraversal {
+	return &GraphTraversal{}
+}
+
+// Bytecode returns the instructions of the traversal
+func (t *GraphTraversal) Bytecode() Bytecode {
+	return t.bytecode.clone()
+}
+
+func (t *GraphTraversal) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return t.bytecode.MarshalGraphSON(version)
+}
+
+// Request returns the bytecode request for the traversal
+func (t *GraphTraversal) Request() (*Request, error) {
+	if t.source == nil {
+		return nil, AnonymousTraversalError
+	}
+	req := BytecodeRequest(t.Bytecode(), t.source.traversalSource)
+	return req.Options(t.source.requestOptions()), nil
+}
+
+// Do sends the traversal to the server and returns all of its results at once
+func (t *GraphTraversal) Do(ctx context.Context) (*Result, error) {
+	req, err := t.Request()
+	if err != nil {
+		return nil, err
+	}
+	if t.source.client == nil {
+		return nil, NoClusterError
+	}
+	return t.source.client.Do(ctx, req)
+}
+
+// fetch sends the traversal the first time a terminal step is called, its results arrive on the stream
+func (t *GraphTraversal) fetch(ctx context.Context) error {
+	if t.stream != nil {
+		return nil
+	}
+	req, err := t.Request()
+	if err != nil {
+		return err
+	}
+	if t.source.client == nil {
+		return NoClusterError
+	}
+	stream := &traversalStream{ready: make(chan struct{}, 1)}
+	t.stream = stream
+	go func() {
+		res, err := t.source.client.Do(withBatchHandler(ctx, stream.add), req)
+		stream.finish(res, err)
+	}()
+	return nil
+}
+
+// fill takes the next batch off the stream when all results taken so far have been consumed.
+// It returns false once the stream has ended.
+func (t *GraphTraversal) fill(ctx context.Context) (bool, error) {
+	for len(t.traversers) == 0 {
+		items, more, err := t.stream.next(ctx)
+		if err != nil || !more {
+			return false, err
+		}
+		if t.traversers, err = decodeTraversers(items); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// HasNext reports whether the traversal has results left
+func (t *GraphTraversal) HasNext(ctx context.Context) (bool, error) {
+	if err := t.fetch(ctx); err != nil {
+		return false, err
+	}
+	return t.fill(ctx)
+}
+
+// Next returns the next result, a traverser with a bulk of n is returned n times.
+// NoMoreResultsError is returned once all results have been consumed.
+func (t *GraphTraversal) Next(ctx context.Context) (interface{}, error) {
+	if err := t.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if more, err := t.fill(ctx); err != nil {
+		return nil, err
+	} else if !more {
+		return nil, NoMoreResultsError
+	}
+	next := &t.traversers[0]
+	next.Bulk--
+	if next.Bulk <= 0 {
+		t.traversers = t.traversers[1:]
+	}
+	return next.Value, nil
+}
+
+// remaining waits for the rest of the results and returns them, leaving none to consume
+func (t *GraphTraversal) remaining(ctx context.Context) ([]Traverser, error) {
+	if err := t.fetch(ctx); err != nil {
+		return nil, err
+	}
+	var traversers []Traverser
+	for {
+		more, err := t.fill(ctx)
+		if err != nil || !more {
+			return traversers, err
+		}
+		traversers = append(traversers, t.traversers...)
+		t.traversers = nil
+	}
+}
+
+// ToList returns the remaining results, repeating each by its bulk
+func (t *GraphTraversal) ToList(ctx context.Context) ([]interface{}, error) {
+	traversers, err := t.remaining(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, traverser := range traversers {
+		for i := int64(0); i < traverser.Bulk; i++ {
+			values = append(values, traverser.Value)
+		}
+	}
+	return values, nil
+}
+
+// ToSet returns the distinct remaining results, in the order they were returned
+func (t *GraphTraversal) ToSet(ctx context.Context) (Set, error) {
+	traversers, err := t.remaining(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var values Set
+	for _, traverser := range traversers {
+		key := fmt.Sprintf("%#v", traverser.Value)
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, traverser.Value)
+		}
+	}
+	return values, nil
+}
+
+// Iterate runs the traversal for its side effects, such as adding vertices, and waits for it to complete.
+// A none step is added so the server doesn't send the results back.
+func (t *GraphTraversal) Iterate(ctx context.Context) error {
+	if t.stream == nil {
+		t.Step("none")
+	}
+	_, err := t.remaining(ctx)
+	return err
+}
+
+// traversalStream collects the results of a traversal as the batches of the response are read
+type traversalStream struct {
+	ready chan struct{} // signalled when results are added or the request completes
+
+	mu    sync.Mutex
+	items []json.RawMessage
+	done  bool
+	err   error
+}
+
+func (s *traversalStream) add(items []json.RawMessage) {
+	s.mu.Lock()
+	s.items = append(s.items, items...)
+	s.mu.Unlock()
+	s.signal()
+}
+
+// finish ends the stream with the outcome of the request. Results the transport didn't stream are in its data.
+func (s *traversalStream) finish(res *Result, err error) {
+	var items []json.RawMessage
+	if err == nil && len(res.Data) > 0 {
+		items, err = resultItems(res.Data)
+	}
+	s.mu.Lock()
+	s.items = append(s.items, items...)
+	s.done = true
+	s.err = err
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *traversalStream) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for results and takes all of those that have arrived. It returns false once the request has
+// completed and every result has been taken, along with the error the request failed with, if any.
+func (s *traversalStream) next(ctx context.Context) ([]json.RawMessage, bool, error) {
+	for {
+		s.mu.Lock()
+		items, done, err := s.items, s.done, s.err
+		s.items = nil
+		s.mu.Unlock()
+		if len(items) > 0 {
+			return items, true, nil
+		}
+		if done {
+			return nil, false, err
+		}
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// Step adds a step by name, for steps that have no method of their own
+func (t *GraphTraversal) Step(name string, args ...interface{}) *GraphTraversal {
+	t.bytecode.addStep(name, args...)
+	return t
+}
+
+func (t *GraphTraversal) V(ids ...interface{}) *GraphTraversal      { return t.Step("V", ids...) }
+func (t *GraphTraversal) AddV(label ...interface{}) *GraphTraversal { return t.Step("addV", label...) }
+func (t *GraphTraversal) AddE(label interface{}) *GraphTraversal    { return t.Step("addE", label) }
+func (t *GraphTraversal) From(from interface{}) *GraphTraversal     { return t.Step("from", from) }
+func (t *GraphTraversal) To(to interface{}) *GraphTraversal         { return t.Step("to", to) }
+func (t *GraphTraversal) Property(args ...interface{}) *GraphTraversal {
+	return t.Step("property", args...)
+}
+func (t *GraphTraversal) Drop() *GraphTraversal                   { return t.Step("drop") }
+func (t *GraphTraversal) Has(args ...interface{}) *GraphTraversal { return t.Step("has", args...) }
+func (t *GraphTraversal) HasLabel(labels ...interface{}) *GraphTraversal {
+	return t.Step("hasLabel", labels...)
+}
+func (t *GraphTraversal) HasId(ids ...interface{}) *GraphTraversal { return t.Step("hasId", ids...) }
+func (t *GraphTraversal) HasKey(keys ...interface{}) *GraphTraversal {
+	return t.Step("hasKey", keys...)
+}
+func (t *GraphTraversal) HasNot(key string) *GraphTraversal         { return t.Step("hasNot", key) }
+func (t *GraphTraversal) Is(value interface{}) *GraphTraversal      { return t.Step("is", value) }
+func (t *GraphTraversal) Where(args ...interface{}) *GraphTraversal { return t.Step("where", args...) }
+func (t *GraphTraversal) Filter(traversal *GraphTraversal) *GraphTraversal {
+	return t.Step("filter", traversal)
+}
+func (t *GraphTraversal) Not(traversal *GraphTraversal) *GraphTraversal {
+	return t.Step("not", traversal)
+}
+func (t *GraphTraversal) And(traversals ...interface{}) *GraphTraversal {
+	return t.Step("and", traversals...)
+}
+func (t *GraphTraversal) Or(traversals ...interface{}) *GraphTraversal {
+	return t.Step("or", traversals...)
+}
+func (t *GraphTraversal) Out(labels ...interface{}) *GraphTraversal { return t.Step("out", labels...) }
+func (t *GraphTraversal) In(labels ...interface{}) *GraphTraversal  { return t.Step("in", labels...) }
+func (t *GraphTraversal) Both(labels ...interface{}) *GraphTraversal {
+	return t.Step("both", labels...)
+}
+func (t *GraphTraversal) OutE(labels ...interface{}) *GraphTraversal {
+	return t.Step("outE", labels...)
+}
+func (t *GraphTraversal) InE(labels ...interface{}) *GraphTraversal { return t.Step("inE", labels...) }
+func (t *GraphTraversal) BothE(labels ...interface{}) *GraphTraversal {
+	return t.Step("bothE", labels...)
+}
+func (t *GraphTraversal) OutV() *GraphTraversal   { return t.Step("outV") }
+func (t *GraphTraversal) InV() *GraphTraversal    { return t.Step("inV") }
+func (t *GraphTraversal) OtherV() *GraphTraversal { return t.Step("otherV") }
+func (t *GraphTraversal) Id() *GraphTraversal     { return t.Step("id") }
+func (t *GraphTraversal) Label() *GraphTraversal  { return t.Step("label") }
+func (t *GraphTraversal) Values(keys ...interface{}) *GraphTraversal {
+	return t.Step("values", keys...)
+}
+func (t *GraphTraversal) ValueMap(args ...interface{}) *GraphTraversal {
+	return t.Step("valueMap", args...)
+}
+func (t *GraphTraversal) ElementMap(keys ...interface{}) *GraphTraversal {
+	return t.Step("elementMap", keys...)
+}
+func (t *GraphTraversal) Properties(keys ...interface{}) *GraphTraversal {
+	return t.Step("properties", keys...)
+}
+func (t *GraphTraversal) Constant(value interface{}) *GraphTraversal {
+	return t.Step("constant", value)
+}
+func (t *GraphTraversal) As(labels ...interface{}) *GraphTraversal { return t.Step("as", labels...) }
+func (t *GraphTraversal) Select(args ...interface{}) *GraphTraversal {
+	return t.Step("select", args...)
+}
+func (t *GraphTraversal) Project(keys ...interface{}) *GraphTraversal {
+	return t.Step("project", keys...)
+}
+func (t *GraphTraversal) By(args ...interface{}) *GraphTraversal    { return t.Step("by", args...) }
+func (t *GraphTraversal) Path() *GraphTraversal                     { return t.Step("path") }
+func (t *GraphTraversal) Dedup(args ...interface{}) *GraphTraversal { return t.Step("dedup", args...) }
+func (t *GraphTraversal) Order(args ...interface{}) *GraphTraversal { return t.Step("order", args...) }
+func (t *GraphTraversal) Limit(args ...interface{}) *GraphTraversal { return t.Step("limit", args...) }
+func (t *GraphTraversal) Range(args ...interface{}) *GraphTraversal { return t.Step("range", args...) }
+func (t *GraphTraversal) Skip(args ...interface{}) *GraphTraversal  { return t.Step("skip", args...) }
+func (t *GraphTraversal) Tail(args ...interface{}) *GraphTraversal  { return t.Step("tail", args...) }
+func (t *GraphTraversal) Count(args ...interface{}) *GraphTraversal { return t.Step("count", args...) }
+func (t *GraphTraversal) Sum(args ...interface{}) *GraphTraversal   { return t.Step("sum", args...) }
+func (t *GraphTraversal) Min(args ...interface{}) *GraphTraversal   { return t.Step("min", args...) }
+func (t *GraphTraversal) Max(args ...interface{}) *GraphTraversal   { return t.Step("max", args...) }
+func (t *GraphTraversal) Mean(args ...interface{}) *GraphTraversal  { return t.Step("mean", args...) }
+func (t *GraphTraversal) Fold() *GraphTraversal                     { return t.Step("fold") }
+func (t *GraphTraversal) Unfold() *GraphTraversal                   { return t.Step("unfold") }
+func (t *GraphTraversal) Group(args ...interface{}) *GraphTraversal { return t.Step("group", args...) }
+func (t *GraphTraversal) GroupCount(args ...interface{}) *GraphTraversal {
+	return t.Step("groupCount", args...)
+}
+func (t *GraphTraversal) Aggregate(args ...interface{}) *GraphTraversal {
+	return t.Step("aggregate", args...)
+}
+func (t *GraphTraversal) Cap(keys ...interface{}) *GraphTraversal { return t.Step("cap", keys...) }
+func (t *GraphTraversal) SideEffect(traversal *GraphTraversal) *GraphTraversal {
+	return t.Step("sideEffect", traversal)
+}
+func (t *GraphTraversal) Coalesce(traversals ...interface{}) *GraphTraversal {
+	return t.Step("coalesce", traversals...)
+}
+func (t *GraphTraversal) Union(traversals ...interface{}) *GraphTraversal {
+	return t.Step("union", traversals...)
+}
+func (t *GraphTraversal) Choose(args ...interface{}) *GraphTraversal {
+	return t.Step("choose", args...)
+}
+func (t *GraphTraversal) Optional(traversal *GraphTraversal) *GraphTraversal {
+	return t.Step("optional", traversal)
+}
+func (t *GraphTraversal) Repeat(traversal *GraphTraversal) *GraphTraversal {
+	return t.Step("repeat", traversal)
+}
+func (t *GraphTraversal) Times(n int) *GraphTraversal               { return t.Step("times", n) }
+func (t *GraphTraversal) Until(args ...interface{}) *GraphTraversal { return t.Step("until", args...) }
+func (t *GraphTraversal) Emit(args ...interface{}) *GraphTraversal  { return t.Step("emit", args...) }
+func (t *GraphTraversal) SimplePath() *GraphTraversal               { return t.Step("simplePath") }
+
+// Token is an enum value of the Gremlin language, such as T.id or Order.desc
+type Token struct {
+	typ   string
+	value string
+}
+
+func (t Token) MarshalGraphSON(version GraphSONVersion) (interface{}, error) {
+	return TypedValue{t.typ, t.value}, nil
+}
+
+var (
+	T = struct{ Id, Label, Key, Value Token }{
+		Token{"g:T", "id"}, Token{"g:T", "label"}, Token{"g:T", "key"}, Token{"g:T", "value"},
+	}
+	Order = struct{ Asc, Desc, Shuffle Token }{
+		Token{"g:Order", "asc"}, Token{"g:Order", "desc"}, Token{"g:Order", "shuffle"},
+	}
+	Scope = struct{ Global, Local Token }{
+		Token{"g:Scope", "global"}, Token{"g:Scope", "local"},
+	}
+	Cardinality = struct{ Single, List, Set Token }{
+		Token{"g:Cardinality", "single"}, Token{"g:Cardinality", "list"}, Token{"g:Cardinality", "set"},
+	}
+	Column = struct{ Keys, Values Token }{
+		Token{"g:Column", "keys"}, Token{"g:Column", "values"},
+	}
+	Direction = struct{ Out, In, Both Token }{
+		Token{"g:Direction", "OUT"}, Token{"g:Direction", "IN"}, Token{"g:Direction", "BOTH"},
+	}
+)
@@ -0,0 +1,138 @@
+package gremlin
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytecodeGraphSON(t *testing.T) {
+	g := NewGraphTraversalSource(nil, "")
+	traversal := g.V().Has("person", "age", P.Gt(30)).Order().By("name", Order.Desc).Limit(int64(2))
+	assert.Equal(t, `{"@type":"g:Bytecode","@value":{"step":[["V"],["has","person","age",{"@type":"g:P","@value":{"predicate":"gt","value":{"@type":"g:Int32","@value":30}}}],["order"],["by","name",{"@type":"g:Order","@value":"desc"}],["limit",{"@type":"g:Int64","@value":2}]]}}`,
+		graphSONString(t, traversal, GraphSONv3))
+
+	nested := g.V().Where(Anon().Out("knows").Count().Is(P.Gte(2)))
+	assert.Equal(t, `{"@type":"g:Bytecode","@value":{"step":[["V"],["where",{"@type":"g:Bytecode","@value":{"step":[["out","knows"],["count"],["is",{"@type":"g:P","@value":{"predicate":"gte","value":{"@type":"g:Int32","@value":2}}}]]}}]]}}`,
+		graphSONString(t, nested, GraphSONv2))
+}
+
+func TestWithStrategies(t *testing.T) {
+	g := NewGraphTraversalSource(nil, "tenants")
+	tenant := g.WithStrategies(PartitionStrategy{PartitionKey: "tenant", WritePartition: "a", ReadPartitions: []string{"a"}})
+	readOnly := tenant.WithStrategies(ReadOnlyStrategy{}, ElementIdStrategy{})
+
+	assert.Equal(t, `{"@type":"g:Bytecode","@value":{"step":[["V"]]}}`, graphSONString(t, g.V(), GraphSONv3))
+	assert.Equal(t, `{"@type":"g:Bytecode","@value":{"source":[["withStrategies",{"@type":"g:PartitionStrategy","@value":{"partitionKey":"tenant","readPartitions":{"@type":"g:List","@value":["a"]},"writePartition":"a"}}]],"step":[["V"]]}}`,
+		graphSONString(t, tenant.V(), GraphSONv3))
+	assert.Equal(t, `{"@type":"g:Bytecode","@value":{"source":[["withStrategies",{"@type":"g:PartitionStrategy","@value":{"partitionKey":"tenant","readPartitions":["a"],"writePartition":"a"}}],["withStrategies",{"@type":"g:ReadOnlyStrategy","@value":{}},{"@type":"g:ElementIdStrategy","@value":{}}]],"step":[["V"]]}}`,
+		graphSONString(t, readOnly.V(), GraphSONv2))
+
+	subgraph := SubgraphStrategy{Vertices: Anon().Has("tenant", "a")}
+	assert.Equal(t, `{"@type":"g:SubgraphStrategy","@value":{"vertices":{"@type":"g:Bytecode","@value":{"step":[["has","tenant","a"]]}}}}`,
+		graphSONString(t, subgraph, GraphSONv3))
+	assert.Equal(t, `{"@type":"g:OptionsStrategy","@value":{"x":false}}`,
+		graphSONString(t, OptionsStrategy{Options: map[string]interface{}{"x": false}}, GraphSONv2))
+}
+
+func TestTraversalRequest(t *testing.T) {
+	server := newTestServer(successResponse(`[{"@type":"g:Int64","@value":3}]`))
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	g := NewGraphTraversalSource(cl, "tenants").
+		WithStrategies(PartitionStrategy{PartitionKey: "tenant", ReadPartitions: []string{"a"}}).
+		WithOptions(RequestOptions{BatchSize: 10, EvaluationTimeout: time.Second}).
+		With("custom", "x")
+	res, err := g.V().Count().Do(context.Background())
+	assert.Empty(t, err)
+	assert.Equal(t, `[{"@type":"g:Int64","@value":3}]`, string(res.Data))
+
+	received := server.received()
+	if assert.Len(t, received, 1) {
+		req := received[0]
+		assert.Equal(t, "bytecode", req.Op)
+		assert.Equal(t, "traversal", req.Processor)
+		assert.Equal(t, map[string]interface{}{"g": "tenants"}, req.Args["aliases"])
+		assert.Equal(t, 10.0, req.Args["batchSize"])
+		assert.Equal(t, 1000.0, req.Args["evaluationTimeout"])
+		bytecode := req.Args["gremlin"].(map[string]interface{})
+		assert.Equal(t, "g:Bytecode", bytecode["@type"])
+		sources := bytecode["@value"].(map[string]interface{})["source"].([]interface{})
+		if assert.Len(t, sources, 2) {
+			options := sources[1].([]interface{})[1].(map[string]interface{})
+			assert.Equal(t, "g:OptionsStrategy", options["@type"])
+			assert.Equal(t, "x", options["@value"].(map[string]interface{})["custom"])
+		}
+	}
+
+	_, err = Anon().Out().Do(context.Background())
+	assert.Equal(t, AnonymousTraversalError, err)
+}
+
+func TestTraversalStreamsBatches(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		return []*Response{
+			testResponse(req, StatusPartialContent, `{"@type":"g:List","@value":[{"@type":"g:Int32","@value":1},{"@type":"g:Int32","@value":2}]}`),
+			testResponse(req, StatusPartialContent, `{"@type":"g:List","@value":[{"@type":"g:Int32","@value":3}]}`),
+			testResponse(req, StatusSuccess, `{"@type":"g:List","@value":[{"@type":"g:Traverser","@value":{"bulk":{"@type":"g:Int64","@value":2},"value":{"@type":"g:Int32","@value":4}}}]}`),
+		}
+	})
+	server.gate = make(chan struct{})
+	defer server.Close()
+	cl, err := NewClientWithOptions(server.Url, OptClientSerializer(GraphSONv3))
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	traversal := NewGraphTraversalSource(cl, "").V().Values("n")
+	// the first batch is handed out while the server holds back the others
+	for _, want := range []interface{}{int32(1), int32(2)} {
+		v, err := traversal.Next(ctx)
+		assert.Empty(t, err)
+		assert.Equal(t, want, v)
+	}
+	server.gate <- struct{}{}
+	v, err := traversal.Next(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, int32(3), v)
+	server.gate <- struct{}{}
+	values, err := traversal.ToList(ctx)
+	assert.Empty(t, err)
+	assert.Equal(t, []interface{}{int32(4), int32(4)}, values)
+	_, err = traversal.Next(ctx)
+	assert.Equal(t, NoMoreResultsError, err)
+}
+
+func TestTraversalStreamError(t *testing.T) {
+	server := newTestServer(func(req *testRequest) []*Response {
+		return []*Response{
+			testResponse(req, StatusPartialContent, `[1]`),
+			testResponse(req, StatusServerError, ""),
+		}
+	})
+	defer server.Close()
+	cl, err := NewClient(server.Url)
+	if !assert.Empty(t, err) {
+		return
+	}
+	defer cl.Close()
+
+	// the results sent before the failure are handed out, then the error
+	traversal := NewGraphTraversalSource(cl, "").V()
+	v, err := traversal.Next(context.Background())
+	assert.Empty(t, err)
+	assert.Equal(t, int64(1), v)
+	_, err = traversal.Next(context.Background())
+	if assert.IsType(t, &ResponseError{}, err) {
+		assert.Equal(t, StatusServerError, err.(*ResponseError).Code)
+	}
+}